@@ -0,0 +1,60 @@
+package main
+
+import (
+	"Elschool-API/internal/fakeelschool"
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// fakeelschool serves the fixture students over plain HTTP. Point the api at
+// it with an infra url like "http://localhost:8090".
+func main() {
+	var (
+		addr         string
+		fixturesPath string
+	)
+
+	flag.StringVar(&addr, "addr", "localhost:8090", "listen address")
+	flag.StringVar(&fixturesPath, "fixtures", "config/fakeelschool.yaml", "fixtures path")
+	flag.Parse()
+
+	log := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	fixtures, err := fakeelschool.LoadFixtures(fixturesPath)
+	if err != nil {
+		panic(err)
+	}
+
+	server, err := fakeelschool.New(log, *fixtures)
+	if err != nil {
+		panic(err)
+	}
+
+	httpServer := &http.Server{Addr: addr, Handler: server, ReadHeaderTimeout: 5 * time.Second}
+
+	go func() {
+		log.Info("fake elschool started", slog.String("addr", addr), slog.Int("students", len(fixtures.Students)))
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			panic(err)
+		}
+	}()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer cancel()
+	<-ctx.Done()
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelShutdown()
+
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		log.Error("failed to stop fake elschool", "error", err)
+	}
+	log.Info("fake elschool stopped")
+}
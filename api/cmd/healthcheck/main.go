@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthv1 "google.golang.org/grpc/health/grpc_health_v1"
+	"os"
+	"time"
+)
+
+// healthcheck asks the server for its health status and exits non-zero
+// unless it is serving. It is meant for container health checks.
+func main() {
+	var (
+		addr    string
+		service string
+		timeout time.Duration
+	)
+
+	flag.StringVar(&addr, "addr", "localhost:44044", "server address")
+	flag.StringVar(&service, "service", "", "service to check, empty for the whole server")
+	flag.DurationVar(&timeout, "timeout", 3*time.Second, "check timeout")
+	flag.Parse()
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	resp, err := healthv1.NewHealthClient(conn).Check(ctx, &healthv1.HealthCheckRequest{Service: service})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	fmt.Println(resp.GetStatus())
+
+	if resp.GetStatus() != healthv1.HealthCheckResponse_SERVING {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"Elschool-API/internal/config"
+	"Elschool-API/internal/infra/secrets"
+	"Elschool-API/internal/infra/storage"
+	"Elschool-API/internal/infra/storage/postgres"
+	"Elschool-API/internal/infra/storage/sqlite"
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+const batchSize = 100
+
+const usage = `usage: keyctl [-config path] <command>
+
+commands:
+  generate  print a new random key for the keyring
+  encrypt   encrypt credentials stored before encryption was enabled,
+            the server does it on start as well
+  rotate    re-wrap data keys with the active master key
+`
+
+type keyStorage interface {
+	EncryptStudents(ctx context.Context, batchSize int) (encrypted int, err error)
+	RotateStudentKeys(ctx context.Context, batchSize int) (rotated int, err error)
+}
+
+func main() {
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+
+	var configPath string
+	flag.StringVar(&configPath, "config", "", "config path")
+	flag.Parse()
+
+	if configPath == "" {
+		configPath = os.Getenv("CONFIG_PATH")
+	}
+
+	command := flag.Arg(0)
+
+	if command == "generate" {
+		key, err := secrets.GenerateKey()
+		if err != nil {
+			panic(err)
+		}
+		fmt.Println(key)
+		return
+	}
+
+	if command != "encrypt" && command != "rotate" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if configPath == "" {
+		panic("config path is empty")
+	}
+	cfg := config.MustLoadByPath(configPath)
+
+	log := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+
+	keyring, err := secrets.LoadKeyring(&cfg.SecretsConfig)
+	if err != nil {
+		panic(err)
+	}
+
+	db, storageInfra, err := setupStorage(&cfg.StorageConfig, keyring)
+	if err != nil {
+		panic(err)
+	}
+	defer db.Close()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer cancel()
+
+	switch command {
+	case "encrypt":
+		log.Info("encrypting students")
+		encrypted, err := storageInfra.EncryptStudents(ctx, batchSize)
+		if err != nil {
+			log.Error("failed to encrypt students", slog.Int("encrypted", encrypted), slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		log.Info("students encrypted", slog.Int("encrypted", encrypted))
+	case "rotate":
+		log.Info("rotating student keys", slog.String("active_key", keyring.ActiveKeyID()))
+		rotated, err := storageInfra.RotateStudentKeys(ctx, batchSize)
+		if err != nil {
+			log.Error("failed to rotate student keys", slog.Int("rotated", rotated), slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		log.Info("student keys rotated", slog.Int("rotated", rotated))
+	}
+}
+
+func setupStorage(cfg *config.StorageConfig, keyring *secrets.Keyring) (*sql.DB, keyStorage, error) {
+	switch cfg.Driver {
+	case storage.DriverPostgres:
+		db, err := postgres.InitDB(cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		return db, postgres.New(db, keyring), nil
+	case storage.DriverSQLite:
+		db, err := sqlite.InitDB(cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		return db, sqlite.New(db, keyring), nil
+	}
+
+	return nil, nil, fmt.Errorf("unknown storage driver: %s", cfg.Driver)
+}
@@ -3,32 +3,63 @@ package main
 import (
 	"Elschool-API/internal/app"
 	"Elschool-API/internal/config"
+	"Elschool-API/internal/infra/cache/memory"
 	"Elschool-API/internal/infra/cache/redis"
+	"Elschool-API/internal/infra/cache/tiered"
+	"Elschool-API/internal/infra/events"
 	"Elschool-API/internal/infra/metrics"
+	"Elschool-API/internal/infra/secrets"
+	"Elschool-API/internal/infra/storage"
 	"Elschool-API/internal/infra/storage/postgres"
+	"Elschool-API/internal/infra/storage/sqlite"
+	"database/sql"
+	"fmt"
+	goredis "github.com/go-redis/redis/v8"
 	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
+	// The school timezone is loaded on images without a zoneinfo database.
+	_ "time/tzdata"
 )
 
 const (
 	envLocal = "local"
 	envProd  = "prod"
+
+	cacheRedis  = "redis"
+	cacheMemory = "memory"
 )
 
+type cache interface {
+	app.Cache
+	Close() error
+}
+
+type eventsLog interface {
+	app.Events
+	Close() error
+}
+
 func main() {
 	cfg := config.MustLoad()
 
 	log := setupLogger(cfg.Env)
 	log.Info("starting server", slog.String("env", cfg.Env))
 
-	db, err := postgres.InitDB(&cfg.StorageConfig)
+	db, err := setupDB(&cfg.StorageConfig)
+	if err != nil {
+		panic(err)
+	}
+
+	cacheInfra, rclient, err := setupCache(log, &cfg.CacheConfig)
 	if err != nil {
 		panic(err)
 	}
 
-	rclient, err := redis.InitCache(&cfg.CacheConfig)
+	eventsInfra := setupEvents(log, &cfg.EventsConfig, rclient)
+
+	keyring, err := secrets.LoadKeyring(&cfg.SecretsConfig)
 	if err != nil {
 		panic(err)
 	}
@@ -38,28 +69,61 @@ func main() {
 		panic(err)
 	}
 
-	application := app.New(log, db, rclient, metr, cfg.InfraConfig, cfg.GRPCConfig.Port)
+	application := app.New(log, db, cacheInfra, eventsInfra, keyring, metr, cfg)
 
 	go func() {
 		application.GRPCsrv.MustRun()
 	}()
 
+	if application.GatewaySrv != nil {
+		go func() {
+			application.GatewaySrv.MustRun()
+		}()
+	}
+
+	if application.AdminSrv != nil {
+		go func() {
+			application.AdminSrv.MustRun()
+		}()
+	}
+
+	if application.GCJob != nil {
+		go application.GCJob.Run()
+	}
+
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
 
 	sign := <-stop
 	log.Info("received shutdown signal", slog.String("signal", sign.String()))
 
+	if application.GCJob != nil {
+		application.GCJob.Stop()
+	}
+
+	if application.AdminSrv != nil {
+		application.AdminSrv.Stop()
+	}
+
+	if application.GatewaySrv != nil {
+		application.GatewaySrv.Stop()
+	}
+
 	application.GRPCsrv.Stop()
 
 	log.Info("closing db connection")
 	if err := db.Close(); err != nil {
-		log.Error("Error closing database connection:", err)
+		log.Error("Error closing database connection", "error", err)
+	}
+
+	log.Info("closing events subscription")
+	if err := eventsInfra.Close(); err != nil {
+		log.Error("Error closing events subscription", "error", err)
 	}
 
 	log.Info("closing cache connection")
-	if err := rclient.Close(); err != nil {
-		log.Error("Error closing cache connection:", err)
+	if err := cacheInfra.Close(); err != nil {
+		log.Error("Error closing cache connection", "error", err)
 	}
 
 	log.Info("application stopped")
@@ -77,3 +141,54 @@ func setupLogger(env string) *slog.Logger {
 
 	return log
 }
+
+func setupDB(cfg *config.StorageConfig) (*sql.DB, error) {
+	switch cfg.Driver {
+	case storage.DriverPostgres:
+		return postgres.InitDB(cfg)
+	case storage.DriverSQLite:
+		return sqlite.InitDB(cfg)
+	}
+
+	return nil, fmt.Errorf("unknown storage driver: %s", cfg.Driver)
+}
+
+// setupCache returns the Redis client along with the cache, nil for the
+// memory cache.
+func setupCache(log *slog.Logger, cfg *config.CacheConfig) (cache, *goredis.Client, error) {
+	switch cfg.Driver {
+	case cacheRedis:
+		rclient, err := redis.InitCache(cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if !cfg.L1.Enabled {
+			return redis.New(rclient, cfg), rclient, nil
+		}
+
+		l1 := memory.New(&config.CacheConfig{
+			TokenTTL:       min(cfg.L1.TTL, cfg.TokenTTL),
+			MarksTTL:       min(cfg.L1.TTL, cfg.MarksTTL),
+			StaleRetention: min(cfg.L1.TTL, cfg.StaleRetention),
+			MaxEntries:     cfg.L1.MaxEntries,
+			MaxMemoryMB:    cfg.L1.MaxMemoryMB,
+		})
+
+		return tiered.New(log, l1, redis.New(rclient, cfg), rclient), rclient, nil
+	case cacheMemory:
+		return memory.New(cfg), nil, nil
+	}
+
+	return nil, nil, fmt.Errorf("unknown cache driver: %s", cfg.Driver)
+}
+
+// setupEvents shares marks events between the replicas over the Redis of the
+// cache. With the memory cache there is a single replica, which keeps them.
+func setupEvents(log *slog.Logger, cfg *config.EventsConfig, rclient *goredis.Client) eventsLog {
+	if rclient == nil {
+		return events.New(cfg)
+	}
+
+	return events.NewShared(log, rclient, cfg)
+}
@@ -0,0 +1,80 @@
+package adminapp
+
+import (
+	"Elschool-API/internal/config"
+	admingrpc "Elschool-API/internal/grpc/admin"
+	"Elschool-API/internal/grpc/interceptors"
+	"Elschool-API/internal/infra/metrics"
+	"fmt"
+	"google.golang.org/grpc"
+	"log/slog"
+	"net"
+)
+
+// App serves the admin service on its own port, so it can be kept off the
+// public network. It never accepts api keys, only operator tokens.
+type App struct {
+	log        *slog.Logger
+	gRPCServer *grpc.Server
+	port       int
+}
+
+type Admin interface {
+	admingrpc.Admin
+	interceptors.AuditRecorder
+}
+
+func New(log *slog.Logger, metricsInfra *metrics.Metrics, adminService Admin, cfg config.AdminConfig) *App {
+	gRPCServer := grpc.NewServer(grpc.ChainUnaryInterceptor(
+		interceptors.RequestIDUnary(),
+		interceptors.LoggingUnary(log),
+		interceptors.MetricsUnary(metricsInfra),
+		interceptors.RecoveryUnary(log, metricsInfra),
+		interceptors.AdminAuthUnary(cfg.Tokens),
+		interceptors.AuditUnary(adminService),
+	))
+
+	admingrpc.Register(gRPCServer, adminService)
+
+	return &App{
+		log:        log,
+		gRPCServer: gRPCServer,
+		port:       cfg.Port,
+	}
+}
+
+func (a *App) MustRun() {
+	if err := a.Run(); err != nil {
+		panic(err)
+	}
+}
+
+func (a *App) Run() error {
+	const op = "adminapp.Run"
+
+	log := a.log.With(slog.String("op", op))
+
+	log.Info("starting admin server")
+
+	l, err := net.Listen("tcp", fmt.Sprintf(":%d", a.port))
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("admin server started", slog.String("addr", l.Addr().String()))
+
+	if err := a.gRPCServer.Serve(l); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (a *App) Stop() {
+	const op = "adminapp.Stop"
+
+	a.log.With(slog.String("op", op)).Info("stopping admin server")
+
+	a.gRPCServer.GracefulStop()
+}
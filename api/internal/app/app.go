@@ -1,38 +1,146 @@
 package app
 
 import (
+	adminapp "Elschool-API/internal/app/admin"
+	gatewayapp "Elschool-API/internal/app/gateway"
+	gcapp "Elschool-API/internal/app/gc"
 	grpcapp "Elschool-API/internal/app/grpc"
 	"Elschool-API/internal/config"
-	"Elschool-API/internal/infra/auth"
-	cache "Elschool-API/internal/infra/cache/redis"
-	"Elschool-API/internal/infra/fetcher"
+	"Elschool-API/internal/grpc/health"
+	"Elschool-API/internal/infra/diary"
+	"Elschool-API/internal/infra/diary/elschool"
+	"Elschool-API/internal/infra/events"
 	"Elschool-API/internal/infra/metrics"
+	"Elschool-API/internal/infra/secrets"
+	"Elschool-API/internal/infra/storage"
 	"Elschool-API/internal/infra/storage/postgres"
+	"Elschool-API/internal/infra/storage/sqlite"
 	"Elschool-API/internal/infra/storage/transaction"
+	"Elschool-API/internal/service/admin"
+	"Elschool-API/internal/service/apikeys"
+	"Elschool-API/internal/service/gc"
 	"Elschool-API/internal/service/marks"
+	"Elschool-API/internal/service/quota"
 	"Elschool-API/internal/service/student"
 	"Elschool-API/internal/service/user"
+	"context"
 	"database/sql"
-	"github.com/go-redis/redis/v8"
 	"log/slog"
+	"time"
 )
 
 type App struct {
 	GRPCsrv *grpcapp.App
+	// GatewaySrv is nil unless the HTTP gateway is enabled.
+	GatewaySrv *gatewayapp.App
+	// AdminSrv is nil unless the admin service is enabled and has tokens.
+	AdminSrv *adminapp.App
+	// GCJob is nil unless garbage collection is enabled.
+	GCJob *gcapp.App
 }
 
-func New(log *slog.Logger, db *sql.DB, rclient *redis.Client, metricsInfra *metrics.Metrics, infraCfg config.InfraConfig, grpcPort int) *App {
-	storageInfra := postgres.New(db)
+type Cache interface {
+	marks.TokenCache
+	marks.MarksCache
+	student.StudentCache
+	user.StudentCache
+	gc.StudentCache
+	quota.CounterCache
+	events.TicketStore
+	Ping(ctx context.Context) error
+}
+
+// Events is the marks events log, shared by the replicas or kept by the
+// single one.
+type Events interface {
+	marks.MarksEvents
+}
+
+type Storage interface {
+	user.UserStorage
+	student.StudentStorage
+	student.UserStudentsStorage
+	marks.StudentStorage
+	marks.UserTokenStorage
+	apikeys.APIKeyStorage
+	admin.AdminStorage
+	admin.AuditStorage
+	gc.StudentStorage
+	quota.QuotaStorage
+	EncryptStudents(ctx context.Context, batchSize int) (encrypted int, err error)
+}
+
+// encryptBatchSize is how many students are encrypted per transaction on
+// start.
+const encryptBatchSize = 100
+
+func New(log *slog.Logger, db *sql.DB, cacheInfra Cache, eventsInfra Events, keyring *secrets.Keyring, metricsInfra *metrics.Metrics, cfg *config.Config) *App {
+	storageInfra := newStorage(cfg.StorageConfig.Driver, db, keyring)
+
+	// Credentials stored before encryption was introduced are sealed before
+	// anything is served, storage doesn't read plaintext ones.
+	encrypted, err := storageInfra.EncryptStudents(context.Background(), encryptBatchSize)
+	if err != nil {
+		panic("failed to encrypt students: " + err.Error())
+	}
+	if encrypted > 0 {
+		log.Info("students encrypted", slog.Int("encrypted", encrypted))
+	}
+
 	txManager := transaction.NewTransactionManager(db)
-	cacheInfra := cache.New(rclient)
-	authInfra := auth.New(infraCfg.Url)
-	fetcherInfra := fetcher.New(infraCfg.Url)
+	providersInfra := diary.NewRegistry()
+	providersInfra.Register(elschool.Name, elschool.New(cfg.InfraConfig, metricsInfra))
+
+	quotaService := quota.New(log, storageInfra, cacheInfra, metricsInfra, cfg.QuotasConfig)
+	userService := user.New(log, storageInfra, cacheInfra, metricsInfra, cfg.UsersConfig)
+	studentService := student.New(log, storageInfra, storageInfra, storageInfra, cacheInfra, providersInfra, txManager, storageInfra, quotaService, metricsInfra)
+	ticketsInfra := events.NewTickets(cacheInfra, &cfg.EventsConfig)
 
-	userService := user.New(log, storageInfra, metricsInfra)
-	studentService := student.New(log, storageInfra, storageInfra, authInfra, txManager, metricsInfra)
-	marksService := marks.New(log, storageInfra, cacheInfra, cacheInfra, authInfra, fetcherInfra, metricsInfra)
+	marksService := marks.New(log, storageInfra, storageInfra, cacheInfra, cacheInfra, providersInfra, eventsInfra, ticketsInfra, quotaService, metricsInfra, cfg.MarksConfig)
+
+	apiKeysService := apikeys.New(log, storageInfra, metricsInfra, cfg.APIKeysConfig)
+
+	health := healthgrpc.New(log, map[string]healthgrpc.Probe{
+		healthgrpc.DependencyDB:       db.PingContext,
+		healthgrpc.DependencyCache:    cacheInfra.Ping,
+		healthgrpc.DependencyElschool: providersInfra.Ping,
+	}, cfg.GRPCConfig.Health.Interval, cfg.GRPCConfig.Health.Timeout)
+
+	schoolLocation, err := time.LoadLocation(cfg.MarksConfig.SchoolTimezone)
+	if err != nil {
+		panic("unknown school timezone: " + err.Error())
+	}
+
+	grpcApp := grpcapp.New(log, metricsInfra, userService, quotaService, studentService, marksService, apiKeysService, cfg.APIKeysConfig.Enabled, health, schoolLocation, cfg.GRPCConfig)
+
+	var gatewayApp *gatewayapp.App
+	if cfg.GatewayConfig.Enabled {
+		gatewayApp = gatewayapp.New(log, cfg.EventsConfig.Heartbeat, cfg.APIKeysConfig.Enabled, cfg.GRPCConfig.Port, cfg.GatewayConfig)
+	}
+
+	var adminApp *adminapp.App
+	if cfg.AdminConfig.Enabled {
+		if len(cfg.AdminConfig.Tokens) == 0 {
+			log.Warn("admin service is enabled, but no admin tokens are configured, not starting it")
+		} else {
+			adminService := admin.New(log, storageInfra, storageInfra, cacheInfra, metricsInfra)
+			adminApp = adminapp.New(log, metricsInfra, adminService, cfg.AdminConfig)
+		}
+	}
+
+	var gcJob *gcapp.App
+	if cfg.GCConfig.Enabled {
+		collector := gc.New(log, storageInfra, cacheInfra, metricsInfra, cfg.GCConfig)
+		gcJob = gcapp.New(log, collector, cfg.GCConfig.Interval)
+	}
+
+	return &App{GRPCsrv: grpcApp, GatewaySrv: gatewayApp, AdminSrv: adminApp, GCJob: gcJob}
+}
 
-	grpcApp := grpcapp.New(log, userService, studentService, marksService, grpcPort)
+func newStorage(driver string, db *sql.DB, keyring *secrets.Keyring) Storage {
+	if driver == storage.DriverSQLite {
+		return sqlite.New(db, keyring)
+	}
 
-	return &App{GRPCsrv: grpcApp}
+	return postgres.New(db, keyring)
 }
@@ -0,0 +1,207 @@
+package gatewayapp
+
+import (
+	"Elschool-API/internal/config"
+	"Elschool-API/internal/grpc/interceptors"
+	eventshttp "Elschool-API/internal/http/events"
+	"context"
+	"errors"
+	"fmt"
+	apiv1 "github.com/Ilya-Repin/elschooler/protos/gen/api/api.v1"
+	"github.com/Ilya-Repin/elschooler/protos/gen/openapiv2"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	OpenAPIPath = "/openapi.json"
+
+	userTokenParam     = "user_token"
+	userTokenJSONParam = "userToken"
+
+	readHeaderTimeout = 10 * time.Second
+	shutdownTimeout   = 10 * time.Second
+)
+
+// App serves the HTTP/JSON API by proxying every call to the gRPC server,
+// so authorization, logging and metrics stay in one place. The marks events
+// stream is proxied to WatchMarks as well, only served as Server-Sent Events.
+type App struct {
+	log            *slog.Logger
+	httpServer     *http.Server
+	eventsBeat     time.Duration
+	grpcAddr       string
+	apiKeysEnabled bool
+	conn           *grpc.ClientConn
+
+	// streams is cancelled on Stop, so long-lived event streams don't
+	// hold up the shutdown.
+	streams       context.Context
+	cancelStreams context.CancelFunc
+}
+
+// New builds the gateway. Event streams are sent a comment every eventsBeat,
+// so proxies don't close them while idle.
+func New(log *slog.Logger, eventsBeat time.Duration, apiKeysEnabled bool, grpcPort int, cfg config.GatewayConfig) *App {
+	streams, cancelStreams := context.WithCancel(context.Background())
+
+	return &App{
+		log:           log,
+		eventsBeat:    eventsBeat,
+		streams:       streams,
+		cancelStreams: cancelStreams,
+		httpServer: &http.Server{
+			Addr:              fmt.Sprintf(":%d", cfg.Port),
+			ReadHeaderTimeout: readHeaderTimeout,
+		},
+		grpcAddr:       net.JoinHostPort("localhost", strconv.Itoa(grpcPort)),
+		apiKeysEnabled: apiKeysEnabled,
+	}
+}
+
+func (a *App) MustRun() {
+	if err := a.Run(); err != nil {
+		panic(err)
+	}
+}
+
+func (a *App) Run() error {
+	const op = "gatewayapp.Run"
+
+	log := a.log.With(slog.String("op", op))
+
+	log.Info("starting HTTP gateway")
+
+	conn, err := grpc.NewClient(a.grpcAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	a.conn = conn
+
+	mux := runtime.NewServeMux(
+		runtime.WithIncomingHeaderMatcher(incomingHeader),
+		runtime.WithOutgoingHeaderMatcher(outgoingHeader),
+	)
+
+	ctx := context.Background()
+	registrations := []func(context.Context, *runtime.ServeMux, *grpc.ClientConn) error{
+		apiv1.RegisterUserHandler,
+		apiv1.RegisterStudentHandler,
+		apiv1.RegisterMarksHandler,
+	}
+	if a.apiKeysEnabled {
+		registrations = append(registrations, apiv1.RegisterApiKeysHandler)
+	}
+
+	for _, register := range registrations {
+		if err := register(ctx, mux, conn); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	handler := http.NewServeMux()
+	handler.HandleFunc(OpenAPIPath, serveOpenAPI)
+	handler.Handle(eventshttp.Pattern, a.streaming(eventshttp.New(a.log, apiv1.NewMarksClient(conn), a.eventsBeat)))
+	handler.Handle("/", noTokenInURL(mux))
+	a.httpServer.Handler = handler
+
+	l, err := net.Listen("tcp", a.httpServer.Addr)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("HTTP gateway started", slog.String("addr", l.Addr().String()))
+
+	if err := a.httpServer.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (a *App) Stop() {
+	const op = "gatewayapp.Stop"
+
+	log := a.log.With(slog.String("op", op))
+
+	log.Info("stopping HTTP gateway")
+
+	a.cancelStreams()
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := a.httpServer.Shutdown(ctx); err != nil {
+		log.Error("failed to stop HTTP gateway", "error", err)
+	}
+
+	if a.conn != nil {
+		_ = a.conn.Close()
+	}
+}
+
+func (a *App) streaming(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+
+		stop := context.AfterFunc(a.streams, cancel)
+		defer stop()
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func serveOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(openapiv2.Spec)
+}
+
+// noTokenInURL rejects user tokens in the query, where proxies and access
+// logs keep them. The Authorization header carries them instead.
+func noTokenInURL(mux *runtime.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Has(userTokenParam) || query.Has(userTokenJSONParam) {
+			_, outbound := runtime.MarshalerForRequest(mux, r)
+			runtime.HTTPError(r.Context(), mux, outbound, w, r, status.Error(codes.InvalidArgument, "user token must be sent as Authorization: Bearer <token>, not in the URL"))
+			return
+		}
+
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// incomingHeader passes the api key and request id on to the gRPC server
+// as plain metadata, next to the headers forwarded by default. Authorization,
+// which carries the user token, is always forwarded by the gateway itself.
+func incomingHeader(key string) (string, bool) {
+	switch strings.ToLower(key) {
+	case interceptors.APIKeyHeader, interceptors.RequestIDHeader:
+		return strings.ToLower(key), true
+	}
+
+	return runtime.DefaultHeaderMatcher(key)
+}
+
+func outgoingHeader(key string) (string, bool) {
+	if key == interceptors.RequestIDHeader {
+		return http.CanonicalHeaderKey(key), true
+	}
+
+	return fmt.Sprintf("%s%s", runtime.MetadataHeaderPrefix, key), true
+}
@@ -0,0 +1,74 @@
+package gcapp
+
+import (
+	"Elschool-API/internal/domain/models"
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+type Collector interface {
+	Collect(ctx context.Context) (report models.GCReport, err error)
+}
+
+// App runs the collector every interval until it is stopped. Runs never
+// overlap, a run which outlasts the interval delays the next one.
+type App struct {
+	log       *slog.Logger
+	collector Collector
+	interval  time.Duration
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+	once   sync.Once
+}
+
+func New(log *slog.Logger, collector Collector, interval time.Duration) *App {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &App{
+		log:       log,
+		collector: collector,
+		interval:  interval,
+		ctx:       ctx,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+}
+
+// Run blocks until Stop is called.
+func (a *App) Run() {
+	const op = "gcapp.Run"
+
+	defer close(a.done)
+
+	a.log.With(slog.String("op", op)).Info("starting gc job", slog.Duration("interval", a.interval))
+
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.ctx.Done():
+			return
+		case <-ticker.C:
+			// Failures are logged and counted by the collector, the next
+			// run starts over.
+			_, _ = a.collector.Collect(a.ctx)
+		}
+	}
+}
+
+// Stop cancels the current run and waits for it to end.
+func (a *App) Stop() {
+	const op = "gcapp.Stop"
+
+	a.once.Do(func() {
+		a.log.With(slog.String("op", op)).Info("stopping gc job")
+
+		a.cancel()
+		<-a.done
+	})
+}
@@ -1,33 +1,118 @@
 package grpcapp
 
 import (
+	"Elschool-API/internal/config"
+	apikeysgrpc "Elschool-API/internal/grpc/apikeys"
+	"Elschool-API/internal/grpc/health"
+	"Elschool-API/internal/grpc/interceptors"
 	"Elschool-API/internal/grpc/marks"
 	studentgrpc "Elschool-API/internal/grpc/student"
 	"Elschool-API/internal/grpc/user"
+	"Elschool-API/internal/infra/metrics"
+	"context"
+	"errors"
 	"fmt"
+	apiv1 "github.com/Ilya-Repin/elschooler/protos/gen/api/api.v1"
+	"github.com/improbable-eng/grpc-web/go/grpcweb"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
 	"log/slog"
 	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	webReadHeaderTimeout = 10 * time.Second
+	webShutdownTimeout   = 10 * time.Second
+	gracefulStopTimeout  = 10 * time.Second
 )
 
 type App struct {
 	log        *slog.Logger
 	gRPCServer *grpc.Server
+	health     *healthgrpc.Checker
 	port       int
+	// webServer serves gRPC-Web to browsers, it is nil unless enabled.
+	webServer *http.Server
 }
 
-func New(log *slog.Logger, userService usergrpc.User, studentService studentgrpc.Student, marksService marksgrpc.Marks, port int) *App {
-	gRPCServer := grpc.NewServer()
+type APIKeys interface {
+	apikeysgrpc.APIKeys
+	interceptors.KeyAuthorizer
+}
+
+// New builds the gRPC server. Unless api keys are enabled, requests aren't
+// authorized and key management isn't exposed.
+func New(log *slog.Logger, metricsInfra *metrics.Metrics, userService usergrpc.User, quotaService usergrpc.Quota, studentService studentgrpc.Student, marksService marksgrpc.Marks, apiKeysService APIKeys, apiKeysEnabled bool, health *healthgrpc.Checker, schoolLocation *time.Location, cfg config.GRPCConfig) *App {
+	unary := []grpc.UnaryServerInterceptor{
+		interceptors.RequestIDUnary(),
+		interceptors.LoggingUnary(log),
+		interceptors.MetricsUnary(metricsInfra),
+		interceptors.RecoveryUnary(log, metricsInfra),
+	}
+	stream := []grpc.StreamServerInterceptor{
+		interceptors.RequestIDStream(),
+		interceptors.LoggingStream(log),
+		interceptors.MetricsStream(metricsInfra),
+		interceptors.RecoveryStream(log, metricsInfra),
+	}
+
+	if apiKeysEnabled {
+		unary = append(unary, interceptors.APIKeyUnary(log, apiKeysService))
+		stream = append(stream, interceptors.APIKeyStream(log, apiKeysService))
+	}
+
+	unary = append(unary, interceptors.ClientUnary(), interceptors.UserTokenUnary())
+	stream = append(stream, interceptors.ClientStream(), interceptors.UserTokenStream())
 
-	usergrpc.Register(gRPCServer, userService)
+	gRPCServer := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(stream...),
+	)
+
+	usergrpc.Register(gRPCServer, userService, quotaService)
 	studentgrpc.Register(gRPCServer, studentService)
-	marksgrpc.Register(gRPCServer, marksService)
+	marksgrpc.Register(gRPCServer, marksService, schoolLocation)
+
+	if apiKeysEnabled {
+		apikeysgrpc.Register(gRPCServer, apiKeysService)
+	}
+
+	// Marks are served from the stale cache while elschool is down, but
+	// neither students nor marks can be refreshed, so both report it.
+	health.SetDependencies("", healthgrpc.DependencyDB, healthgrpc.DependencyCache)
+	health.SetDependencies(apiv1.User_ServiceDesc.ServiceName, healthgrpc.DependencyDB)
+	health.SetDependencies(apiv1.Student_ServiceDesc.ServiceName, healthgrpc.DependencyDB, healthgrpc.DependencyCache, healthgrpc.DependencyElschool)
+	health.SetDependencies(apiv1.Marks_ServiceDesc.ServiceName, healthgrpc.DependencyDB, healthgrpc.DependencyCache, healthgrpc.DependencyElschool)
+	if apiKeysEnabled {
+		health.SetDependencies(apiv1.ApiKeys_ServiceDesc.ServiceName, healthgrpc.DependencyDB)
+	}
+	health.Register(gRPCServer)
+
+	if cfg.Reflection {
+		reflection.Register(gRPCServer)
+	}
 
-	return &App{
+	app := &App{
 		log:        log,
 		gRPCServer: gRPCServer,
-		port:       port,
+		health:     health,
+		port:       cfg.Port,
+	}
+
+	if cfg.Web.Enabled {
+		app.webServer = &http.Server{
+			Addr: fmt.Sprintf(":%d", cfg.Web.Port),
+			Handler: grpcweb.WrapServer(gRPCServer,
+				grpcweb.WithOriginFunc(allowOrigin(cfg.Web.AllowedOrigins)),
+			),
+			ReadHeaderTimeout: webReadHeaderTimeout,
+		}
 	}
+
+	return app
 }
 
 func (a *App) MustRun() {
@@ -51,6 +136,23 @@ func (a *App) Run() error {
 
 	log.Info("gRPC server started", slog.String("addr", l.Addr().String()))
 
+	if a.webServer != nil {
+		wl, err := net.Listen("tcp", a.webServer.Addr)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		log.Info("gRPC-Web server started", slog.String("addr", wl.Addr().String()))
+
+		go func() {
+			if err := a.webServer.Serve(wl); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Error("gRPC-Web server failed", "error", err)
+			}
+		}()
+	}
+
+	a.health.Start()
+
 	if err := a.gRPCServer.Serve(l); err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
@@ -61,7 +163,48 @@ func (a *App) Run() error {
 func (a *App) Stop() {
 	const op = "grpcapp.Stop"
 
-	a.log.With(slog.String("op", op)).Info("stopping gRPC server")
+	log := a.log.With(slog.String("op", op))
+
+	log.Info("stopping gRPC server")
 
-	a.gRPCServer.GracefulStop()
+	// Report not serving first, so balancers stop sending new requests
+	// while the in-flight ones are drained.
+	a.health.Shutdown()
+
+	if a.webServer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), webShutdownTimeout)
+		defer cancel()
+
+		if err := a.webServer.Shutdown(ctx); err != nil {
+			log.Error("failed to stop gRPC-Web server", "error", err)
+		}
+	}
+
+	// Marks event streams last until their clients go, so they are cut once
+	// the other calls had time to finish.
+	stopped := make(chan struct{})
+	go func() {
+		a.gRPCServer.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(gracefulStopTimeout):
+		a.gRPCServer.Stop()
+		<-stopped
+	}
+}
+
+// allowOrigin lets browsers call the API only from the configured origins,
+// "*" allows any origin.
+func allowOrigin(allowed []string) func(origin string) bool {
+	return func(origin string) bool {
+		for _, o := range allowed {
+			if o == "*" || strings.EqualFold(o, origin) {
+				return true
+			}
+		}
+		return false
+	}
 }
@@ -12,21 +12,62 @@ type Config struct {
 	InfraConfig   InfraConfig   `yaml:"infra"`
 	StorageConfig StorageConfig `yaml:"storage"`
 	CacheConfig   CacheConfig   `yaml:"cache"`
+	MarksConfig   MarksConfig   `yaml:"marks"`
+	EventsConfig  EventsConfig  `yaml:"events"`
+	UsersConfig   UsersConfig   `yaml:"users"`
 	GRPCConfig    GRPCConfig    `yaml:"grpc"`
+	GatewayConfig GatewayConfig `yaml:"gateway"`
 	MetricsConfig MetricsConfig `yaml:"metrics"`
+	SecretsConfig SecretsConfig `yaml:"secrets"`
+	APIKeysConfig APIKeysConfig `yaml:"api_keys"`
+	AdminConfig   AdminConfig   `yaml:"admin"`
+	GCConfig      GCConfig      `yaml:"gc"`
+	QuotasConfig  QuotasConfig  `yaml:"quotas"`
 }
 
 type GRPCConfig struct {
-	Port    int           `yaml:"port"`
-	Timeout time.Duration `yaml:"timeout"`
+	Port       int           `yaml:"port"`
+	Timeout    time.Duration `yaml:"timeout"`
+	Reflection bool          `yaml:"reflection"`
+	Health     HealthConfig  `yaml:"health"`
+	Web        GRPCWebConfig `yaml:"web"`
 }
 
+type GRPCWebConfig struct {
+	Enabled        bool     `yaml:"enabled"`
+	Port           int      `yaml:"port" env-default:"8081"`
+	AllowedOrigins []string `yaml:"allowed_origins" env:"ELSCHOOL_GRPC_WEB_ALLOWED_ORIGINS" env-separator:","`
+}
+
+type HealthConfig struct {
+	Interval time.Duration `yaml:"interval" env-default:"15s"`
+	Timeout  time.Duration `yaml:"timeout" env-default:"3s"`
+}
+
+type GatewayConfig struct {
+	Enabled bool `yaml:"enabled"`
+	Port    int  `yaml:"port" env-default:"8080"`
+}
+
+// InfraConfig points at Elschool. Url is the default instance, Instances are
+// further regional instances students may be bound to, host by name. A bare
+// host is reached over https, a url with a scheme as is, e.g. plain http to
+// cmd/fakeelschool.
 type InfraConfig struct {
-	Url string `yaml:"url"`
+	Url       string            `yaml:"url"`
+	Instances map[string]string `yaml:"instances"`
+	Breaker   BreakerConfig     `yaml:"breaker"`
+}
+
+type BreakerConfig struct {
+	FailureThreshold uint32        `yaml:"failure_threshold" env-default:"5"`
+	OpenTimeout      time.Duration `yaml:"open_timeout" env-default:"30s"`
+	HalfOpenRequests uint32        `yaml:"half_open_requests" env-default:"1"`
 }
 
 type StorageConfig struct {
-	Driver   string `yaml:"driver"`
+	Driver   string `yaml:"driver" env-default:"postgres"`
+	Path     string `yaml:"path"`
 	Host     string `yaml:"host"`
 	Port     int    `yaml:"port"`
 	Dbname   string `yaml:"dbname"`
@@ -36,9 +77,83 @@ type StorageConfig struct {
 }
 
 type CacheConfig struct {
-	Host string `yaml:"host"`
-	Port int    `yaml:"port"`
-	Base int    `yaml:"base"`
+	Driver         string        `yaml:"driver" env-default:"redis"`
+	Host           string        `yaml:"host"`
+	Port           int           `yaml:"port"`
+	Base           int           `yaml:"base"`
+	TokenTTL       time.Duration `yaml:"token_ttl" env-default:"72h"`
+	MarksTTL       time.Duration `yaml:"marks_ttl" env-default:"7s"`
+	StaleRetention time.Duration `yaml:"stale_retention" env-default:"720h"`
+	MaxEntries     int           `yaml:"max_entries" env-default:"100000"`
+	MaxMemoryMB    int           `yaml:"max_memory_mb" env-default:"64"`
+	L1             L1CacheConfig `yaml:"l1"`
+}
+
+type L1CacheConfig struct {
+	Enabled     bool          `yaml:"enabled"`
+	TTL         time.Duration `yaml:"ttl" env-default:"5s"`
+	MaxEntries  int           `yaml:"max_entries" env-default:"10000"`
+	MaxMemoryMB int           `yaml:"max_memory_mb" env-default:"16"`
+}
+
+type MarksConfig struct {
+	FreshTimeout   time.Duration `yaml:"fresh_timeout" env-default:"3s"`
+	RefreshTimeout time.Duration `yaml:"refresh_timeout" env-default:"1m"`
+	// SessionRefreshMargin is how long before its expiry a cached session is
+	// checked with the diary again. Until then it is trusted as is.
+	SessionRefreshMargin time.Duration `yaml:"session_refresh_margin" env-default:"5m"`
+	// SchoolTimezone resolves "today" and "yesterday" in day marks requests.
+	SchoolTimezone string `yaml:"school_timezone" env-default:"Asia/Yekaterinburg"`
+}
+
+type EventsConfig struct {
+	LogSize   int           `yaml:"log_size" env-default:"1000"`
+	Heartbeat time.Duration `yaml:"heartbeat" env-default:"15s"`
+	TicketTTL time.Duration `yaml:"ticket_ttl" env-default:"30s"`
+}
+
+type UsersConfig struct {
+	TokenTTL      time.Duration `yaml:"token_ttl"`
+	RotationGrace time.Duration `yaml:"rotation_grace" env-default:"10m"`
+}
+
+type SecretsConfig struct {
+	KeyringPath string `yaml:"keyring_path" env:"ELSCHOOL_KEYRING_PATH"`
+	MasterKeys  string `env:"ELSCHOOL_MASTER_KEYS"`
+	ActiveKey   string `env:"ELSCHOOL_ACTIVE_KEY"`
+	HMACKey     string `env:"ELSCHOOL_HMAC_KEY"`
+}
+
+type APIKeysConfig struct {
+	Enabled      bool   `yaml:"enabled"`
+	BootstrapKey string `env:"ELSCHOOL_BOOTSTRAP_API_KEY"`
+}
+
+// AdminConfig enables the admin service on its own port. Tokens are keyed by
+// operator name, ELSCHOOL_ADMIN_TOKENS is "name:token,name:token".
+type AdminConfig struct {
+	Enabled bool              `yaml:"enabled"`
+	Port    int               `yaml:"port" env-default:"44045"`
+	Tokens  map[string]string `env:"ELSCHOOL_ADMIN_TOKENS" env-separator:","`
+}
+
+// GCConfig schedules removal of students nobody has and of cache entries
+// left for them. Students unused for UnusedFor are removed too, unless it is
+// zero. In DryRun mode everything is counted, but nothing is removed.
+type GCConfig struct {
+	Enabled   bool          `yaml:"enabled"`
+	Interval  time.Duration `yaml:"interval" env-default:"1h"`
+	UnusedFor time.Duration `yaml:"unused_for"`
+	DryRun    bool          `yaml:"dry_run"`
+	BatchSize int           `yaml:"batch_size" env-default:"100"`
+}
+
+// QuotasConfig limits what a single user may do. Zero, as well as a missing
+// setting, means no limit.
+type QuotasConfig struct {
+	StudentsPerUser int `yaml:"students_per_user"`
+	UsersPerStudent int `yaml:"users_per_student"`
+	MarksPerMinute  int `yaml:"marks_per_minute"`
 }
 
 type MetricsConfig struct {
@@ -0,0 +1,95 @@
+package models
+
+import "time"
+
+// UserInfo is what operators see about a user.
+type UserInfo struct {
+	ID             string
+	Service        string
+	CreatedAt      time.Time
+	DisabledAt     time.Time
+	DisabledReason string
+	Students       int
+	ActiveTokens   int
+}
+
+// StudentInfo is what operators see about a student, credentials are never
+// exposed.
+type StudentInfo struct {
+	ID        string
+	Encrypted bool
+	KeyID     string
+	Users     int
+}
+
+type Relation struct {
+	UserID    string
+	StudentID string
+	CreatedAt time.Time
+}
+
+// RelationFilter selects relations of the user, of the student or both.
+// Relations are ordered by creation, Offset skips that many of them.
+type RelationFilter struct {
+	UserID    string
+	StudentID string
+	Limit     int
+	Offset    int
+}
+
+type Stats struct {
+	Users         int64
+	DisabledUsers int64
+	Students      int64
+	Relations     int64
+	Growth        []GrowthPoint
+}
+
+// GrowthPoint counts users and relations created on the day, Date is
+// formatted as 2006-01-02.
+type GrowthPoint struct {
+	Date      string
+	Users     int64
+	Relations int64
+}
+
+// AuditEntry records an action taken on behalf of an actor, an operator or
+// a user. UserID and StudentID are set when the action affected them.
+type AuditEntry struct {
+	ID        int64
+	Actor     string
+	Action    string
+	Target    string
+	UserID    string
+	StudentID string
+	// Service is the client service the request came from.
+	Service   string
+	Status    string
+	RequestID string
+	CreatedAt time.Time
+}
+
+// AuditFilter selects entries of the user, of the student or both, newest
+// first. Only entries with ids below Before are returned, unless it is zero.
+type AuditFilter struct {
+	UserID    string
+	StudentID string
+	Before    int64
+	Limit     int
+}
+
+const (
+	AuditRegUser       = "reg_user"
+	AuditAddStudent    = "add_student"
+	AuditUpdateStudent = "update_student"
+	AuditDeleteStudent = "delete_student"
+)
+
+const (
+	AuditStatusOk              = "ok"
+	AuditStatusUserNotFound    = "user_not_found"
+	AuditStatusStudentNotFound = "student_not_found"
+	AuditStatusUnavailable     = "unavailable"
+	AuditStatusQuotaExceeded   = "quota_exceeded"
+	AuditStatusFailed          = "failed"
+)
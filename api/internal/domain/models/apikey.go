@@ -0,0 +1,29 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+const (
+	ScopeUsersCreate   = "users:create"
+	ScopeUsersWrite    = "users:write"
+	ScopeUsersRead     = "users:read"
+	ScopeStudentsWrite = "students:write"
+	ScopeMarksRead     = "marks:read"
+	ScopeKeysAdmin     = "keys:admin"
+)
+
+var Scopes = []string{ScopeUsersCreate, ScopeUsersWrite, ScopeUsersRead, ScopeStudentsWrite, ScopeMarksRead, ScopeKeysAdmin}
+
+type APIKey struct {
+	ID        string
+	Service   string
+	Scopes    []string
+	CreatedAt time.Time
+	RevokedAt time.Time
+}
+
+func (k APIKey) HasScope(scope string) bool {
+	return slices.Contains(k.Scopes, scope)
+}
@@ -0,0 +1,16 @@
+package models
+
+// DiaryCapabilities tells which marks a diary provider can fetch.
+type DiaryCapabilities struct {
+	DayMarks     bool
+	AverageMarks bool
+	FinalMarks   bool
+}
+
+// DiaryProvider describes a school e-diary system students can be bound to.
+// The default instance goes first.
+type DiaryProvider struct {
+	Name         string
+	Instances    []string
+	Capabilities DiaryCapabilities
+}
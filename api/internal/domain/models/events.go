@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+const (
+	MarksTypeDay     = "day"
+	MarksTypeAverage = "average"
+	MarksTypeFinal   = "final"
+)
+
+// MarksEvent tells that marks of the student changed since they were
+// observed last time. Exactly one of Day, Average and Final is set.
+type MarksEvent struct {
+	ID        string
+	StudentID string
+	Type      string
+	Subjects  []string
+	Day       *DayMarks
+	Average   *AverageMarks
+	Final     *FinalMarks
+	CreatedAt time.Time
+}
+
+// MarksSubscription delivers the events missed since the last seen one and
+// then the new ones, until Events is closed. Reset means the missed events
+// are no longer known, so the client has to reload marks.
+type MarksSubscription struct {
+	Backlog []MarksEvent
+	Events  <-chan MarksEvent
+	Reset   bool
+}
+
+// StreamTicket lets the user open one marks events stream of the student
+// until it expires.
+type StreamTicket struct {
+	ID        string
+	UserID    string
+	StudentID string
+	ExpiresAt time.Time
+}
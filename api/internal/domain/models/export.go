@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// UserExport is everything stored about a user. Credentials of the students
+// are never exported.
+type UserExport struct {
+	User     UserInfo
+	Tokens   []TokenRecord
+	Students []LinkedStudent
+	Audit    []AuditEntry
+}
+
+// TokenRecord describes a user token, the token itself isn't stored.
+type TokenRecord struct {
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	RevokedAt time.Time
+}
+
+type LinkedStudent struct {
+	ID         string
+	LinkedAt   time.Time
+	LastUsedAt time.Time
+	LastMarks  LastMarks
+}
+
+// LastMarks are the marks of a student last fetched from the diary. They are
+// kept for the stale retention to be served while the diary is unavailable.
+type LastMarks struct {
+	Day     []DayMarks
+	Average []AverageMarks
+	Final   *FinalMarks
+}
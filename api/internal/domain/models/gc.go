@@ -0,0 +1,11 @@
+package models
+
+// GCReport tells what a garbage collection run removed, or would have
+// removed in dry-run mode.
+type GCReport struct {
+	Orphaned int
+	Unused   int
+	// Cache counts students whose cache entries outlived them.
+	Cache  int
+	DryRun bool
+}
@@ -1,19 +1,39 @@
 package models
 
+import "time"
+
 type DayMarks struct {
 	Marks     map[string][]int32
 	WorstMark int32
 	Date      string
+	FetchedAt time.Time
+	Stale     bool
+	Warnings  []ParseWarning
 }
 
 type AverageMarks struct {
 	Marks     map[string]string
 	WorstMark string
 	Period    int32
+	FetchedAt time.Time
+	Stale     bool
+	Warnings  []ParseWarning
 }
 
 type FinalMarks struct {
 	Marks     map[string][]int32
 	WorstMark int32
+	FetchedAt time.Time
+	Stale     bool
+	Warnings  []ParseWarning
 	//year int
 }
+
+// ParseWarning is a part of a diary page that couldn't be read. Marks are
+// returned without it.
+type ParseWarning struct {
+	Selector string
+	Row      int
+	Raw      string
+	Reason   string
+}
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+const (
+	QuotaStudentsPerUser = "students_per_user"
+	QuotaUsersPerStudent = "users_per_student"
+	QuotaMarksPerMinute  = "marks_per_minute"
+)
+
+// QuotaLimits bounds what a single user may do. Zero means no limit.
+type QuotaLimits struct {
+	StudentsPerUser int
+	UsersPerStudent int
+	MarksPerMinute  int
+}
+
+// QuotaUsage is what the user has used of its quotas. MarksResetAt is when
+// the current marks window ends.
+type QuotaUsage struct {
+	Limits         QuotaLimits
+	Students       int
+	MarksRequests  int
+	MarksResetAt   time.Time
+	LinkedStudents []StudentUsers
+}
+
+// StudentUsers tells how many users share the student.
+type StudentUsers struct {
+	StudentID string
+	Users     int
+}
@@ -1,7 +1,19 @@
 package models
 
+const (
+	// DefaultProvider is the diary provider students are bound to unless
+	// another one is asked for. Students added before providers were
+	// introduced are bound to it.
+	DefaultProvider = "elschool"
+	// DefaultInstance is the Elschool instance at the configured infra url.
+	// Students added before instances were introduced are bound to it.
+	DefaultInstance = "default"
+)
+
 type Student struct {
 	Token    string
+	Provider string
+	Instance string
 	Login    string
 	Password string
 }
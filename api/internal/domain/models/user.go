@@ -0,0 +1,12 @@
+package models
+
+// UserDeletion tells what was deleted together with the user.
+type UserDeletion struct {
+	Tokens    int
+	Relations int
+	// Students lists the students deleted because no other user had them.
+	Students []string
+	// CachesInvalidated counts the deleted students whose cached jwt and
+	// marks were dropped.
+	CachesInvalidated int
+}
@@ -0,0 +1,372 @@
+// Package fakeelschool emulates the part of Elschool the api talks to, so
+// it can be developed and tested without real accounts.
+package fakeelschool
+
+import (
+	"Elschool-API/internal/infra/auth"
+	"Elschool-API/internal/infra/fetcher"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultPupilID = 1111111
+
+	detailsPath = "/users/diaries/details"
+	// diaryQuery mimics the ids Elschool puts into diary links, only the
+	// pupil id differs between students.
+	diaryQuery = "rooId=11&instituteId=111&departmentId=111111&pupilId="
+
+	logonPage         = `<html><head><title>Elschool</title></head><body><form method="post" action="/Logon/Index"><input name="login"><input name="password" type="password"></form></body></html>`
+	privateOfficePage = `<html><head><title>PrivateOffice</title></head><body>PrivateOffice</body></html>`
+	emptyPage         = `<html><head><title>Elschool</title></head><body></body></html>`
+)
+
+type session struct {
+	login     string
+	expiresAt time.Time
+}
+
+// Pages replace the diary pages of a login, as if its marks changed. Empty
+// pages are left as they are.
+type Pages struct {
+	Grades  string
+	Results string
+}
+
+// Outage makes the diary pages of a login answer late, with the status
+// unless it is zero. Logon still works, as it does when only the diary is
+// overloaded.
+type Outage struct {
+	Status int
+	Delay  time.Duration
+}
+
+// Server serves the logon, the private office and the diary pages of the
+// fixture students. Sessions are kept in memory.
+type Server struct {
+	log      *slog.Logger
+	students map[string]student
+	handler  http.Handler
+
+	mu       sync.Mutex
+	sessions map[string]session
+	outages  map[string]Outage
+	pages    map[string]Pages
+}
+
+func New(log *slog.Logger, fixtures Fixtures) (*Server, error) {
+	const op = "fakeelschool.New"
+
+	s := &Server{log: log, students: make(map[string]student, len(fixtures.Students)), sessions: make(map[string]session), outages: make(map[string]Outage), pages: make(map[string]Pages)}
+
+	for _, fixture := range fixtures.Students {
+		if fixture.PupilID == 0 {
+			fixture.PupilID = defaultPupilID
+		}
+
+		stud, err := loadStudent(fixture)
+		if err != nil {
+			return nil, fmt.Errorf("%s: student %s: %w", op, fixture.Login, err)
+		}
+		s.students[fixture.Login] = stud
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", s.root)
+	mux.HandleFunc("GET "+auth.LogonIndex, s.logonPage)
+	mux.HandleFunc("POST "+auth.LogonIndex, s.logon)
+	mux.HandleFunc("GET "+auth.PrivateOffice, s.privateOffice)
+	mux.HandleFunc("GET "+fetcher.Diaries, s.diaries)
+	mux.HandleFunc("GET "+fetcher.Grades, s.page(func(stud student) string { return stud.gradesPage }))
+	mux.HandleFunc("GET "+fetcher.Results, s.page(func(stud student) string { return stud.resultsPage }))
+	mux.HandleFunc("POST /fake/sessions:expire", s.expireSessions)
+	mux.HandleFunc("POST /fake/outages:start", s.startOutage)
+	mux.HandleFunc("POST /fake/outages:end", s.endOutage)
+	mux.HandleFunc("PUT /fake/pages/{page}", s.setPage)
+	s.handler = mux
+
+	return s, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.log.Debug("request", slog.String("method", r.Method), slog.String("path", r.URL.Path))
+	s.handler.ServeHTTP(w, r)
+}
+
+func (s *Server) root(w http.ResponseWriter, r *http.Request) {
+	writePage(w, http.StatusOK, emptyPage)
+}
+
+func (s *Server) logonPage(w http.ResponseWriter, r *http.Request) {
+	writePage(w, http.StatusOK, logonPage)
+}
+
+// logon issues a session cookie and redirects to the private office, as
+// Elschool does. Wrong credentials get the logon page again.
+func (s *Server) logon(w http.ResponseWriter, r *http.Request) {
+	stud, ok := s.student(r.PostFormValue("login"), r.PostFormValue("password"))
+	if !ok {
+		s.log.Info("logon failed", slog.String("login", r.PostFormValue("login")))
+		writePage(w, http.StatusOK, logonPage)
+		return
+	}
+
+	token, err := newToken()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	sess := session{login: r.PostFormValue("login")}
+	if stud.SessionTTL > 0 {
+		sess.expiresAt = time.Now().Add(stud.SessionTTL)
+	}
+
+	s.mu.Lock()
+	s.sessions[token] = sess
+	s.mu.Unlock()
+
+	http.SetCookie(w, &http.Cookie{Name: auth.JwtCookieName, Value: token, Path: "/", HttpOnly: true})
+	redirect(w, auth.PrivateOffice)
+}
+
+func (s *Server) privateOffice(w http.ResponseWriter, r *http.Request) {
+	if _, _, ok := s.session(r); !ok {
+		writePage(w, http.StatusForbidden, logonPage)
+		return
+	}
+	writePage(w, http.StatusOK, privateOfficePage)
+}
+
+// diaries redirects to the diary of the student, the link carries the ids
+// the diary pages are asked for with.
+func (s *Server) diaries(w http.ResponseWriter, r *http.Request) {
+	_, stud, ok := s.session(r)
+	if !ok {
+		writePage(w, http.StatusForbidden, logonPage)
+		return
+	}
+	redirect(w, detailsPath+"?"+diaryQuery+strconv.Itoa(stud.PupilID))
+}
+
+func (s *Server) page(content func(stud student) string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		login, stud, ok := s.session(r)
+		if !ok {
+			writePage(w, http.StatusForbidden, logonPage)
+			return
+		}
+
+		s.mu.Lock()
+		outage, down := s.outages[login]
+		pages := s.pages[login]
+		s.mu.Unlock()
+
+		if down {
+			select {
+			case <-time.After(outage.Delay):
+			case <-r.Context().Done():
+				return
+			}
+			if outage.Status != 0 {
+				writePage(w, outage.Status, emptyPage)
+				return
+			}
+		}
+
+		if r.URL.Query().Get("pupilId") != strconv.Itoa(stud.PupilID) {
+			writePage(w, http.StatusNotFound, emptyPage)
+			return
+		}
+
+		if pages.Grades != "" {
+			stud.gradesPage = pages.Grades
+		}
+		if pages.Results != "" {
+			stud.resultsPage = pages.Results
+		}
+
+		writePage(w, http.StatusOK, content(stud))
+	}
+}
+
+// expireSessions ends the sessions of the login, or all of them without
+// one, so the api has to log on again.
+func (s *Server) expireSessions(w http.ResponseWriter, r *http.Request) {
+	login := r.URL.Query().Get("login")
+
+	s.mu.Lock()
+	expired := 0
+	for token, sess := range s.sessions {
+		if login == "" || sess.login == login {
+			delete(s.sessions, token)
+			expired++
+		}
+	}
+	s.mu.Unlock()
+
+	s.log.Info("sessions expired", slog.String("login", login), slog.Int("count", expired))
+	fmt.Fprintf(w, "%d\n", expired)
+}
+
+// StartOutage makes the diary pages of the login fail or answer late until
+// the outage ends.
+func (s *Server) StartOutage(login string, outage Outage) {
+	s.mu.Lock()
+	s.outages[login] = outage
+	s.mu.Unlock()
+
+	s.log.Info("outage started", slog.String("login", login), slog.Int("status", outage.Status), slog.Duration("delay", outage.Delay))
+}
+
+func (s *Server) EndOutage(login string) {
+	s.mu.Lock()
+	delete(s.outages, login)
+	s.mu.Unlock()
+
+	s.log.Info("outage ended", slog.String("login", login))
+}
+
+// startOutage takes the outage from the status and delay parameters, e.g.
+// ?login=student&status=503&delay=5s.
+func (s *Server) startOutage(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	var outage Outage
+	var err error
+	if status := query.Get("status"); status != "" {
+		if outage.Status, err = strconv.Atoi(status); err != nil {
+			http.Error(w, "malformed status: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if delay := query.Get("delay"); delay != "" {
+		if outage.Delay, err = time.ParseDuration(delay); err != nil {
+			http.Error(w, "malformed delay: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	s.StartOutage(query.Get("login"), outage)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) endOutage(w http.ResponseWriter, r *http.Request) {
+	s.EndOutage(r.URL.Query().Get("login"))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SetPages replaces the diary pages of the login, the fixture ones are
+// served to other logins still.
+func (s *Server) SetPages(login string, pages Pages) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := s.pages[login]
+	if pages.Grades != "" {
+		current.Grades = pages.Grades
+	}
+	if pages.Results != "" {
+		current.Results = pages.Results
+	}
+	s.pages[login] = current
+
+	s.log.Info("pages set", slog.String("login", login))
+}
+
+// setPage replaces the grades or the results page of the login with the
+// body, e.g. PUT /fake/pages/results?login=student.
+func (s *Server) setPage(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var pages Pages
+	switch r.PathValue("page") {
+	case "grades":
+		pages.Grades = string(body)
+	case "results":
+		pages.Results = string(body)
+	default:
+		http.Error(w, "unknown page, grades or results expected", http.StatusNotFound)
+		return
+	}
+
+	s.SetPages(r.URL.Query().Get("login"), pages)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) student(login, password string) (student, bool) {
+	if login == "" || password == "" {
+		return student{}, false
+	}
+
+	stud, ok := s.students[login]
+	if !ok {
+		stud, ok = s.students[AnyLogin]
+	}
+	if !ok {
+		return student{}, false
+	}
+
+	return stud, stud.Password == AnyLogin || stud.Password == password
+}
+
+// session returns the login the session was opened with along with its
+// student, which may be the one of AnyLogin.
+func (s *Server) session(r *http.Request) (login string, stud student, ok bool) {
+	cookie, err := r.Cookie(auth.JwtCookieName)
+	if err != nil {
+		return "", student{}, false
+	}
+
+	s.mu.Lock()
+	sess, ok := s.sessions[cookie.Value]
+	if ok && !sess.expiresAt.IsZero() && time.Now().After(sess.expiresAt) {
+		delete(s.sessions, cookie.Value)
+		ok = false
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		return "", student{}, false
+	}
+
+	stud, ok = s.students[sess.login]
+	if !ok {
+		stud, ok = s.students[AnyLogin]
+	}
+	return sess.login, stud, ok
+}
+
+// redirect answers like the ASP.NET behind Elschool, the clients look for
+// the link in the body rather than at the location.
+func redirect(w http.ResponseWriter, location string) {
+	w.Header().Set("Location", location)
+	writePage(w, http.StatusFound, fmt.Sprintf("<html><head><title>Object moved</title></head><body>\n<h2>Object moved to <a href=\"%s\">here</a>.</h2>\n</body></html>", html.EscapeString(location)))
+}
+
+func writePage(w http.ResponseWriter, status int, page string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	fmt.Fprint(w, page)
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
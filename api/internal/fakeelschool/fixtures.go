@@ -0,0 +1,117 @@
+package fakeelschool
+
+import (
+	"fmt"
+	"github.com/ilyakaznacheev/cleanenv"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// AnyLogin as a login matches every login no other student has. As a
+// password it matches every non-empty password.
+const AnyLogin = "*"
+
+// Fixtures are the students the fake server knows.
+type Fixtures struct {
+	Students []StudentFixture `yaml:"students"`
+}
+
+// StudentFixture is a student with the pages served to it. Page paths are
+// relative to the fixtures file.
+type StudentFixture struct {
+	Login    string `yaml:"login"`
+	Password string `yaml:"password"`
+	PupilID  int    `yaml:"pupil_id"`
+	Grades   string `yaml:"grades"`
+	Results  string `yaml:"results"`
+	// SessionTTL makes sessions of the student expire, like Elschool JWTs
+	// do. Zero means they never do.
+	SessionTTL time.Duration `yaml:"session_ttl"`
+	// MarkupChanged serves the pages with every class renamed, like after
+	// an Elschool redesign.
+	MarkupChanged bool `yaml:"markup_changed"`
+}
+
+type student struct {
+	StudentFixture
+	gradesPage  string
+	resultsPage string
+}
+
+// LoadFixtures reads the fixtures file along with the pages it refers to.
+func LoadFixtures(path string) (*Fixtures, error) {
+	const op = "fakeelschool.LoadFixtures"
+
+	var fixtures Fixtures
+	if err := cleanenv.ReadConfig(path, &fixtures); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	dir := filepath.Dir(path)
+	for i := range fixtures.Students {
+		fixture := &fixtures.Students[i]
+		if fixture.Login == "" || fixture.Password == "" {
+			return nil, fmt.Errorf("%s: student %d: login and password required", op, i)
+		}
+		if fixture.PupilID == 0 {
+			fixture.PupilID = defaultPupilID
+		}
+		fixture.Grades = resolve(dir, fixture.Grades)
+		fixture.Results = resolve(dir, fixture.Results)
+	}
+
+	return &fixtures, nil
+}
+
+func resolve(dir, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+func loadStudent(fixture StudentFixture) (student, error) {
+	grades, err := readPage(fixture.Grades)
+	if err != nil {
+		return student{}, err
+	}
+
+	results, err := readPage(fixture.Results)
+	if err != nil {
+		return student{}, err
+	}
+
+	if fixture.MarkupChanged {
+		grades, results = changeMarkup(grades), changeMarkup(results)
+	}
+
+	return student{StudentFixture: fixture, gradesPage: grades, resultsPage: results}, nil
+}
+
+// readPage reads the page, a student without one gets an empty diary.
+func readPage(path string) (string, error) {
+	if path == "" {
+		return emptyPage, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+var classAttr = regexp.MustCompile(`class="([^"]*)"`)
+
+func changeMarkup(page string) string {
+	return classAttr.ReplaceAllStringFunc(page, func(attr string) string {
+		classes := strings.Fields(classAttr.FindStringSubmatch(attr)[1])
+		for i, class := range classes {
+			classes[i] = "v2-" + class
+		}
+		return `class="` + strings.Join(classes, " ") + `"`
+	})
+}
@@ -0,0 +1,341 @@
+package admingrpc
+
+import (
+	"Elschool-API/internal/domain/models"
+	"Elschool-API/internal/service"
+	"context"
+	"errors"
+	apiv1 "github.com/Ilya-Repin/elschooler/protos/gen/api/api.v1"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"strconv"
+)
+
+const (
+	defaultPageSize = 100
+	maxPageSize     = 1000
+)
+
+type Admin interface {
+	GetUser(ctx context.Context, userID string) (user models.UserInfo, err error)
+	GetUserByToken(ctx context.Context, userToken string) (user models.UserInfo, err error)
+	GetStudent(ctx context.Context, studentToken string) (student models.StudentInfo, err error)
+	GetStudentByLogin(ctx context.Context, login string) (student models.StudentInfo, err error)
+	ListRelations(ctx context.Context, filter models.RelationFilter) (relations []models.Relation, err error)
+	Stats(ctx context.Context, days int) (stats models.Stats, err error)
+	InvalidateStudentCache(ctx context.Context, studentToken string) (err error)
+	DisableUser(ctx context.Context, userID, reason string) (user models.UserInfo, err error)
+	EnableUser(ctx context.Context, userID string) (user models.UserInfo, err error)
+	ListAuditLog(ctx context.Context, filter models.AuditFilter) (entries []models.AuditEntry, err error)
+}
+
+type serverAPI struct {
+	apiv1.UnimplementedAdminServer
+	admin Admin
+}
+
+func Register(gRPC *grpc.Server, admin Admin) {
+	apiv1.RegisterAdminServer(gRPC, &serverAPI{admin: admin})
+}
+
+func (s *serverAPI) GetUser(ctx context.Context, req *apiv1.GetUserRequest) (*apiv1.GetUserResponse, error) {
+	var user models.UserInfo
+	var err error
+
+	switch {
+	case req.GetUserId() != "" && req.GetUserToken() != "":
+		return nil, status.Error(codes.InvalidArgument, "either user id or user token required, not both")
+	case req.GetUserId() != "":
+		if err := validateUUID(req.GetUserId(), "user id"); err != nil {
+			return nil, err
+		}
+		user, err = s.admin.GetUser(ctx, req.GetUserId())
+	case req.GetUserToken() != "":
+		if err := validateUUID(req.GetUserToken(), "user token"); err != nil {
+			return nil, err
+		}
+		user, err = s.admin.GetUserByToken(ctx, req.GetUserToken())
+	default:
+		return nil, status.Error(codes.InvalidArgument, "user id or user token required")
+	}
+
+	if err != nil {
+		return nil, userError(err, "get user error")
+	}
+
+	return &apiv1.GetUserResponse{User: userToProto(user)}, nil
+}
+
+func (s *serverAPI) GetStudent(ctx context.Context, req *apiv1.GetStudentRequest) (*apiv1.GetStudentResponse, error) {
+	var student models.StudentInfo
+	var err error
+
+	switch {
+	case req.GetStudentId() != "" && req.GetLogin() != "":
+		return nil, status.Error(codes.InvalidArgument, "either student id or login required, not both")
+	case req.GetStudentId() != "":
+		if err := validateUUID(req.GetStudentId(), "student id"); err != nil {
+			return nil, err
+		}
+		student, err = s.admin.GetStudent(ctx, req.GetStudentId())
+	case req.GetLogin() != "":
+		student, err = s.admin.GetStudentByLogin(ctx, req.GetLogin())
+	default:
+		return nil, status.Error(codes.InvalidArgument, "student id or login required")
+	}
+
+	if err != nil {
+		return nil, studentError(err, "get student error")
+	}
+
+	return &apiv1.GetStudentResponse{Student: &apiv1.AdminStudent{
+		Id:        student.ID,
+		Encrypted: student.Encrypted,
+		KeyId:     student.KeyID,
+		Users:     int32(student.Users),
+	}}, nil
+}
+
+func (s *serverAPI) ListRelations(ctx context.Context, req *apiv1.ListRelationsRequest) (*apiv1.ListRelationsResponse, error) {
+	if err := validateFilter(req.GetUserId(), req.GetStudentId()); err != nil {
+		return nil, err
+	}
+
+	pageSize, err := validatePageSize(req.GetPageSize())
+	if err != nil {
+		return nil, err
+	}
+
+	var offset int
+	if req.GetPageToken() != "" {
+		if offset, err = strconv.Atoi(req.GetPageToken()); err != nil || offset < 0 {
+			return nil, status.Error(codes.InvalidArgument, "wrong page token")
+		}
+	}
+
+	// One more relation is requested to know whether there is a next page.
+	relations, err := s.admin.ListRelations(ctx, models.RelationFilter{
+		UserID:    req.GetUserId(),
+		StudentID: req.GetStudentId(),
+		Limit:     pageSize + 1,
+		Offset:    offset,
+	})
+
+	if err != nil {
+		return nil, status.Error(codes.Internal, "list relations error")
+	}
+
+	resp := &apiv1.ListRelationsResponse{}
+	if len(relations) > pageSize {
+		relations = relations[:pageSize]
+		resp.NextPageToken = strconv.Itoa(offset + pageSize)
+	}
+
+	resp.Relations = make([]*apiv1.Relation, 0, len(relations))
+	for _, relation := range relations {
+		resp.Relations = append(resp.Relations, &apiv1.Relation{
+			UserId:    relation.UserID,
+			StudentId: relation.StudentID,
+			CreatedAt: timestamppb.New(relation.CreatedAt),
+		})
+	}
+
+	return resp, nil
+}
+
+func (s *serverAPI) ListAuditLog(ctx context.Context, req *apiv1.ListAuditLogRequest) (*apiv1.ListAuditLogResponse, error) {
+	if err := validateFilter(req.GetUserId(), req.GetStudentId()); err != nil {
+		return nil, err
+	}
+
+	pageSize, err := validatePageSize(req.GetPageSize())
+	if err != nil {
+		return nil, err
+	}
+
+	// Entries are only appended, so pages are keyed by the last id seen and
+	// don't shift while new entries come.
+	var before int64
+	if req.GetPageToken() != "" {
+		if before, err = strconv.ParseInt(req.GetPageToken(), 10, 64); err != nil || before <= 0 {
+			return nil, status.Error(codes.InvalidArgument, "wrong page token")
+		}
+	}
+
+	// One more entry is requested to know whether there is a next page.
+	entries, err := s.admin.ListAuditLog(ctx, models.AuditFilter{
+		UserID:    req.GetUserId(),
+		StudentID: req.GetStudentId(),
+		Before:    before,
+		Limit:     pageSize + 1,
+	})
+
+	if err != nil {
+		return nil, status.Error(codes.Internal, "list audit log error")
+	}
+
+	resp := &apiv1.ListAuditLogResponse{}
+	if len(entries) > pageSize {
+		entries = entries[:pageSize]
+		resp.NextPageToken = strconv.FormatInt(entries[pageSize-1].ID, 10)
+	}
+
+	resp.Entries = make([]*apiv1.AuditEntry, 0, len(entries))
+	for _, entry := range entries {
+		resp.Entries = append(resp.Entries, &apiv1.AuditEntry{
+			Id:        entry.ID,
+			Actor:     entry.Actor,
+			Action:    entry.Action,
+			Target:    entry.Target,
+			UserId:    entry.UserID,
+			StudentId: entry.StudentID,
+			Service:   entry.Service,
+			Status:    entry.Status,
+			RequestId: entry.RequestID,
+			CreatedAt: timestamppb.New(entry.CreatedAt),
+		})
+	}
+
+	return resp, nil
+}
+
+func (s *serverAPI) GetStats(ctx context.Context, req *apiv1.GetStatsRequest) (*apiv1.GetStatsResponse, error) {
+	if req.GetDays() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "days must not be negative")
+	}
+
+	stats, err := s.admin.Stats(ctx, int(req.GetDays()))
+
+	if err != nil {
+		return nil, status.Error(codes.Internal, "get stats error")
+	}
+
+	resp := &apiv1.GetStatsResponse{
+		Users:         stats.Users,
+		DisabledUsers: stats.DisabledUsers,
+		Students:      stats.Students,
+		Relations:     stats.Relations,
+		Growth:        make([]*apiv1.GrowthPoint, 0, len(stats.Growth)),
+	}
+	for _, point := range stats.Growth {
+		resp.Growth = append(resp.Growth, &apiv1.GrowthPoint{Date: point.Date, Users: point.Users, Relations: point.Relations})
+	}
+
+	return resp, nil
+}
+
+func (s *serverAPI) InvalidateStudentCache(ctx context.Context, req *apiv1.InvalidateStudentCacheRequest) (*apiv1.InvalidateStudentCacheResponse, error) {
+	if err := validateUUID(req.GetStudentId(), "student id"); err != nil {
+		return nil, err
+	}
+
+	err := s.admin.InvalidateStudentCache(ctx, req.GetStudentId())
+
+	if err != nil {
+		return nil, studentError(err, "invalidate student cache error")
+	}
+
+	return &apiv1.InvalidateStudentCacheResponse{Success: true}, nil
+}
+
+func (s *serverAPI) DisableUser(ctx context.Context, req *apiv1.DisableUserRequest) (*apiv1.DisableUserResponse, error) {
+	if err := validateUUID(req.GetUserId(), "user id"); err != nil {
+		return nil, err
+	}
+
+	if req.GetReason() == "" {
+		return nil, status.Error(codes.InvalidArgument, "reason required")
+	}
+
+	user, err := s.admin.DisableUser(ctx, req.GetUserId(), req.GetReason())
+
+	if err != nil {
+		return nil, userError(err, "disable user error")
+	}
+
+	return &apiv1.DisableUserResponse{User: userToProto(user)}, nil
+}
+
+func (s *serverAPI) EnableUser(ctx context.Context, req *apiv1.EnableUserRequest) (*apiv1.EnableUserResponse, error) {
+	if err := validateUUID(req.GetUserId(), "user id"); err != nil {
+		return nil, err
+	}
+
+	user, err := s.admin.EnableUser(ctx, req.GetUserId())
+
+	if err != nil {
+		return nil, userError(err, "enable user error")
+	}
+
+	return &apiv1.EnableUserResponse{User: userToProto(user)}, nil
+}
+
+func userToProto(user models.UserInfo) *apiv1.AdminUser {
+	resp := &apiv1.AdminUser{
+		Id:             user.ID,
+		Service:        user.Service,
+		CreatedAt:      timestamppb.New(user.CreatedAt),
+		DisabledReason: user.DisabledReason,
+		Students:       int32(user.Students),
+		ActiveTokens:   int32(user.ActiveTokens),
+	}
+	if !user.DisabledAt.IsZero() {
+		resp.DisabledAt = timestamppb.New(user.DisabledAt)
+	}
+	return resp
+}
+
+func userError(err error, msg string) error {
+	if errors.Is(err, service.ErrUserNotFound) {
+		return status.Error(codes.NotFound, "no such user")
+	}
+
+	return status.Error(codes.Internal, msg)
+}
+
+func studentError(err error, msg string) error {
+	if errors.Is(err, service.ErrStudentNotFound) {
+		return status.Error(codes.NotFound, "no such student")
+	}
+
+	return status.Error(codes.Internal, msg)
+}
+
+func validateUUID(id, fieldName string) error {
+	if id == "" {
+		return status.Errorf(codes.InvalidArgument, "%s required", fieldName)
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		return status.Errorf(codes.InvalidArgument, "wrong %s format, uuid required", fieldName)
+	}
+	return nil
+}
+
+// validateFilter checks the optional user and student ids lists are
+// filtered by.
+func validateFilter(userID, studentID string) error {
+	if userID != "" {
+		if err := validateUUID(userID, "user id"); err != nil {
+			return err
+		}
+	}
+	if studentID != "" {
+		if err := validateUUID(studentID, "student id"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validatePageSize(size int32) (int, error) {
+	if size < 0 {
+		return 0, status.Error(codes.InvalidArgument, "page size must not be negative")
+	}
+	if size == 0 {
+		return defaultPageSize, nil
+	}
+	return min(int(size), maxPageSize), nil
+}
@@ -0,0 +1,99 @@
+package apikeysgrpc
+
+import (
+	"Elschool-API/internal/domain/models"
+	"Elschool-API/internal/service"
+	"context"
+	"errors"
+	apiv1 "github.com/Ilya-Repin/elschooler/protos/gen/api/api.v1"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type APIKeys interface {
+	CreateKey(ctx context.Context, service string, scopes []string) (secret string, key models.APIKey, err error)
+	ListKeys(ctx context.Context, service string) (keys []models.APIKey, err error)
+	RevokeKey(ctx context.Context, keyID string) (err error)
+}
+
+type serverAPI struct {
+	apiv1.UnimplementedApiKeysServer
+	apiKeys APIKeys
+}
+
+func Register(gRPC *grpc.Server, apiKeys APIKeys) {
+	apiv1.RegisterApiKeysServer(gRPC, &serverAPI{apiKeys: apiKeys})
+}
+
+func (s *serverAPI) CreateApiKey(ctx context.Context, req *apiv1.CreateApiKeyRequest) (*apiv1.CreateApiKeyResponse, error) {
+	if req.GetService() == "" {
+		return nil, status.Error(codes.InvalidArgument, "service name required")
+	}
+	if len(req.GetScopes()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "scopes required")
+	}
+
+	secret, key, err := s.apiKeys.CreateKey(ctx, req.GetService(), req.GetScopes())
+
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidScope) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+
+		return nil, status.Error(codes.Internal, "create api key error")
+	}
+
+	return &apiv1.CreateApiKeyResponse{ApiKey: secret, Key: toProto(key)}, nil
+}
+
+func (s *serverAPI) ListApiKeys(ctx context.Context, req *apiv1.ListApiKeysRequest) (*apiv1.ListApiKeysResponse, error) {
+	keys, err := s.apiKeys.ListKeys(ctx, req.GetService())
+
+	if err != nil {
+		return nil, status.Error(codes.Internal, "list api keys error")
+	}
+
+	resp := &apiv1.ListApiKeysResponse{Keys: make([]*apiv1.ApiKey, 0, len(keys))}
+	for _, key := range keys {
+		resp.Keys = append(resp.Keys, toProto(key))
+	}
+
+	return resp, nil
+}
+
+func (s *serverAPI) RevokeApiKey(ctx context.Context, req *apiv1.RevokeApiKeyRequest) (*apiv1.RevokeApiKeyResponse, error) {
+	if req.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "api key id required")
+	}
+	if _, err := uuid.Parse(req.GetId()); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "wrong api key id format, uuid required")
+	}
+
+	err := s.apiKeys.RevokeKey(ctx, req.GetId())
+
+	if err != nil {
+		if errors.Is(err, service.ErrAPIKeyNotFound) {
+			return nil, status.Error(codes.InvalidArgument, "no such api key")
+		}
+
+		return nil, status.Error(codes.Internal, "revoke api key error")
+	}
+
+	return &apiv1.RevokeApiKeyResponse{Success: true}, nil
+}
+
+func toProto(key models.APIKey) *apiv1.ApiKey {
+	resp := &apiv1.ApiKey{
+		Id:        key.ID,
+		Service:   key.Service,
+		Scopes:    key.Scopes,
+		CreatedAt: timestamppb.New(key.CreatedAt),
+	}
+	if !key.RevokedAt.IsZero() {
+		resp.RevokedAt = timestamppb.New(key.RevokedAt)
+	}
+	return resp
+}
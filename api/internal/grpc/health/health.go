@@ -0,0 +1,131 @@
+package healthgrpc
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthv1 "google.golang.org/grpc/health/grpc_health_v1"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	DependencyDB       = "db"
+	DependencyCache    = "cache"
+	DependencyElschool = "elschool"
+)
+
+// Probe checks a single dependency, nil means it's reachable.
+type Probe func(ctx context.Context) error
+
+// Checker periodically probes dependencies and reports every service as
+// serving only while all dependencies it needs are reachable.
+type Checker struct {
+	log      *slog.Logger
+	server   *health.Server
+	probes   map[string]Probe
+	services map[string][]string
+	interval time.Duration
+	timeout  time.Duration
+
+	stop chan struct{}
+	once sync.Once
+}
+
+func New(log *slog.Logger, probes map[string]Probe, interval, timeout time.Duration) *Checker {
+	return &Checker{
+		log:      log,
+		server:   health.NewServer(),
+		probes:   probes,
+		services: make(map[string][]string),
+		interval: interval,
+		timeout:  timeout,
+		stop:     make(chan struct{}),
+	}
+}
+
+// SetDependencies declares which dependencies the service needs. An empty
+// service name stands for the server as a whole.
+func (c *Checker) SetDependencies(service string, dependencies ...string) {
+	c.services[service] = dependencies
+	c.server.SetServingStatus(service, healthv1.HealthCheckResponse_NOT_SERVING)
+}
+
+func (c *Checker) Register(gRPCServer *grpc.Server) {
+	healthv1.RegisterHealthServer(gRPCServer, c.server)
+}
+
+// Start runs the first round of probes synchronously and keeps probing in
+// the background until Shutdown.
+func (c *Checker) Start() {
+	c.check()
+
+	go func() {
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-c.stop:
+				return
+			case <-ticker.C:
+				c.check()
+			}
+		}
+	}()
+}
+
+// Shutdown reports every service as not serving and ignores further probes.
+func (c *Checker) Shutdown() {
+	c.once.Do(func() {
+		close(c.stop)
+		c.server.Shutdown()
+	})
+}
+
+func (c *Checker) check() {
+	const op = "grpc.health.check"
+
+	log := c.log.With(slog.String("op", op))
+
+	healthy := make(map[string]bool, len(c.probes))
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+
+	for name, probe := range c.probes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+			defer cancel()
+
+			err := probe(ctx)
+			if err != nil {
+				log.Warn("dependency is unavailable", slog.String("dependency", name), "error", err)
+			}
+
+			mu.Lock()
+			healthy[name] = err == nil
+			mu.Unlock()
+		}()
+	}
+
+	wg.Wait()
+
+	for service, dependencies := range c.services {
+		status := healthv1.HealthCheckResponse_SERVING
+		for _, dependency := range dependencies {
+			if !healthy[dependency] {
+				status = healthv1.HealthCheckResponse_NOT_SERVING
+				break
+			}
+		}
+
+		c.server.SetServingStatus(service, status)
+	}
+}
@@ -0,0 +1,162 @@
+package interceptors
+
+import (
+	"Elschool-API/internal/domain/models"
+	"Elschool-API/internal/service"
+	"context"
+	"errors"
+	apiv1 "github.com/Ilya-Repin/elschooler/protos/gen/api/api.v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthv1 "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	reflectionv1 "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	reflectionv1alpha "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+const APIKeyHeader = "x-api-key"
+
+type KeyAuthorizer interface {
+	Authorize(ctx context.Context, secret string) (key models.APIKey, err error)
+}
+
+type apiKeyCtxKey struct{}
+
+// methodScopes lists the scope every method requires. Methods missing here
+// can't be called with an api key at all.
+var methodScopes = map[string]string{
+	apiv1.User_RegUser_FullMethodName:         models.ScopeUsersCreate,
+	apiv1.User_RotateUserToken_FullMethodName: models.ScopeUsersWrite,
+	apiv1.User_RevokeUserToken_FullMethodName: models.ScopeUsersWrite,
+	apiv1.User_DeleteUser_FullMethodName:      models.ScopeUsersWrite,
+	apiv1.User_ExportUserData_FullMethodName:  models.ScopeUsersRead,
+	apiv1.User_GetQuota_FullMethodName:        models.ScopeUsersRead,
+
+	apiv1.Student_AddStudent_FullMethodName:    models.ScopeStudentsWrite,
+	apiv1.Student_DeleteStudent_FullMethodName: models.ScopeStudentsWrite,
+	apiv1.Student_UpdateStudent_FullMethodName: models.ScopeStudentsWrite,
+
+	apiv1.Marks_GetDayMarks_FullMethodName:        models.ScopeMarksRead,
+	apiv1.Marks_GetAverageMarks_FullMethodName:    models.ScopeMarksRead,
+	apiv1.Marks_GetFinalMarks_FullMethodName:      models.ScopeMarksRead,
+	apiv1.Marks_CreateEventsTicket_FullMethodName: models.ScopeMarksRead,
+
+	apiv1.ApiKeys_CreateApiKey_FullMethodName: models.ScopeKeysAdmin,
+	apiv1.ApiKeys_ListApiKeys_FullMethodName:  models.ScopeKeysAdmin,
+	apiv1.ApiKeys_RevokeApiKey_FullMethodName: models.ScopeKeysAdmin,
+}
+
+// publicServices can be called without an api key, so that probes and
+// tooling work without credentials.
+var publicServices = []string{
+	healthv1.Health_ServiceDesc.ServiceName,
+	reflectionv1.ServerReflection_ServiceDesc.ServiceName,
+	reflectionv1alpha.ServerReflection_ServiceDesc.ServiceName,
+}
+
+// publicMethods can be called without an api key too. They describe the
+// service itself and expose no user data.
+var publicMethods = []string{
+	apiv1.Student_ListInstances_FullMethodName,
+	apiv1.Student_ListProviders_FullMethodName,
+}
+
+// ticketMethods are authorized by a ticket instead, as browsers can't send
+// the key with them. Tickets are issued by methods the key is checked for.
+var ticketMethods = []string{
+	apiv1.Marks_WatchMarks_FullMethodName,
+}
+
+// APIKeyFromContext returns the key the request was authorized with.
+func APIKeyFromContext(ctx context.Context) (models.APIKey, bool) {
+	key, ok := ctx.Value(apiKeyCtxKey{}).(models.APIKey)
+	return key, ok
+}
+
+func APIKeyUnary(log *slog.Logger, authorizer KeyAuthorizer) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := authorize(ctx, log, authorizer, info.FullMethod, req)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+func APIKeyStream(log *slog.Logger, authorizer KeyAuthorizer) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authorize(ss.Context(), log, authorizer, info.FullMethod, nil)
+		if err != nil {
+			return err
+		}
+
+		return handler(srv, &wrappedStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+func authorize(ctx context.Context, log *slog.Logger, authorizer KeyAuthorizer, method string, req any) (context.Context, error) {
+	const op = "grpc.interceptors.authorize"
+
+	if isPublic(method) || slices.Contains(ticketMethods, method) {
+		return ctx, nil
+	}
+
+	scope, ok := methodScopes[method]
+	if !ok {
+		return nil, status.Error(codes.PermissionDenied, "method is not available")
+	}
+
+	secrets := metadata.ValueFromIncomingContext(ctx, APIKeyHeader)
+	if len(secrets) == 0 || secrets[0] == "" {
+		return nil, status.Error(codes.Unauthenticated, "api key required")
+	}
+
+	key, err := authorizer.Authorize(ctx, secrets[0])
+	if err != nil {
+		if errors.Is(err, service.ErrAPIKeyNotFound) {
+			return nil, status.Error(codes.Unauthenticated, "invalid api key")
+		}
+
+		log.Error("failed to authorize api key", slog.String("op", op), "error", err)
+		return nil, status.Error(codes.Internal, "authorization error")
+	}
+
+	if !key.HasScope(scope) {
+		return nil, status.Errorf(codes.PermissionDenied, "api key has no %s scope", scope)
+	}
+
+	// Keys are issued per client service, so they can only act on behalf of
+	// their own service.
+	if r, ok := req.(interface{ GetService() string }); ok && !key.HasScope(models.ScopeKeysAdmin) && r.GetService() != key.Service {
+		return nil, status.Error(codes.PermissionDenied, "api key belongs to another service")
+	}
+
+	return context.WithValue(ctx, apiKeyCtxKey{}, key), nil
+}
+
+// wrappedStream replaces the context of a server stream.
+type wrappedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (w *wrappedStream) Context() context.Context {
+	return w.ctx
+}
+
+func isPublic(method string) bool {
+	if slices.Contains(publicMethods, method) {
+		return true
+	}
+	for _, service := range publicServices {
+		if strings.HasPrefix(method, "/"+service+"/") {
+			return true
+		}
+	}
+	return false
+}
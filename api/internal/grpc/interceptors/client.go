@@ -0,0 +1,30 @@
+package interceptors
+
+import (
+	"Elschool-API/internal/service"
+	"context"
+	"google.golang.org/grpc"
+)
+
+// ClientUnary tells services where the request came from. It goes after the
+// request id and api key interceptors.
+func ClientUnary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		return handler(withClient(ctx), req)
+	}
+}
+
+func ClientStream() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &wrappedStream{ServerStream: ss, ctx: withClient(ss.Context())})
+	}
+}
+
+func withClient(ctx context.Context) context.Context {
+	client := service.Client{RequestID: RequestIDFromContext(ctx)}
+	if key, ok := APIKeyFromContext(ctx); ok {
+		client.Service = key.Service
+	}
+
+	return service.WithClient(ctx, client)
+}
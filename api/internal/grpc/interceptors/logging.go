@@ -0,0 +1,59 @@
+package interceptors
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"log/slog"
+	"time"
+)
+
+// LoggingUnary writes an access log line for every call.
+func LoggingUnary(log *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		logAccess(ctx, log, info.FullMethod, start, err)
+
+		return resp, err
+	}
+}
+
+func LoggingStream(log *slog.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		logAccess(ss.Context(), log, info.FullMethod, start, err)
+
+		return err
+	}
+}
+
+func logAccess(ctx context.Context, log *slog.Logger, method string, start time.Time, err error) {
+	code := status.Code(err)
+
+	attrs := []any{
+		slog.String("method", method),
+		slog.String("code", code.String()),
+		slog.Duration("duration", time.Since(start)),
+		slog.String("request_id", RequestIDFromContext(ctx)),
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		attrs = append(attrs, slog.String("peer", p.Addr.String()))
+	}
+
+	// Probes hit public methods every few seconds, so they only show up in
+	// debug logs.
+	level := slog.LevelInfo
+	if isPublic(method) {
+		level = slog.LevelDebug
+	}
+
+	if err != nil {
+		log.Log(ctx, level, "grpc request failed", append(attrs, slog.String("error", status.Convert(err).Message()))...)
+		return
+	}
+
+	log.Log(ctx, level, "grpc request", attrs...)
+}
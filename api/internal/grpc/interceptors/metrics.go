@@ -0,0 +1,30 @@
+package interceptors
+
+import (
+	"Elschool-API/internal/infra/metrics"
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"time"
+)
+
+// MetricsUnary observes the duration of every call by method and status code.
+func MetricsUnary(metricsInfra *metrics.Metrics) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		metricsInfra.GRPCRequestDuration.WithLabelValues(info.FullMethod, status.Code(err).String()).Observe(time.Since(start).Seconds())
+
+		return resp, err
+	}
+}
+
+func MetricsStream(metricsInfra *metrics.Metrics) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		metricsInfra.GRPCRequestDuration.WithLabelValues(info.FullMethod, status.Code(err).String()).Observe(time.Since(start).Seconds())
+
+		return err
+	}
+}
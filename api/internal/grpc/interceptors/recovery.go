@@ -0,0 +1,49 @@
+package interceptors
+
+import (
+	"Elschool-API/internal/infra/metrics"
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"log/slog"
+	"runtime/debug"
+)
+
+// RecoveryUnary turns a panic in a handler into codes.Internal instead of
+// crashing the whole server.
+func RecoveryUnary(log *slog.Logger, metricsInfra *metrics.Metrics) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = recovered(ctx, log, metricsInfra, info.FullMethod, r)
+			}
+		}()
+
+		return handler(ctx, req)
+	}
+}
+
+func RecoveryStream(log *slog.Logger, metricsInfra *metrics.Metrics) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = recovered(ss.Context(), log, metricsInfra, info.FullMethod, r)
+			}
+		}()
+
+		return handler(srv, ss)
+	}
+}
+
+func recovered(ctx context.Context, log *slog.Logger, metricsInfra *metrics.Metrics, method string, r any) error {
+	log.Error("panic in grpc handler",
+		slog.String("method", method),
+		slog.String("request_id", RequestIDFromContext(ctx)),
+		slog.Any("panic", r),
+		slog.String("stack", string(debug.Stack())),
+	)
+	metricsInfra.GRPCPanicsTotal.WithLabelValues(method).Inc()
+
+	return status.Error(codes.Internal, "internal error")
+}
@@ -0,0 +1,53 @@
+package interceptors
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const RequestIDHeader = "x-request-id"
+
+// maxRequestIDLen bounds ids coming from clients, since they end up in logs.
+const maxRequestIDLen = 128
+
+type requestIDCtxKey struct{}
+
+// RequestIDFromContext returns the id of the request being handled.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDCtxKey{}).(string)
+	return id
+}
+
+// RequestIDUnary takes the request id from the client or generates one, puts
+// it into the context and sends it back in the response headers.
+func RequestIDUnary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, id := withRequestID(ctx)
+		_ = grpc.SetHeader(ctx, metadata.Pairs(RequestIDHeader, id))
+
+		return handler(ctx, req)
+	}
+}
+
+func RequestIDStream() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, id := withRequestID(ss.Context())
+		_ = ss.SetHeader(metadata.Pairs(RequestIDHeader, id))
+
+		return handler(srv, &wrappedStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+func withRequestID(ctx context.Context) (context.Context, string) {
+	var id string
+
+	if ids := metadata.ValueFromIncomingContext(ctx, RequestIDHeader); len(ids) > 0 && ids[0] != "" && len(ids[0]) <= maxRequestIDLen {
+		id = ids[0]
+	} else {
+		id = uuid.NewString()
+	}
+
+	return context.WithValue(ctx, requestIDCtxKey{}, id), id
+}
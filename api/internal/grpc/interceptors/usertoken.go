@@ -0,0 +1,79 @@
+package interceptors
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"strings"
+)
+
+// UserTokenHeader carries the user token as "Bearer <token>". The gateway
+// forwards the Authorization header under it, so HTTP requests without a
+// body don't need the token in the URL.
+const UserTokenHeader = "authorization"
+
+const userTokenField protoreflect.Name = "user_token"
+
+// UserTokenUnary sets the user token of the request from UserTokenHeader.
+// A token in the request itself must be the same one.
+func UserTokenUnary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := withUserToken(ctx, req); err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+func UserTokenStream() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &userTokenStream{ServerStream: ss})
+	}
+}
+
+// userTokenStream sets the user token of every received message.
+type userTokenStream struct {
+	grpc.ServerStream
+}
+
+func (s *userTokenStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+
+	return withUserToken(s.Context(), m)
+}
+
+func withUserToken(ctx context.Context, req any) error {
+	msg, ok := req.(proto.Message)
+	if !ok {
+		return nil
+	}
+
+	field := msg.ProtoReflect().Descriptor().Fields().ByName(userTokenField)
+	if field == nil || field.Kind() != protoreflect.StringKind {
+		return nil
+	}
+
+	values := metadata.ValueFromIncomingContext(ctx, UserTokenHeader)
+	if len(values) == 0 {
+		return nil
+	}
+
+	token, ok := strings.CutPrefix(values[0], "Bearer ")
+	if !ok || token == "" {
+		return status.Error(codes.Unauthenticated, "authorization must be Bearer <user token>")
+	}
+
+	if current := msg.ProtoReflect().Get(field).String(); current != "" && current != token {
+		return status.Error(codes.InvalidArgument, "user token differs from the one in authorization")
+	}
+
+	msg.ProtoReflect().Set(field, protoreflect.ValueOfString(token))
+	return nil
+}
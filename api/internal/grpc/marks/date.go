@@ -0,0 +1,67 @@
+package marksgrpc
+
+import (
+	"google.golang.org/genproto/googleapis/type/date"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"strings"
+	"time"
+)
+
+const (
+	// elschoolDateLayout is how Elschool shows the dates marks were given on,
+	// day marks are looked up by dates in it.
+	elschoolDateLayout = "02.01.2006"
+	isoDateLayout      = time.DateOnly
+	today              = "today"
+	yesterday          = "yesterday"
+	dateFormats        = `YYYY-MM-DD, DD.MM.YYYY, "today" or "yesterday"`
+)
+
+// normalizeDate turns the date of a day marks request into the Elschool
+// layout. Keywords are resolved at now in the school timezone.
+func normalizeDate(dateStr string, day *date.Date, now time.Time, location *time.Location) (string, error) {
+	dateStr = strings.TrimSpace(dateStr)
+
+	if day != nil {
+		if dateStr != "" {
+			return "", status.Error(codes.InvalidArgument, "either date or day is allowed, not both")
+		}
+		return fromProtoDate(day)
+	}
+
+	switch strings.ToLower(dateStr) {
+	case "":
+		return "", status.Error(codes.InvalidArgument, "date required")
+	case today:
+		return now.In(location).Format(elschoolDateLayout), nil
+	case yesterday:
+		return now.In(location).AddDate(0, 0, -1).Format(elschoolDateLayout), nil
+	}
+
+	for _, layout := range []string{isoDateLayout, elschoolDateLayout} {
+		if parsed, err := time.Parse(layout, dateStr); err == nil {
+			return parsed.Format(elschoolDateLayout), nil
+		}
+	}
+
+	// A full ISO-8601 timestamp names the school day it falls on.
+	if parsed, err := time.Parse(time.RFC3339, dateStr); err == nil {
+		return parsed.In(location).Format(elschoolDateLayout), nil
+	}
+
+	return "", status.Errorf(codes.InvalidArgument, "wrong date %q, use %s", dateStr, dateFormats)
+}
+
+func fromProtoDate(day *date.Date) (string, error) {
+	if day.GetYear() == 0 || day.GetMonth() == 0 || day.GetDay() == 0 {
+		return "", status.Error(codes.InvalidArgument, "day must have year, month and day set")
+	}
+
+	parsed := time.Date(int(day.GetYear()), time.Month(day.GetMonth()), int(day.GetDay()), 0, 0, 0, 0, time.UTC)
+	if parsed.Year() != int(day.GetYear()) || int(parsed.Month()) != int(day.GetMonth()) || parsed.Day() != int(day.GetDay()) {
+		return "", status.Errorf(codes.InvalidArgument, "wrong day %04d-%02d-%02d, no such date", day.GetYear(), day.GetMonth(), day.GetDay())
+	}
+
+	return parsed.Format(elschoolDateLayout), nil
+}
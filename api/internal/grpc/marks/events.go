@@ -0,0 +1,126 @@
+package marksgrpc
+
+import (
+	"Elschool-API/internal/domain/models"
+	"Elschool-API/internal/service"
+	"context"
+	"errors"
+	apiv1 "github.com/Ilya-Repin/elschooler/protos/gen/api/api.v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (s *serverAPI) CreateEventsTicket(ctx context.Context, req *apiv1.EventsTicketRequest) (*apiv1.EventsTicketResponse, error) {
+	if err := validateUUID4(req.GetUserToken(), "user token"); err != nil {
+		return nil, err
+	}
+	if err := validateUUID4(req.GetStudentToken(), "student token"); err != nil {
+		return nil, err
+	}
+
+	ticket, err := s.marks.CreateEventsTicket(ctx, req.GetUserToken(), req.GetStudentToken())
+	if err != nil {
+		if errors.Is(err, service.ErrUserNotFound) {
+			return nil, status.Error(codes.InvalidArgument, "no such user")
+		}
+
+		if errors.Is(err, service.ErrStudentNotFound) {
+			return nil, status.Error(codes.InvalidArgument, "no such student")
+		}
+
+		return nil, status.Error(codes.Internal, "failed to create events ticket")
+	}
+
+	return &apiv1.EventsTicketResponse{Ticket: ticket.ID, ExpiresAt: timestamppb.New(ticket.ExpiresAt)}, nil
+}
+
+// WatchMarks sends whether events were lost first, then the missed events
+// and the new ones. The stream ends when the client falls behind, it reopens
+// the stream and catches up with the last event id.
+func (s *serverAPI) WatchMarks(req *apiv1.WatchMarksRequest, stream apiv1.Marks_WatchMarksServer) error {
+	if err := validateUUID4(req.GetTicket(), "ticket"); err != nil {
+		return status.Error(codes.Unauthenticated, status.Convert(err).Message())
+	}
+	if err := validateUUID4(req.GetStudentToken(), "student token"); err != nil {
+		return err
+	}
+
+	sub, err := s.marks.WatchMarks(stream.Context(), req.GetTicket(), req.GetStudentToken(), req.GetLastEventId())
+	if err != nil {
+		if errors.Is(err, service.ErrTicketNotFound) {
+			return status.Error(codes.Unauthenticated, "invalid ticket, it may be used or expired")
+		}
+
+		if errors.Is(err, service.ErrUserNotFound) {
+			return status.Error(codes.Unauthenticated, "no such user")
+		}
+
+		if errors.Is(err, service.ErrStudentNotFound) {
+			return status.Error(codes.NotFound, "no such student")
+		}
+
+		return status.Error(codes.Internal, "failed to watch marks")
+	}
+
+	if err = stream.Send(&apiv1.WatchMarksResponse{Reset_: sub.Reset}); err != nil {
+		return err
+	}
+
+	for _, event := range sub.Backlog {
+		if err = stream.Send(&apiv1.WatchMarksResponse{Event: toMarksEvent(event)}); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case event, ok := <-sub.Events:
+			if !ok {
+				return nil
+			}
+			if err = stream.Send(&apiv1.WatchMarksResponse{Event: toMarksEvent(event)}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func toMarksEvent(event models.MarksEvent) *apiv1.MarksEvent {
+	grpcEvent := &apiv1.MarksEvent{Id: event.ID, Type: event.Type, Subjects: event.Subjects}
+
+	switch {
+	case event.Day != nil:
+		grpcEvent.Date = event.Day.Date
+		grpcEvent.Marks = &apiv1.MarksEvent_Day{Day: &apiv1.DayMarksResponse{
+			Marks:     toIntMarks(event.Day.Marks),
+			WorstMark: event.Day.WorstMark,
+			FetchedAt: timestamppb.New(event.Day.FetchedAt),
+		}}
+	case event.Average != nil:
+		grpcEvent.Period = event.Average.Period
+		grpcEvent.Marks = &apiv1.MarksEvent_Average{Average: &apiv1.AverageMarksResponse{
+			Marks:     event.Average.Marks,
+			WorstMark: event.Average.WorstMark,
+			FetchedAt: timestamppb.New(event.Average.FetchedAt),
+		}}
+	case event.Final != nil:
+		grpcEvent.Marks = &apiv1.MarksEvent_Final{Final: &apiv1.FinalMarksResponse{
+			Marks:     toIntMarks(event.Final.Marks),
+			WorstMark: event.Final.WorstMark,
+			FetchedAt: timestamppb.New(event.Final.FetchedAt),
+		}}
+	}
+
+	return grpcEvent
+}
+
+func toIntMarks(marks map[string][]int32) map[string]*apiv1.LisOfIntMarks {
+	grpcMarks := make(map[string]*apiv1.LisOfIntMarks, len(marks))
+	for key, values := range marks {
+		grpcMarks[key] = &apiv1.LisOfIntMarks{Marks: values}
+	}
+	return grpcMarks
+}
@@ -2,6 +2,7 @@ package marksgrpc
 
 import (
 	"Elschool-API/internal/domain/models"
+	quotagrpc "Elschool-API/internal/grpc/quota"
 	"Elschool-API/internal/service"
 	"context"
 	"errors"
@@ -10,12 +11,16 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"time"
 )
 
 type Marks interface {
 	GetDayMarks(ctx context.Context, userToken, studentToken, date string) (marks models.DayMarks, err error)
 	GetAverageMarks(ctx context.Context, userToken, studentToken string, period int32) (marks models.AverageMarks, err error)
 	GetFinalMarks(ctx context.Context, userToken, studentToken string) (marks models.FinalMarks, err error)
+	CreateEventsTicket(ctx context.Context, userToken, studentToken string) (ticket models.StreamTicket, err error)
+	WatchMarks(ctx context.Context, ticket, studentToken, lastEventID string) (sub models.MarksSubscription, err error)
 }
 
 const (
@@ -25,10 +30,12 @@ const (
 type serverAPI struct {
 	apiv1.UnimplementedMarksServer
 	marks Marks
+	// location is the school timezone relative dates are resolved in.
+	location *time.Location
 }
 
-func Register(gRPC *grpc.Server, marks Marks) {
-	apiv1.RegisterMarksServer(gRPC, &serverAPI{marks: marks})
+func Register(gRPC *grpc.Server, marks Marks, location *time.Location) {
+	apiv1.RegisterMarksServer(gRPC, &serverAPI{marks: marks, location: location})
 }
 
 func (s *serverAPI) GetDayMarks(ctx context.Context, req *apiv1.DayMarksRequest) (*apiv1.DayMarksResponse, error) {
@@ -39,17 +46,38 @@ func (s *serverAPI) GetDayMarks(ctx context.Context, req *apiv1.DayMarksRequest)
 		return nil, err
 	}
 
-	if req.GetDate() == "" {
-		return nil, status.Error(codes.InvalidArgument, "date required")
+	date, err := normalizeDate(req.GetDate(), req.GetDay(), time.Now(), s.location)
+	if err != nil {
+		return nil, err
 	}
 
-	dayMarks, err := s.marks.GetDayMarks(ctx, req.GetUserToken(), req.GetStudentToken(), req.GetDate())
+	dayMarks, err := s.marks.GetDayMarks(ctx, req.GetUserToken(), req.GetStudentToken(), date)
 
 	if err != nil {
+		if errors.Is(err, service.ErrUserNotFound) {
+			return nil, status.Error(codes.InvalidArgument, "no such user")
+		}
+
 		if errors.Is(err, service.ErrStudentNotFound) {
 			return nil, status.Error(codes.InvalidArgument, "no such student")
 		}
 
+		if st, ok := quotagrpc.Status(err); ok {
+			return nil, st
+		}
+
+		if errors.Is(err, service.ErrUnavailable) {
+			return nil, status.Error(codes.Unavailable, "elschool is unavailable, try again later")
+		}
+
+		if errors.Is(err, service.ErrNotSupported) {
+			return nil, status.Error(codes.FailedPrecondition, "not supported by the diary provider of the student")
+		}
+
+		if errors.Is(err, service.ErrMarkupChanged) {
+			return nil, status.Error(codes.Unavailable, "diary pages changed and can't be read, try again later")
+		}
+
 		return nil, status.Error(codes.Internal, "failed to get marks")
 	}
 
@@ -59,7 +87,13 @@ func (s *serverAPI) GetDayMarks(ctx context.Context, req *apiv1.DayMarksRequest)
 		grpcMarks[key] = &apiv1.LisOfIntMarks{Marks: values}
 	}
 
-	return &apiv1.DayMarksResponse{Marks: grpcMarks, WorstMark: dayMarks.WorstMark}, nil
+	return &apiv1.DayMarksResponse{
+		Marks:     grpcMarks,
+		WorstMark: dayMarks.WorstMark,
+		FetchedAt: timestamppb.New(dayMarks.FetchedAt),
+		Stale:     dayMarks.Stale,
+		Warnings:  toWarnings(dayMarks.Warnings),
+	}, nil
 }
 
 func (s *serverAPI) GetAverageMarks(ctx context.Context, req *apiv1.AverageMarksRequest) (*apiv1.AverageMarksResponse, error) {
@@ -76,14 +110,40 @@ func (s *serverAPI) GetAverageMarks(ctx context.Context, req *apiv1.AverageMarks
 	avgMarks, err := s.marks.GetAverageMarks(ctx, req.GetUserToken(), req.GetStudentToken(), req.GetPeriod())
 
 	if err != nil {
+		if errors.Is(err, service.ErrUserNotFound) {
+			return nil, status.Error(codes.InvalidArgument, "no such user")
+		}
+
 		if errors.Is(err, service.ErrStudentNotFound) {
 			return nil, status.Error(codes.InvalidArgument, "no such student")
 		}
 
+		if st, ok := quotagrpc.Status(err); ok {
+			return nil, st
+		}
+
+		if errors.Is(err, service.ErrUnavailable) {
+			return nil, status.Error(codes.Unavailable, "elschool is unavailable, try again later")
+		}
+
+		if errors.Is(err, service.ErrNotSupported) {
+			return nil, status.Error(codes.FailedPrecondition, "not supported by the diary provider of the student")
+		}
+
+		if errors.Is(err, service.ErrMarkupChanged) {
+			return nil, status.Error(codes.Unavailable, "diary pages changed and can't be read, try again later")
+		}
+
 		return nil, status.Error(codes.Internal, "failed to get marks")
 	}
 
-	return &apiv1.AverageMarksResponse{Marks: avgMarks.Marks, WorstMark: avgMarks.WorstMark}, nil
+	return &apiv1.AverageMarksResponse{
+		Marks:     avgMarks.Marks,
+		WorstMark: avgMarks.WorstMark,
+		FetchedAt: timestamppb.New(avgMarks.FetchedAt),
+		Stale:     avgMarks.Stale,
+		Warnings:  toWarnings(avgMarks.Warnings),
+	}, nil
 }
 
 func (s *serverAPI) GetFinalMarks(ctx context.Context, req *apiv1.FinalMarksRequest) (*apiv1.FinalMarksResponse, error) {
@@ -97,10 +157,30 @@ func (s *serverAPI) GetFinalMarks(ctx context.Context, req *apiv1.FinalMarksRequ
 	finalMarks, err := s.marks.GetFinalMarks(ctx, req.GetUserToken(), req.GetStudentToken())
 
 	if err != nil {
+		if errors.Is(err, service.ErrUserNotFound) {
+			return nil, status.Error(codes.InvalidArgument, "no such user")
+		}
+
 		if errors.Is(err, service.ErrStudentNotFound) {
 			return nil, status.Error(codes.InvalidArgument, "no such student")
 		}
 
+		if st, ok := quotagrpc.Status(err); ok {
+			return nil, st
+		}
+
+		if errors.Is(err, service.ErrUnavailable) {
+			return nil, status.Error(codes.Unavailable, "elschool is unavailable, try again later")
+		}
+
+		if errors.Is(err, service.ErrNotSupported) {
+			return nil, status.Error(codes.FailedPrecondition, "not supported by the diary provider of the student")
+		}
+
+		if errors.Is(err, service.ErrMarkupChanged) {
+			return nil, status.Error(codes.Unavailable, "diary pages changed and can't be read, try again later")
+		}
+
 		return nil, status.Error(codes.Internal, "failed to get marks")
 	}
 
@@ -110,7 +190,21 @@ func (s *serverAPI) GetFinalMarks(ctx context.Context, req *apiv1.FinalMarksRequ
 		grpcMarks[key] = &apiv1.LisOfIntMarks{Marks: values}
 	}
 
-	return &apiv1.FinalMarksResponse{Marks: grpcMarks, WorstMark: finalMarks.WorstMark}, nil
+	return &apiv1.FinalMarksResponse{
+		Marks:     grpcMarks,
+		WorstMark: finalMarks.WorstMark,
+		FetchedAt: timestamppb.New(finalMarks.FetchedAt),
+		Stale:     finalMarks.Stale,
+		Warnings:  toWarnings(finalMarks.Warnings),
+	}, nil
+}
+
+func toWarnings(warnings []models.ParseWarning) []*apiv1.ParseWarning {
+	grpcWarnings := make([]*apiv1.ParseWarning, 0, len(warnings))
+	for _, w := range warnings {
+		grpcWarnings = append(grpcWarnings, &apiv1.ParseWarning{Selector: w.Selector, Row: int32(w.Row), Raw: w.Raw, Reason: w.Reason})
+	}
+	return grpcWarnings
 }
 
 func validateUUID4(id, fieldName string) error {
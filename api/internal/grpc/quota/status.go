@@ -0,0 +1,39 @@
+package quotagrpc
+
+import (
+	"Elschool-API/internal/service"
+	"errors"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// Status converts a quota violation into ResourceExhausted with QuotaFailure
+// details, and RetryInfo when waiting helps. It returns false for other
+// errors.
+func Status(err error) (error, bool) {
+	var qerr *service.QuotaError
+	if !errors.As(err, &qerr) {
+		return nil, false
+	}
+
+	st := status.Newf(codes.ResourceExhausted, "%s quota of %d exceeded", qerr.Quota, qerr.Limit)
+
+	details := []protoadapt.MessageV1{&errdetails.QuotaFailure{
+		Violations: []*errdetails.QuotaFailure_Violation{{
+			Subject:     qerr.Subject,
+			Description: qerr.Quota,
+		}},
+	}}
+	if qerr.RetryAfter > 0 {
+		details = append(details, &errdetails.RetryInfo{RetryDelay: durationpb.New(qerr.RetryAfter)})
+	}
+
+	if detailed, err := st.WithDetails(details...); err == nil {
+		st = detailed
+	}
+
+	return st.Err(), true
+}
@@ -1,6 +1,8 @@
 package studentgrpc
 
 import (
+	"Elschool-API/internal/domain/models"
+	quotagrpc "Elschool-API/internal/grpc/quota"
 	"Elschool-API/internal/service"
 	"context"
 	"errors"
@@ -12,9 +14,11 @@ import (
 )
 
 type Student interface {
-	AddStudent(ctx context.Context, userToken, login, password string) (studentToken string, err error)
+	AddStudent(ctx context.Context, userToken, provider, instance, login, password string) (studentToken string, err error)
 	DeleteStudent(ctx context.Context, userToken, studentToken string) (err error)
 	UpdateStudent(ctx context.Context, userToken, studentToken, login, password string) (newStudentToken string, err error)
+	ListInstances(provider string) (instances []string, err error)
+	ListProviders() (providers []models.DiaryProvider)
 }
 
 type serverAPI struct {
@@ -39,13 +43,29 @@ func (s *serverAPI) AddStudent(ctx context.Context, req *apiv1.AddStudentRequest
 		return nil, status.Error(codes.InvalidArgument, "password required")
 	}
 
-	token, err := s.student.AddStudent(ctx, req.GetUserToken(), req.GetLogin(), req.GetPassword())
+	token, err := s.student.AddStudent(ctx, req.GetUserToken(), req.GetProvider(), req.GetInstance(), req.GetLogin(), req.GetPassword())
 
 	if err != nil {
 		if errors.Is(err, service.ErrUserNotFound) {
 			return nil, status.Error(codes.InvalidArgument, "no such user")
 		}
 
+		if errors.Is(err, service.ErrUnknownProvider) {
+			return nil, status.Error(codes.InvalidArgument, "unknown provider")
+		}
+
+		if errors.Is(err, service.ErrUnknownInstance) {
+			return nil, status.Error(codes.InvalidArgument, "unknown instance")
+		}
+
+		if st, ok := quotagrpc.Status(err); ok {
+			return nil, st
+		}
+
+		if errors.Is(err, service.ErrUnavailable) {
+			return nil, status.Error(codes.Unavailable, "elschool is unavailable, try again later")
+		}
+
 		return nil, status.Error(codes.Internal, "add student error")
 	}
 
@@ -106,12 +126,53 @@ func (s *serverAPI) UpdateStudent(ctx context.Context, req *apiv1.UpdateStudentR
 			return nil, status.Error(codes.InvalidArgument, "no such student")
 		}
 
+		if st, ok := quotagrpc.Status(err); ok {
+			return nil, st
+		}
+
+		if errors.Is(err, service.ErrUnavailable) {
+			return nil, status.Error(codes.Unavailable, "elschool is unavailable, try again later")
+		}
+
 		return nil, status.Error(codes.Internal, "update user error")
 	}
 
 	return &apiv1.UpdateStudentResponse{StudentToken: token}, nil
 }
 
+func (s *serverAPI) ListInstances(ctx context.Context, req *apiv1.ListInstancesRequest) (*apiv1.ListInstancesResponse, error) {
+	instances, err := s.student.ListInstances(req.GetProvider())
+	if err != nil {
+		if errors.Is(err, service.ErrUnknownProvider) {
+			return nil, status.Error(codes.InvalidArgument, "unknown provider")
+		}
+
+		return nil, status.Error(codes.Internal, "list instances error")
+	}
+
+	return &apiv1.ListInstancesResponse{Instances: instances, DefaultInstance: instances[0]}, nil
+}
+
+func (s *serverAPI) ListProviders(ctx context.Context, req *apiv1.ListProvidersRequest) (*apiv1.ListProvidersResponse, error) {
+	providers := s.student.ListProviders()
+
+	resp := &apiv1.ListProvidersResponse{DefaultProvider: models.DefaultProvider}
+	for _, provider := range providers {
+		resp.Providers = append(resp.Providers, &apiv1.DiaryProvider{
+			Name:            provider.Name,
+			Instances:       provider.Instances,
+			DefaultInstance: provider.Instances[0],
+			Capabilities: &apiv1.DiaryCapabilities{
+				DayMarks:     provider.Capabilities.DayMarks,
+				AverageMarks: provider.Capabilities.AverageMarks,
+				FinalMarks:   provider.Capabilities.FinalMarks,
+			},
+		})
+	}
+
+	return resp, nil
+}
+
 func validateUUID4(id, fieldName string) error {
 	if id == "" {
 		return status.Errorf(codes.InvalidArgument, "%s required", fieldName)
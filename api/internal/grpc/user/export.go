@@ -0,0 +1,158 @@
+package usergrpc
+
+import (
+	"Elschool-API/internal/domain/models"
+	"archive/zip"
+	"bytes"
+	"fmt"
+	apiv1 "github.com/Ilya-Repin/elschooler/protos/gen/api/api.v1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"time"
+)
+
+const (
+	exportFileName  = "user_data"
+	exportChunkSize = 64 << 10
+	redacted        = "[redacted]"
+)
+
+func exportDocument(export models.UserExport, exportedAt time.Time) *apiv1.UserDataExport {
+	doc := &apiv1.UserDataExport{
+		ExportedAt: timestamppb.New(exportedAt),
+		User: &apiv1.ExportedUser{
+			Id:             export.User.ID,
+			Service:        export.User.Service,
+			CreatedAt:      timestamp(export.User.CreatedAt),
+			DisabledAt:     timestamp(export.User.DisabledAt),
+			DisabledReason: export.User.DisabledReason,
+		},
+	}
+
+	for _, token := range export.Tokens {
+		doc.Tokens = append(doc.Tokens, &apiv1.ExportedToken{
+			CreatedAt: timestamp(token.CreatedAt),
+			ExpiresAt: timestamp(token.ExpiresAt),
+			RevokedAt: timestamp(token.RevokedAt),
+		})
+	}
+
+	for _, student := range export.Students {
+		doc.Students = append(doc.Students, &apiv1.ExportedStudent{
+			StudentToken: student.ID,
+			Login:        redacted,
+			Password:     redacted,
+			LinkedAt:     timestamp(student.LinkedAt),
+			LastUsedAt:   timestamp(student.LastUsedAt),
+			LastMarks:    exportMarks(student.LastMarks),
+		})
+	}
+
+	for _, entry := range export.Audit {
+		doc.AuditLog = append(doc.AuditLog, &apiv1.ExportedAuditEntry{
+			Actor:        entry.Actor,
+			Action:       entry.Action,
+			Status:       entry.Status,
+			RequestId:    entry.RequestID,
+			CreatedAt:    timestamp(entry.CreatedAt),
+			StudentToken: entry.StudentID,
+			Service:      entry.Service,
+		})
+	}
+
+	return doc
+}
+
+func exportMarks(last models.LastMarks) *apiv1.ExportedMarks {
+	marks := &apiv1.ExportedMarks{}
+
+	for _, day := range last.Day {
+		marks.Day = append(marks.Day, &apiv1.ExportedDayMarks{
+			Date: day.Date,
+			Marks: &apiv1.DayMarksResponse{
+				Marks:     intMarks(day.Marks),
+				WorstMark: day.WorstMark,
+				FetchedAt: timestamp(day.FetchedAt),
+			},
+		})
+	}
+
+	for _, average := range last.Average {
+		marks.Average = append(marks.Average, &apiv1.ExportedAverageMarks{
+			Period: average.Period,
+			Marks: &apiv1.AverageMarksResponse{
+				Marks:     average.Marks,
+				WorstMark: average.WorstMark,
+				FetchedAt: timestamp(average.FetchedAt),
+			},
+		})
+	}
+
+	if last.Final != nil {
+		marks.Final = &apiv1.FinalMarksResponse{
+			Marks:     intMarks(last.Final.Marks),
+			WorstMark: last.Final.WorstMark,
+			FetchedAt: timestamp(last.Final.FetchedAt),
+		}
+	}
+
+	return marks
+}
+
+func intMarks(marks map[string][]int32) map[string]*apiv1.LisOfIntMarks {
+	grpcMarks := make(map[string]*apiv1.LisOfIntMarks, len(marks))
+	for key, values := range marks {
+		grpcMarks[key] = &apiv1.LisOfIntMarks{Marks: values}
+	}
+	return grpcMarks
+}
+
+// encodeExport renders the document in the requested format, JSON unless
+// it is set.
+func encodeExport(doc *apiv1.UserDataExport, format apiv1.ExportFormat) (contentType, fileName string, data []byte, err error) {
+	data, err = protojson.MarshalOptions{Multiline: true, Indent: "  "}.Marshal(doc)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	if format != apiv1.ExportFormat_EXPORT_FORMAT_ZIP {
+		return "application/json", exportFileName + ".json", data, nil
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	w, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     exportFileName + ".json",
+		Method:   zip.Deflate,
+		Modified: doc.GetExportedAt().AsTime(),
+	})
+	if err != nil {
+		return "", "", nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return "", "", nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return "", "", nil, err
+	}
+
+	return "application/zip", exportFileName + ".zip", buf.Bytes(), nil
+}
+
+// sendExport streams the bundle in chunks, the first one tells what it is.
+func sendExport(stream grpc.ServerStreamingServer[apiv1.ExportUserDataResponse], contentType, fileName string, data []byte) error {
+	for start := 0; start == 0 || start < len(data); start += exportChunkSize {
+		resp := &apiv1.ExportUserDataResponse{Chunk: data[start:min(start+exportChunkSize, len(data))]}
+		if start == 0 {
+			resp.ContentType, resp.FileName = contentType, fileName
+		}
+
+		if err := stream.Send(resp); err != nil {
+			return fmt.Errorf("send export chunk: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -1,24 +1,40 @@
 package usergrpc
 
 import (
+	"Elschool-API/internal/domain/models"
+	"Elschool-API/internal/service"
 	"context"
+	"errors"
 	apiv1 "github.com/Ilya-Repin/elschooler/protos/gen/api/api.v1"
+	"github.com/google/uuid"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"time"
 )
 
 type User interface {
-	CreateUser(ctx context.Context, service string) (token string, err error)
+	CreateUser(ctx context.Context, service string, ttl time.Duration) (token string, expiresAt time.Time, err error)
+	RotateToken(ctx context.Context, token string, ttl time.Duration) (newToken string, expiresAt, oldExpiresAt time.Time, err error)
+	RevokeToken(ctx context.Context, token string) (err error)
+	DeleteUser(ctx context.Context, token string) (deleted models.UserDeletion, err error)
+	ExportUserData(ctx context.Context, token string) (export models.UserExport, err error)
+}
+
+type Quota interface {
+	GetQuota(ctx context.Context, token string) (usage models.QuotaUsage, err error)
 }
 
 type serverAPI struct {
 	apiv1.UnimplementedUserServer
-	user User
+	user  User
+	quota Quota
 }
 
-func Register(gRPC *grpc.Server, user User) {
-	apiv1.RegisterUserServer(gRPC, &serverAPI{user: user})
+func Register(gRPC *grpc.Server, user User, quota Quota) {
+	apiv1.RegisterUserServer(gRPC, &serverAPI{user: user, quota: quota})
 }
 
 func (s *serverAPI) RegUser(ctx context.Context, req *apiv1.RegUserRequest) (*apiv1.RegUserResponse, error) {
@@ -26,11 +42,175 @@ func (s *serverAPI) RegUser(ctx context.Context, req *apiv1.RegUserRequest) (*ap
 		return nil, status.Error(codes.InvalidArgument, "service name required")
 	}
 
-	token, err := s.user.CreateUser(ctx, req.GetService())
+	ttl, err := validateTTL(req.GetTtl())
+	if err != nil {
+		return nil, err
+	}
+
+	token, expiresAt, err := s.user.CreateUser(ctx, req.GetService(), ttl)
 
 	if err != nil {
 		return nil, status.Error(codes.Internal, "create user error")
 	}
 
-	return &apiv1.RegUserResponse{UserToken: token}, nil
+	return &apiv1.RegUserResponse{UserToken: token, ExpiresAt: timestamp(expiresAt)}, nil
+}
+
+func (s *serverAPI) RotateUserToken(ctx context.Context, req *apiv1.RotateUserTokenRequest) (*apiv1.RotateUserTokenResponse, error) {
+	if err := validateUUID4(req.GetUserToken(), "user token"); err != nil {
+		return nil, err
+	}
+
+	ttl, err := validateTTL(req.GetTtl())
+	if err != nil {
+		return nil, err
+	}
+
+	token, expiresAt, oldExpiresAt, err := s.user.RotateToken(ctx, req.GetUserToken(), ttl)
+
+	if err != nil {
+		if errors.Is(err, service.ErrUserNotFound) {
+			return nil, status.Error(codes.InvalidArgument, "no such user")
+		}
+
+		return nil, status.Error(codes.Internal, "rotate user token error")
+	}
+
+	return &apiv1.RotateUserTokenResponse{
+		UserToken:         token,
+		ExpiresAt:         timestamp(expiresAt),
+		PreviousExpiresAt: timestamp(oldExpiresAt),
+	}, nil
+}
+
+func (s *serverAPI) RevokeUserToken(ctx context.Context, req *apiv1.RevokeUserTokenRequest) (*apiv1.RevokeUserTokenResponse, error) {
+	if err := validateUUID4(req.GetUserToken(), "user token"); err != nil {
+		return nil, err
+	}
+
+	err := s.user.RevokeToken(ctx, req.GetUserToken())
+
+	if err != nil {
+		if errors.Is(err, service.ErrUserNotFound) {
+			return nil, status.Error(codes.InvalidArgument, "no such user")
+		}
+
+		return nil, status.Error(codes.Internal, "revoke user token error")
+	}
+
+	return &apiv1.RevokeUserTokenResponse{Success: true}, nil
+}
+
+func (s *serverAPI) DeleteUser(ctx context.Context, req *apiv1.DeleteUserRequest) (*apiv1.DeleteUserResponse, error) {
+	if err := validateUUID4(req.GetUserToken(), "user token"); err != nil {
+		return nil, err
+	}
+
+	deleted, err := s.user.DeleteUser(ctx, req.GetUserToken())
+
+	if err != nil {
+		if errors.Is(err, service.ErrUserNotFound) {
+			return nil, status.Error(codes.InvalidArgument, "no such user")
+		}
+
+		return nil, status.Error(codes.Internal, "delete user error")
+	}
+
+	return &apiv1.DeleteUserResponse{
+		TokensDeleted:            int32(deleted.Tokens),
+		RelationsDeleted:         int32(deleted.Relations),
+		StudentsDeleted:          int32(len(deleted.Students)),
+		DeletedStudentTokens:     deleted.Students,
+		StudentsCacheInvalidated: int32(deleted.CachesInvalidated),
+	}, nil
+}
+
+func (s *serverAPI) ExportUserData(req *apiv1.ExportUserDataRequest, stream grpc.ServerStreamingServer[apiv1.ExportUserDataResponse]) error {
+	if err := validateUUID4(req.GetUserToken(), "user token"); err != nil {
+		return err
+	}
+
+	if _, ok := apiv1.ExportFormat_name[int32(req.GetFormat())]; !ok {
+		return status.Error(codes.InvalidArgument, "unknown export format")
+	}
+
+	export, err := s.user.ExportUserData(stream.Context(), req.GetUserToken())
+
+	if err != nil {
+		if errors.Is(err, service.ErrUserNotFound) {
+			return status.Error(codes.InvalidArgument, "no such user")
+		}
+
+		return status.Error(codes.Internal, "export user data error")
+	}
+
+	contentType, fileName, data, err := encodeExport(exportDocument(export, time.Now().UTC()), req.GetFormat())
+	if err != nil {
+		return status.Error(codes.Internal, "export user data error")
+	}
+
+	return sendExport(stream, contentType, fileName, data)
+}
+
+func (s *serverAPI) GetQuota(ctx context.Context, req *apiv1.GetQuotaRequest) (*apiv1.GetQuotaResponse, error) {
+	if err := validateUUID4(req.GetUserToken(), "user token"); err != nil {
+		return nil, err
+	}
+
+	usage, err := s.quota.GetQuota(ctx, req.GetUserToken())
+
+	if err != nil {
+		if errors.Is(err, service.ErrUserNotFound) {
+			return nil, status.Error(codes.InvalidArgument, "no such user")
+		}
+
+		return nil, status.Error(codes.Internal, "get quota error")
+	}
+
+	students := make([]*apiv1.StudentQuota, 0, len(usage.LinkedStudents))
+	for _, student := range usage.LinkedStudents {
+		students = append(students, &apiv1.StudentQuota{
+			StudentToken: student.StudentID,
+			Users:        quotaUsage(usage.Limits.UsersPerStudent, student.Users),
+		})
+	}
+
+	return &apiv1.GetQuotaResponse{
+		Students:             quotaUsage(usage.Limits.StudentsPerUser, usage.Students),
+		MarksRequests:        quotaUsage(usage.Limits.MarksPerMinute, usage.MarksRequests),
+		MarksRequestsResetAt: timestamp(usage.MarksResetAt),
+		LinkedStudents:       students,
+	}, nil
+}
+
+func quotaUsage(limit, used int) *apiv1.QuotaUsage {
+	return &apiv1.QuotaUsage{Limit: int32(limit), Used: int32(used), Remaining: int32(max(limit-used, 0))}
+}
+
+func validateTTL(ttl *durationpb.Duration) (time.Duration, error) {
+	if ttl == nil {
+		return 0, nil
+	}
+	if err := ttl.CheckValid(); err != nil || ttl.AsDuration() < 0 {
+		return 0, status.Error(codes.InvalidArgument, "wrong ttl, non-negative duration required")
+	}
+	return ttl.AsDuration(), nil
+}
+
+// timestamp leaves the field unset for tokens which never expire.
+func timestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+func validateUUID4(id, fieldName string) error {
+	if id == "" {
+		return status.Errorf(codes.InvalidArgument, "%s required", fieldName)
+	}
+	if parsedUUID, err := uuid.Parse(id); err != nil || parsedUUID.Version() != 4 {
+		return status.Errorf(codes.InvalidArgument, "wrong %s format, uuid4 required", fieldName)
+	}
+	return nil
 }
@@ -0,0 +1,189 @@
+package eventshttp
+
+import (
+	"Elschool-API/internal/grpc/interceptors"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	apiv1 "github.com/Ilya-Repin/elschooler/protos/gen/api/api.v1"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const (
+	Pattern = "GET /v1/students/{student_token}/events"
+
+	lastEventIDHeader = "Last-Event-ID"
+	// retryDelay is how long browsers wait before reconnecting.
+	retryDelay = 5 * time.Second
+)
+
+type eventData struct {
+	Type      string    `json:"type"`
+	Subjects  []string  `json:"subjects"`
+	Date      string    `json:"date,omitempty"`
+	Period    int32     `json:"period,omitempty"`
+	Marks     any       `json:"marks"`
+	WorstMark any       `json:"worst_mark"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+type handler struct {
+	log       *slog.Logger
+	marks     apiv1.MarksClient
+	heartbeat time.Duration
+}
+
+// New returns the Server-Sent Events stream of the student's marks changes.
+// It is served by the WatchMarks call, so the ticket is checked, logged and
+// counted by the gRPC server. EventSource can't set headers, so the ticket
+// from CreateEventsTicket is taken from the ticket query parameter.
+func New(log *slog.Logger, marks apiv1.MarksClient, heartbeat time.Duration) http.Handler {
+	return &handler{log: log, marks: marks, heartbeat: heartbeat}
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	const op = "http.events.ServeHTTP"
+
+	log := h.log.With(slog.String("op", op))
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	lastEventID := r.Header.Get(lastEventIDHeader)
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	if id := r.Header.Get(interceptors.RequestIDHeader); id != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, interceptors.RequestIDHeader, id)
+	}
+
+	stream, err := h.marks.WatchMarks(ctx, &apiv1.WatchMarksRequest{
+		Ticket:       r.URL.Query().Get("ticket"),
+		StudentToken: r.PathValue("student_token"),
+		LastEventId:  lastEventID,
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// The first message tells the stream is open, failures come instead of it.
+	opened, err := stream.Recv()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if header, err := stream.Header(); err == nil {
+		if ids := header.Get(interceptors.RequestIDHeader); len(ids) > 0 {
+			w.Header().Set(interceptors.RequestIDHeader, ids[0])
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: %d\n\n", retryDelay.Milliseconds())
+
+	if opened.GetReset_() {
+		fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+	}
+	flusher.Flush()
+
+	events := make(chan *apiv1.MarksEvent)
+	go func() {
+		defer close(events)
+
+		for {
+			resp, err := stream.Recv()
+			if err != nil {
+				if !errors.Is(err, io.EOF) && ctx.Err() == nil {
+					log.Error("failed to receive marks event", "error", err)
+				}
+				return
+			}
+
+			select {
+			case events <- resp.GetEvent():
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		case event, ok := <-events:
+			// The stream ends when the client falls behind, it reconnects
+			// with a new ticket and catches up with the last event id.
+			if !ok {
+				return
+			}
+			if err := writeEvent(w, event); err != nil {
+				log.Error("failed to write event", "error", err)
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// writeError answers with the HTTP status the gateway maps the code to.
+func writeError(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+	http.Error(w, st.Message(), runtime.HTTPStatusFromCode(st.Code()))
+}
+
+func writeEvent(w http.ResponseWriter, event *apiv1.MarksEvent) error {
+	data := eventData{Type: event.GetType(), Subjects: event.GetSubjects(), Date: event.GetDate(), Period: event.GetPeriod()}
+
+	switch {
+	case event.GetDay() != nil:
+		data.Marks, data.WorstMark, data.FetchedAt = fromIntMarks(event.GetDay().GetMarks()), event.GetDay().GetWorstMark(), event.GetDay().GetFetchedAt().AsTime()
+	case event.GetAverage() != nil:
+		data.Marks, data.WorstMark, data.FetchedAt = event.GetAverage().GetMarks(), event.GetAverage().GetWorstMark(), event.GetAverage().GetFetchedAt().AsTime()
+	case event.GetFinal() != nil:
+		data.Marks, data.WorstMark, data.FetchedAt = fromIntMarks(event.GetFinal().GetMarks()), event.GetFinal().GetWorstMark(), event.GetFinal().GetFetchedAt().AsTime()
+	}
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %s\nevent: marks\ndata: %s\n\n", event.GetId(), payload)
+	return err
+}
+
+func fromIntMarks(marks map[string]*apiv1.LisOfIntMarks) map[string][]int32 {
+	result := make(map[string][]int32, len(marks))
+	for key, values := range marks {
+		result[key] = values.GetMarks()
+	}
+	return result
+}
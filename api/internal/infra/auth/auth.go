@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"Elschool-API/internal/infra/breaker"
 	"bytes"
 	"context"
 	"errors"
@@ -24,25 +25,66 @@ const (
 
 type UserAuthClient struct {
 	httpClient *http.Client
+	breaker    *breaker.Breaker
 	url        string
 }
 
-func New(url string) *UserAuthClient {
+func New(url string, breakerInfra *breaker.Breaker) *UserAuthClient {
 	return &UserAuthClient{
 		httpClient: &http.Client{
 			Timeout: 15 * time.Second, CheckRedirect: func(req *http.Request, via []*http.Request) error {
 				return http.ErrUseLastResponse
 			},
 		},
-		url: url,
+		breaker: breakerInfra,
+		url:     baseURL(url),
 	}
 }
 
+// baseURL makes https the scheme of a bare host. A url with a scheme is kept,
+// so the client can talk plain http to a local fake Elschool.
+func baseURL(url string) string {
+	if strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://") {
+		return strings.TrimSuffix(url, "/")
+	}
+	return HttpsPrefix + url
+}
+
 func (u *UserAuthClient) AuthStudent(ctx context.Context, login, password string) (jwt string, err error) {
 	const op = "infra.auth.AuthStudent"
 
+	err = u.breaker.Execute(func() error {
+		jwt, err = u.authStudent(ctx, login, password)
+		return err
+	})
+
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return jwt, nil
+}
+
+func (u *UserAuthClient) CheckToken(ctx context.Context, jwt string) (status bool, err error) {
+	const op = "infra.auth.CheckToken"
+
+	err = u.breaker.Execute(func() error {
+		status, err = u.checkToken(ctx, jwt)
+		return err
+	})
+
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return status, nil
+}
+
+func (u *UserAuthClient) authStudent(ctx context.Context, login, password string) (jwt string, err error) {
+	const op = "infra.auth.authStudent"
+
 	data := fmt.Sprintf("login=%s&password=%s&GoogleAuthCode=", login, password)
-	url := HttpsPrefix + u.url + LogonIndex
+	url := u.url + LogonIndex
 
 	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBufferString(data))
 	if err != nil {
@@ -57,6 +99,10 @@ func (u *UserAuthClient) AuthStudent(ctx context.Context, login, password string
 
 	defer resp.Body.Close()
 
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return "", fmt.Errorf("%s: %w: status %d", op, ErrUserAuthFailed, resp.StatusCode)
+	}
+
 	if resp.StatusCode == http.StatusFound {
 		body, err := io.ReadAll(resp.Body)
 		if err != nil {
@@ -86,12 +132,12 @@ func (u *UserAuthClient) AuthStudent(ctx context.Context, login, password string
 		}
 	}
 
-	return "", fmt.Errorf("%s: %w", op, ErrUserAuthFailed)
+	return "", breaker.Harmless(fmt.Errorf("%s: %w", op, ErrUserAuthFailed))
 }
 
-func (u *UserAuthClient) CheckToken(ctx context.Context, jwt string) (status bool, err error) {
-	const op = "infra.auth.CheckToken"
-	url := HttpsPrefix + u.url + PrivateOffice
+func (u *UserAuthClient) checkToken(ctx context.Context, jwt string) (status bool, err error) {
+	const op = "infra.auth.checkToken"
+	url := u.url + PrivateOffice
 
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
@@ -113,6 +159,10 @@ func (u *UserAuthClient) CheckToken(ctx context.Context, jwt string) (status boo
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return false, fmt.Errorf("%s: unexpected status %d", op, resp.StatusCode)
+	}
+
 	if resp.StatusCode == http.StatusOK {
 		return true, nil
 	}
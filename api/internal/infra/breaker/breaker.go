@@ -0,0 +1,86 @@
+package breaker
+
+import (
+	"Elschool-API/internal/config"
+	"Elschool-API/internal/infra/metrics"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sony/gobreaker"
+)
+
+var (
+	ErrOpen = errors.New("circuit breaker is open")
+	// ErrFailed wraps errors counted as upstream failures: network errors,
+	// timeouts and server errors. Harmless errors aren't wrapped.
+	ErrFailed = errors.New("upstream request failed")
+)
+
+// Breaker stops sending requests to an upstream after repeated failures
+// and lets a limited number of probes through once the open timeout passes.
+type Breaker struct {
+	cb *gobreaker.CircuitBreaker
+}
+
+// harmlessError marks an error that says nothing about upstream health,
+// e.g. wrong credentials, so it must not trip the breaker.
+type harmlessError struct {
+	err error
+}
+
+func (e harmlessError) Error() string {
+	return e.err.Error()
+}
+
+func (e harmlessError) Unwrap() error {
+	return e.err
+}
+
+func Harmless(err error) error {
+	return harmlessError{err: err}
+}
+
+func New(name string, cfg config.BreakerConfig, metricsInfra *metrics.Metrics) *Breaker {
+	metricsInfra.ElschoolBreakerState.WithLabelValues(name).Set(float64(gobreaker.StateClosed))
+
+	cb := gobreaker.NewCircuitBreaker(gobreaker.Settings{
+		Name:        name,
+		MaxRequests: cfg.HalfOpenRequests,
+		Timeout:     cfg.OpenTimeout,
+		ReadyToTrip: func(counts gobreaker.Counts) bool {
+			return counts.ConsecutiveFailures >= cfg.FailureThreshold
+		},
+		IsSuccessful: func(err error) bool {
+			var harmless harmlessError
+			return err == nil || errors.As(err, &harmless) || errors.Is(err, context.Canceled)
+		},
+		OnStateChange: func(name string, from gobreaker.State, to gobreaker.State) {
+			metricsInfra.ElschoolBreakerState.WithLabelValues(name).Set(float64(to))
+		},
+	})
+
+	return &Breaker{cb: cb}
+}
+
+func (b *Breaker) Execute(req func() error) error {
+	const op = "infra.breaker.Execute"
+
+	_, err := b.cb.Execute(func() (interface{}, error) {
+		return nil, req()
+	})
+
+	if errors.Is(err, gobreaker.ErrOpenState) || errors.Is(err, gobreaker.ErrTooManyRequests) {
+		return fmt.Errorf("%s: %w", op, ErrOpen)
+	}
+
+	var harmless harmlessError
+	if errors.As(err, &harmless) {
+		return harmless.err
+	}
+
+	if err != nil && !errors.Is(err, context.Canceled) {
+		return fmt.Errorf("%s: %w: %w", op, ErrFailed, err)
+	}
+
+	return err
+}
@@ -3,6 +3,7 @@ package cache
 import "errors"
 
 var (
-	ErrMarksNotFound = errors.New("marks not found")
-	ErrTokenNotFound = errors.New("token not found")
+	ErrMarksNotFound  = errors.New("marks not found")
+	ErrTokenNotFound  = errors.New("token not found")
+	ErrTicketNotFound = errors.New("ticket not found")
 )
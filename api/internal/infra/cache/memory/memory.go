@@ -0,0 +1,502 @@
+package memory
+
+import (
+	"Elschool-API/internal/config"
+	"Elschool-API/internal/domain/models"
+	"Elschool-API/internal/infra/cache"
+	"cmp"
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	lastPrefix      = "last:"
+	cleanupInterval = time.Minute
+	// entryOverhead approximates the bookkeeping cost of a single entry
+	// (list element, map bucket, expiry) on top of its key and value.
+	entryOverhead = 128
+)
+
+type entry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+func (e *entry) size() int64 {
+	return int64(len(e.key) + len(e.value) + entryOverhead)
+}
+
+type counter struct {
+	value     int64
+	expiresAt time.Time
+}
+
+// MemoryCache is an in-process LRU cache bounded both by the number of
+// entries and by the approximate amount of memory they occupy.
+type MemoryCache struct {
+	mu        sync.Mutex
+	items     map[string]*list.Element
+	order     *list.List
+	byStudent map[string]map[string]struct{}
+	used      int64
+	// counters are kept apart from the LRU, evicting one would reset a quota.
+	counters map[string]*counter
+	// tickets are kept apart too, evicting one would break a stream opening.
+	tickets map[string]models.StreamTicket
+
+	maxEntries     int
+	maxBytes       int64
+	tokenTTL       time.Duration
+	marksTTL       time.Duration
+	staleRetention time.Duration
+
+	stop chan struct{}
+	once sync.Once
+}
+
+func New(cfg *config.CacheConfig) *MemoryCache {
+	c := &MemoryCache{
+		items:          make(map[string]*list.Element),
+		order:          list.New(),
+		byStudent:      make(map[string]map[string]struct{}),
+		counters:       make(map[string]*counter),
+		tickets:        make(map[string]models.StreamTicket),
+		maxEntries:     cfg.MaxEntries,
+		maxBytes:       int64(cfg.MaxMemoryMB) << 20,
+		tokenTTL:       cfg.TokenTTL,
+		marksTTL:       cfg.MarksTTL,
+		staleRetention: cfg.StaleRetention,
+		stop:           make(chan struct{}),
+	}
+
+	go c.cleanup()
+
+	return c
+}
+
+func (c *MemoryCache) FindToken(ctx context.Context, studID string) (string, error) {
+	const op = "infra.cache.memory.FindToken"
+
+	value, ok := c.get(studID)
+	if !ok {
+		return "", fmt.Errorf("%s: %w", op, cache.ErrTokenNotFound)
+	}
+
+	return string(value), nil
+}
+
+func (c *MemoryCache) AddToken(ctx context.Context, studID, jwt string) error {
+	c.set(studID, []byte(jwt), c.tokenTTL)
+	return nil
+}
+
+func (c *MemoryCache) DeleteToken(ctx context.Context, studID string) error {
+	const op = "infra.cache.memory.DeleteToken"
+
+	if !c.delete(studID) {
+		return fmt.Errorf("%s: %w", op, cache.ErrTokenNotFound)
+	}
+	return nil
+}
+
+func (c *MemoryCache) SaveDayMarks(ctx context.Context, studID string, marks models.DayMarks) error {
+	const op = "infra.cache.memory.SaveDayMarks"
+
+	if err := c.saveMarks(dayMarksKey(studID, marks.Date), marks); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (c *MemoryCache) SaveAverageMarks(ctx context.Context, studID string, marks models.AverageMarks) error {
+	const op = "infra.cache.memory.SaveAverageMarks"
+
+	if err := c.saveMarks(averageMarksKey(studID, marks.Period), marks); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (c *MemoryCache) SaveFinalMarks(ctx context.Context, studID string, marks models.FinalMarks) error {
+	const op = "infra.cache.memory.SaveFinalMarks"
+
+	if err := c.saveMarks(finalMarksKey(studID), marks); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (c *MemoryCache) GetDayMarks(ctx context.Context, studID, date string) (models.DayMarks, error) {
+	const op = "infra.cache.memory.GetDayMarks"
+
+	var marks models.DayMarks
+	if err := c.getMarks(dayMarksKey(studID, date), &marks); err != nil {
+		return models.DayMarks{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return marks, nil
+}
+
+func (c *MemoryCache) GetAverageMarks(ctx context.Context, studID string, period int32) (models.AverageMarks, error) {
+	const op = "infra.cache.memory.GetAverageMarks"
+
+	var marks models.AverageMarks
+	if err := c.getMarks(averageMarksKey(studID, period), &marks); err != nil {
+		return models.AverageMarks{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return marks, nil
+}
+
+func (c *MemoryCache) GetFinalMarks(ctx context.Context, studID string) (models.FinalMarks, error) {
+	const op = "infra.cache.memory.GetFinalMarks"
+
+	var marks models.FinalMarks
+	if err := c.getMarks(finalMarksKey(studID), &marks); err != nil {
+		return models.FinalMarks{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return marks, nil
+}
+
+func (c *MemoryCache) GetLastDayMarks(ctx context.Context, studID, date string) (models.DayMarks, error) {
+	const op = "infra.cache.memory.GetLastDayMarks"
+
+	var marks models.DayMarks
+	if err := c.getMarks(lastKey(dayMarksKey(studID, date)), &marks); err != nil {
+		return models.DayMarks{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return marks, nil
+}
+
+func (c *MemoryCache) GetLastAverageMarks(ctx context.Context, studID string, period int32) (models.AverageMarks, error) {
+	const op = "infra.cache.memory.GetLastAverageMarks"
+
+	var marks models.AverageMarks
+	if err := c.getMarks(lastKey(averageMarksKey(studID, period)), &marks); err != nil {
+		return models.AverageMarks{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return marks, nil
+}
+
+func (c *MemoryCache) GetLastFinalMarks(ctx context.Context, studID string) (models.FinalMarks, error) {
+	const op = "infra.cache.memory.GetLastFinalMarks"
+
+	var marks models.FinalMarks
+	if err := c.getMarks(lastKey(finalMarksKey(studID)), &marks); err != nil {
+		return models.FinalMarks{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return marks, nil
+}
+
+// LastMarks returns all last known marks of the student.
+func (c *MemoryCache) LastMarks(ctx context.Context, studID string) (models.LastMarks, error) {
+	const op = "infra.cache.memory.LastMarks"
+
+	c.mu.Lock()
+	var keys []string
+	for key := range c.byStudent[studID] {
+		if strings.HasPrefix(key, lastPrefix) {
+			keys = append(keys, key)
+		}
+	}
+	c.mu.Unlock()
+
+	var last models.LastMarks
+	for _, key := range keys {
+		data, ok := c.get(key)
+		if !ok {
+			continue
+		}
+		if err := addLastMarks(&last, key, data); err != nil {
+			return models.LastMarks{}, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	sortLastMarks(&last)
+
+	return last, nil
+}
+
+// InvalidateStudent drops the token and all marks cached for the student.
+func (c *MemoryCache) InvalidateStudent(ctx context.Context, studID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key := range c.byStudent[studID] {
+		c.removeElement(c.items[key])
+	}
+
+	return nil
+}
+
+// CachedStudents returns the students something is cached for.
+func (c *MemoryCache) CachedStudents(ctx context.Context) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	students := make([]string, 0, len(c.byStudent))
+	for studID := range c.byStudent {
+		students = append(students, studID)
+	}
+
+	return students, nil
+}
+
+// IncrCounter increments the counter, starting it with the ttl if it isn't
+// there yet, and returns the new value.
+func (c *MemoryCache) IncrCounter(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	cnt, ok := c.counters[key]
+	if !ok || now.After(cnt.expiresAt) {
+		cnt = &counter{expiresAt: now.Add(ttl)}
+		c.counters[key] = cnt
+	}
+	cnt.value++
+
+	return cnt.value, nil
+}
+
+// GetCounter returns the value of the counter, zero if it isn't there.
+func (c *MemoryCache) GetCounter(ctx context.Context, key string) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cnt, ok := c.counters[key]
+	if !ok || time.Now().After(cnt.expiresAt) {
+		return 0, nil
+	}
+
+	return cnt.value, nil
+}
+
+// AddTicket keeps the ticket until it expires.
+func (c *MemoryCache) AddTicket(ctx context.Context, ticket models.StreamTicket) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.tickets[ticket.ID] = ticket
+
+	return nil
+}
+
+// TakeTicket returns the ticket and deletes it.
+func (c *MemoryCache) TakeTicket(ctx context.Context, id string) (models.StreamTicket, error) {
+	const op = "infra.cache.memory.TakeTicket"
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ticket, ok := c.tickets[id]
+	delete(c.tickets, id)
+
+	if !ok || !time.Now().Before(ticket.ExpiresAt) {
+		return models.StreamTicket{}, fmt.Errorf("%s: %w", op, cache.ErrTicketNotFound)
+	}
+
+	return ticket, nil
+}
+
+// Ping always succeeds, the cache lives in the process.
+func (c *MemoryCache) Ping(ctx context.Context) error {
+	return nil
+}
+
+// Close stops the background cleanup of expired entries.
+func (c *MemoryCache) Close() error {
+	c.once.Do(func() {
+		close(c.stop)
+	})
+	return nil
+}
+
+func (c *MemoryCache) saveMarks(key string, marks any) error {
+	data, err := json.Marshal(marks)
+	if err != nil {
+		return err
+	}
+
+	c.set(key, data, c.marksTTL)
+	c.set(lastKey(key), data, c.staleRetention)
+
+	return nil
+}
+
+func (c *MemoryCache) getMarks(key string, marks any) error {
+	data, ok := c.get(key)
+	if !ok {
+		return cache.ErrMarksNotFound
+	}
+
+	return json.Unmarshal(data, marks)
+}
+
+func (c *MemoryCache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	e := elem.Value.(*entry)
+	if time.Now().After(e.expiresAt) {
+		c.removeElement(elem)
+		return nil, false
+	}
+
+	c.order.MoveToFront(elem)
+
+	return e.value, true
+}
+
+func (c *MemoryCache) set(key string, value []byte, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		c.removeElement(elem)
+	}
+
+	e := &entry{key: key, value: value, expiresAt: time.Now().Add(ttl)}
+	if c.maxBytes > 0 && e.size() > c.maxBytes {
+		return
+	}
+
+	c.items[key] = c.order.PushFront(e)
+	c.used += e.size()
+
+	studID := studentOf(key)
+	if c.byStudent[studID] == nil {
+		c.byStudent[studID] = make(map[string]struct{})
+	}
+	c.byStudent[studID][key] = struct{}{}
+
+	for c.overflowed() {
+		c.removeElement(c.order.Back())
+	}
+}
+
+func (c *MemoryCache) delete(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		return false
+	}
+
+	c.removeElement(elem)
+
+	return true
+}
+
+func (c *MemoryCache) overflowed() bool {
+	return (c.maxEntries > 0 && c.order.Len() > c.maxEntries) || (c.maxBytes > 0 && c.used > c.maxBytes)
+}
+
+func (c *MemoryCache) removeElement(elem *list.Element) {
+	e := c.order.Remove(elem).(*entry)
+	delete(c.items, e.key)
+	c.used -= e.size()
+
+	studID := studentOf(e.key)
+	delete(c.byStudent[studID], e.key)
+	if len(c.byStudent[studID]) == 0 {
+		delete(c.byStudent, studID)
+	}
+}
+
+func (c *MemoryCache) cleanup() {
+	ticker := time.NewTicker(cleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case now := <-ticker.C:
+			c.mu.Lock()
+			for elem := c.order.Back(); elem != nil; {
+				prev := elem.Prev()
+				if now.After(elem.Value.(*entry).expiresAt) {
+					c.removeElement(elem)
+				}
+				elem = prev
+			}
+			for key, cnt := range c.counters {
+				if now.After(cnt.expiresAt) {
+					delete(c.counters, key)
+				}
+			}
+			for id, ticket := range c.tickets {
+				if !now.Before(ticket.ExpiresAt) {
+					delete(c.tickets, id)
+				}
+			}
+			c.mu.Unlock()
+		}
+	}
+}
+
+func dayMarksKey(studID, date string) string {
+	return studID + ":day_marks:" + date
+}
+
+func averageMarksKey(studID string, period int32) string {
+	return studID + ":average_marks:" + strconv.Itoa(int(period))
+}
+
+func finalMarksKey(studID string) string {
+	return studID + ":final_marks"
+}
+
+func lastKey(key string) string {
+	return lastPrefix + key
+}
+
+// addLastMarks adds the marks stored under the last known marks key.
+func addLastMarks(last *models.LastMarks, key string, data []byte) error {
+	switch {
+	case strings.Contains(key, ":day_marks:"):
+		var marks models.DayMarks
+		if err := json.Unmarshal(data, &marks); err != nil {
+			return err
+		}
+		last.Day = append(last.Day, marks)
+	case strings.Contains(key, ":average_marks:"):
+		var marks models.AverageMarks
+		if err := json.Unmarshal(data, &marks); err != nil {
+			return err
+		}
+		last.Average = append(last.Average, marks)
+	case strings.HasSuffix(key, ":final_marks"):
+		var marks models.FinalMarks
+		if err := json.Unmarshal(data, &marks); err != nil {
+			return err
+		}
+		last.Final = &marks
+	}
+	return nil
+}
+
+func sortLastMarks(last *models.LastMarks) {
+	slices.SortFunc(last.Day, func(a, b models.DayMarks) int { return a.FetchedAt.Compare(b.FetchedAt) })
+	slices.SortFunc(last.Average, func(a, b models.AverageMarks) int { return cmp.Compare(a.Period, b.Period) })
+}
+
+func studentOf(key string) string {
+	key = strings.TrimPrefix(key, lastPrefix)
+	if i := strings.IndexByte(key, ':'); i != -1 {
+		return key[:i]
+	}
+	return key
+}
@@ -4,20 +4,28 @@ import (
 	"Elschool-API/internal/config"
 	"Elschool-API/internal/domain/models"
 	"Elschool-API/internal/infra/cache"
+	"cmp"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 )
 
 type RedisCache struct {
-	conn *redis.Client
+	conn           *redis.Client
+	tokenTTL       time.Duration
+	marksTTL       time.Duration
+	staleRetention time.Duration
 }
 
-func New(conn *redis.Client) *RedisCache {
-	return &RedisCache{conn}
+func New(conn *redis.Client, cfg *config.CacheConfig) *RedisCache {
+	return &RedisCache{conn: conn, tokenTTL: cfg.TokenTTL, marksTTL: cfg.MarksTTL, staleRetention: cfg.StaleRetention}
 }
 
 func (r *RedisCache) FindToken(ctx context.Context, studID string) (string, error) {
@@ -37,7 +45,7 @@ func (r *RedisCache) FindToken(ctx context.Context, studID string) (string, erro
 func (r *RedisCache) AddToken(ctx context.Context, studID, jwt string) error {
 	const op = "infra.cache.AddToken"
 
-	err := r.conn.Set(ctx, studID, jwt, 72*time.Hour).Err()
+	err := r.conn.Set(ctx, studID, jwt, r.tokenTTL).Err()
 	if err != nil && !errors.Is(err, redis.Nil) {
 		return fmt.Errorf("%s: %w", op, err)
 	}
@@ -59,13 +67,7 @@ func (r *RedisCache) DeleteToken(ctx context.Context, studID string) error {
 func (r *RedisCache) SaveDayMarks(ctx context.Context, studID string, marks models.DayMarks) error {
 	const op = "infra.cache.SaveDayMarks"
 
-	key := studID + ":day_marks:" + marks.Date
-	data, err := json.Marshal(marks)
-	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
-	}
-	err = r.conn.Set(ctx, key, data, 7*time.Second).Err()
-	if err != nil {
+	if err := r.saveMarks(ctx, dayMarksKey(studID, marks.Date), marks); err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 	return nil
@@ -74,13 +76,7 @@ func (r *RedisCache) SaveDayMarks(ctx context.Context, studID string, marks mode
 func (r *RedisCache) SaveAverageMarks(ctx context.Context, studID string, marks models.AverageMarks) error {
 	const op = "infra.cache.SaveAverageMarks"
 
-	key := studID + ":average_marks:" + string(marks.Period)
-	data, err := json.Marshal(marks)
-	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
-	}
-	err = r.conn.Set(ctx, key, data, 7*time.Second).Err()
-	if err != nil {
+	if err := r.saveMarks(ctx, averageMarksKey(studID, marks.Period), marks); err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 	return nil
@@ -89,13 +85,7 @@ func (r *RedisCache) SaveAverageMarks(ctx context.Context, studID string, marks
 func (r *RedisCache) SaveFinalMarks(ctx context.Context, studID string, marks models.FinalMarks) error {
 	const op = "infra.cache.SaveFinalMarks"
 
-	key := studID + ":final_marks"
-	data, err := json.Marshal(marks)
-	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
-	}
-	err = r.conn.Set(ctx, key, data, 7*time.Second).Err()
-	if err != nil {
+	if err := r.saveMarks(ctx, finalMarksKey(studID), marks); err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 	return nil
@@ -104,19 +94,9 @@ func (r *RedisCache) SaveFinalMarks(ctx context.Context, studID string, marks mo
 func (r *RedisCache) GetDayMarks(ctx context.Context, studID, date string) (models.DayMarks, error) {
 	const op = "infra.cache.GetDayMarks"
 
-	key := studID + ":day_marks:" + date
-	result, err := r.conn.Get(ctx, key).Result()
-	if err != nil {
-		if errors.Is(err, redis.Nil) {
-			return models.DayMarks{}, fmt.Errorf("%s: %w", op, cache.ErrMarksNotFound)
-		}
-		return models.DayMarks{}, fmt.Errorf("%s: %w", op, err)
-	}
-
 	var marks models.DayMarks
-	err = json.Unmarshal([]byte(result), &marks)
-	if err != nil {
-		return models.DayMarks{}, err
+	if err := r.getMarks(ctx, dayMarksKey(studID, date), &marks); err != nil {
+		return models.DayMarks{}, fmt.Errorf("%s: %w", op, err)
 	}
 	return marks, nil
 }
@@ -124,41 +104,307 @@ func (r *RedisCache) GetDayMarks(ctx context.Context, studID, date string) (mode
 func (r *RedisCache) GetAverageMarks(ctx context.Context, studID string, period int32) (models.AverageMarks, error) {
 	const op = "infra.cache.GetAverageMarks"
 
-	key := studID + ":average_marks:" + string(period)
-	result, err := r.conn.Get(ctx, key).Result()
-	if err != nil {
-		if errors.Is(err, redis.Nil) {
-			return models.AverageMarks{}, fmt.Errorf("%s: %w", op, cache.ErrMarksNotFound)
-		}
+	var marks models.AverageMarks
+	if err := r.getMarks(ctx, averageMarksKey(studID, period), &marks); err != nil {
 		return models.AverageMarks{}, fmt.Errorf("%s: %w", op, err)
 	}
+	return marks, nil
+}
+
+func (r *RedisCache) GetFinalMarks(ctx context.Context, studID string) (models.FinalMarks, error) {
+	const op = "infra.cache.GetFinalMarks"
+
+	var marks models.FinalMarks
+	if err := r.getMarks(ctx, finalMarksKey(studID), &marks); err != nil {
+		return models.FinalMarks{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return marks, nil
+}
+
+func (r *RedisCache) GetLastDayMarks(ctx context.Context, studID, date string) (models.DayMarks, error) {
+	const op = "infra.cache.GetLastDayMarks"
+
+	var marks models.DayMarks
+	if err := r.getMarks(ctx, lastKey(dayMarksKey(studID, date)), &marks); err != nil {
+		return models.DayMarks{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return marks, nil
+}
+
+func (r *RedisCache) GetLastAverageMarks(ctx context.Context, studID string, period int32) (models.AverageMarks, error) {
+	const op = "infra.cache.GetLastAverageMarks"
 
 	var marks models.AverageMarks
-	err = json.Unmarshal([]byte(result), &marks)
-	if err != nil {
+	if err := r.getMarks(ctx, lastKey(averageMarksKey(studID, period)), &marks); err != nil {
 		return models.AverageMarks{}, fmt.Errorf("%s: %w", op, err)
 	}
 	return marks, nil
 }
 
-func (r *RedisCache) GetFinalMarks(ctx context.Context, studID string) (models.FinalMarks, error) {
-	const op = "infra.cache.GetFinalMarks"
+func (r *RedisCache) GetLastFinalMarks(ctx context.Context, studID string) (models.FinalMarks, error) {
+	const op = "infra.cache.GetLastFinalMarks"
+
+	var marks models.FinalMarks
+	if err := r.getMarks(ctx, lastKey(finalMarksKey(studID)), &marks); err != nil {
+		return models.FinalMarks{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return marks, nil
+}
+
+// saveMarks stores marks under a short-lived key used for deduplication of
+// requests and under a long-lived one served when elschool is unavailable.
+func (r *RedisCache) saveMarks(ctx context.Context, key string, marks any) error {
+	data, err := json.Marshal(marks)
+	if err != nil {
+		return err
+	}
+
+	pipe := r.conn.TxPipeline()
+	pipe.Set(ctx, key, data, r.marksTTL)
+	pipe.Set(ctx, lastKey(key), data, r.staleRetention)
 
-	key := studID + ":final_marks"
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (r *RedisCache) getMarks(ctx context.Context, key string, marks any) error {
 	result, err := r.conn.Get(ctx, key).Result()
 	if err != nil {
 		if errors.Is(err, redis.Nil) {
-			return models.FinalMarks{}, fmt.Errorf("%s: %w", op, cache.ErrMarksNotFound)
+			return cache.ErrMarksNotFound
 		}
-		return models.FinalMarks{}, fmt.Errorf("%s: %w", op, err)
+		return err
 	}
 
-	var marks models.FinalMarks
-	err = json.Unmarshal([]byte(result), &marks)
+	return json.Unmarshal([]byte(result), marks)
+}
+
+// LastMarks returns all last known marks of the student.
+func (r *RedisCache) LastMarks(ctx context.Context, studID string) (models.LastMarks, error) {
+	const op = "infra.cache.LastMarks"
+
+	var keys []string
+	iter := r.conn.Scan(ctx, 0, lastKey(studID+":*"), 100).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return models.LastMarks{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var last models.LastMarks
+	if len(keys) == 0 {
+		return last, nil
+	}
+
+	values, err := r.conn.MGet(ctx, keys...).Result()
 	if err != nil {
-		return models.FinalMarks{}, fmt.Errorf("%s: %w", op, err)
+		return models.LastMarks{}, fmt.Errorf("%s: %w", op, err)
 	}
-	return marks, nil
+
+	for i, value := range values {
+		// The key may have expired since it was listed.
+		data, ok := value.(string)
+		if !ok {
+			continue
+		}
+		if err := addLastMarks(&last, keys[i], []byte(data)); err != nil {
+			return models.LastMarks{}, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	sortLastMarks(&last)
+
+	return last, nil
+}
+
+// InvalidateStudent drops the token and all marks cached for the student,
+// including the last known ones.
+func (r *RedisCache) InvalidateStudent(ctx context.Context, studID string) error {
+	const op = "infra.cache.InvalidateStudent"
+
+	keys := []string{studID}
+
+	for _, pattern := range []string{studID + ":*", lastKey(studID + ":*")} {
+		iter := r.conn.Scan(ctx, 0, pattern, 100).Iterator()
+		for iter.Next(ctx) {
+			keys = append(keys, iter.Val())
+		}
+		if err := iter.Err(); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := r.conn.Del(ctx, keys...).Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// CachedStudents returns the students something is cached for. Keys of
+// other kinds sharing the database are skipped.
+func (r *RedisCache) CachedStudents(ctx context.Context) ([]string, error) {
+	const op = "infra.cache.CachedStudents"
+
+	seen := make(map[string]struct{})
+	var students []string
+
+	iter := r.conn.Scan(ctx, 0, "*", 100).Iterator()
+	for iter.Next(ctx) {
+		studID := studentOf(iter.Val())
+		if _, ok := seen[studID]; ok {
+			continue
+		}
+		if _, err := uuid.Parse(studID); err != nil {
+			continue
+		}
+		seen[studID] = struct{}{}
+		students = append(students, studID)
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return students, nil
+}
+
+// IncrCounter increments the counter and returns the new value. The ttl is
+// renewed on every call, counters are expected to be keyed by their window.
+func (r *RedisCache) IncrCounter(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	const op = "infra.cache.IncrCounter"
+
+	var incr *redis.IntCmd
+	_, err := r.conn.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.Incr(ctx, key)
+		pipe.Expire(ctx, key, ttl)
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return incr.Val(), nil
+}
+
+// GetCounter returns the value of the counter, zero if it isn't there.
+func (r *RedisCache) GetCounter(ctx context.Context, key string) (int64, error) {
+	const op = "infra.cache.GetCounter"
+
+	value, err := r.conn.Get(ctx, key).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return value, nil
+}
+
+// AddTicket keeps the ticket until it expires.
+func (r *RedisCache) AddTicket(ctx context.Context, ticket models.StreamTicket) error {
+	const op = "infra.cache.AddTicket"
+
+	ttl := time.Until(ticket.ExpiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(ticket)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = r.conn.Set(ctx, ticketKey(ticket.ID), data, ttl).Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// TakeTicket returns the ticket and deletes it at once, so of replicas
+// racing for a ticket only one gets it.
+func (r *RedisCache) TakeTicket(ctx context.Context, id string) (models.StreamTicket, error) {
+	const op = "infra.cache.TakeTicket"
+
+	data, err := r.conn.GetDel(ctx, ticketKey(id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return models.StreamTicket{}, fmt.Errorf("%s: %w", op, cache.ErrTicketNotFound)
+	}
+	if err != nil {
+		return models.StreamTicket{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var ticket models.StreamTicket
+	if err = json.Unmarshal(data, &ticket); err != nil {
+		return models.StreamTicket{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return ticket, nil
+}
+
+func dayMarksKey(studID, date string) string {
+	return studID + ":day_marks:" + date
+}
+
+func averageMarksKey(studID string, period int32) string {
+	return studID + ":average_marks:" + strconv.Itoa(int(period))
+}
+
+func finalMarksKey(studID string) string {
+	return studID + ":final_marks"
+}
+
+func lastKey(key string) string {
+	return "last:" + key
+}
+
+func ticketKey(id string) string {
+	return "ticket:" + id
+}
+
+// addLastMarks adds the marks stored under the last known marks key.
+func addLastMarks(last *models.LastMarks, key string, data []byte) error {
+	switch {
+	case strings.Contains(key, ":day_marks:"):
+		var marks models.DayMarks
+		if err := json.Unmarshal(data, &marks); err != nil {
+			return err
+		}
+		last.Day = append(last.Day, marks)
+	case strings.Contains(key, ":average_marks:"):
+		var marks models.AverageMarks
+		if err := json.Unmarshal(data, &marks); err != nil {
+			return err
+		}
+		last.Average = append(last.Average, marks)
+	case strings.HasSuffix(key, ":final_marks"):
+		var marks models.FinalMarks
+		if err := json.Unmarshal(data, &marks); err != nil {
+			return err
+		}
+		last.Final = &marks
+	}
+	return nil
+}
+
+func sortLastMarks(last *models.LastMarks) {
+	slices.SortFunc(last.Day, func(a, b models.DayMarks) int { return a.FetchedAt.Compare(b.FetchedAt) })
+	slices.SortFunc(last.Average, func(a, b models.AverageMarks) int { return cmp.Compare(a.Period, b.Period) })
+}
+
+func studentOf(key string) string {
+	key = strings.TrimPrefix(key, "last:")
+	if i := strings.IndexByte(key, ':'); i != -1 {
+		return key[:i]
+	}
+	return key
+}
+
+func (r *RedisCache) Ping(ctx context.Context) error {
+	return r.conn.Ping(ctx).Err()
+}
+
+func (r *RedisCache) Close() error {
+	return r.conn.Close()
 }
 
 func InitCache(cfg *config.CacheConfig) (*redis.Client, error) {
@@ -0,0 +1,262 @@
+package tiered
+
+import (
+	"Elschool-API/internal/domain/models"
+	"Elschool-API/internal/infra/cache/memory"
+	rediscache "Elschool-API/internal/infra/cache/redis"
+	"context"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+const (
+	invalidationChannel = "elschool-api:cache:invalidate"
+	publishTimeout      = time.Second
+)
+
+// TieredCache serves reads from an in-process L1 in front of the shared Redis L2.
+// Every write goes to both tiers and is announced over Redis pub/sub, so other
+// replicas drop the student from their L1. Messages lost while the subscription
+// reconnects are covered by the short L1 TTL.
+type TieredCache struct {
+	log        *slog.Logger
+	l1         *memory.MemoryCache
+	l2         *rediscache.RedisCache
+	conn       *redis.Client
+	pubsub     *redis.PubSub
+	instanceID string
+}
+
+func New(log *slog.Logger, l1 *memory.MemoryCache, l2 *rediscache.RedisCache, conn *redis.Client) *TieredCache {
+	c := &TieredCache{
+		log:        log.With(slog.String("component", "infra.cache.tiered")),
+		l1:         l1,
+		l2:         l2,
+		conn:       conn,
+		pubsub:     conn.Subscribe(context.Background(), invalidationChannel),
+		instanceID: uuid.NewString(),
+	}
+
+	go c.listen()
+
+	return c
+}
+
+func (c *TieredCache) FindToken(ctx context.Context, studID string) (string, error) {
+	if jwt, err := c.l1.FindToken(ctx, studID); err == nil {
+		return jwt, nil
+	}
+
+	jwt, err := c.l2.FindToken(ctx, studID)
+	if err != nil {
+		return "", err
+	}
+
+	_ = c.l1.AddToken(ctx, studID, jwt)
+
+	return jwt, nil
+}
+
+func (c *TieredCache) AddToken(ctx context.Context, studID, jwt string) error {
+	if err := c.l2.AddToken(ctx, studID, jwt); err != nil {
+		return err
+	}
+
+	_ = c.l1.AddToken(ctx, studID, jwt)
+	c.publish(ctx, studID)
+
+	return nil
+}
+
+// DeleteToken deletes from L2 first, so a concurrent read can't refill L1
+// with the token between the deletes.
+func (c *TieredCache) DeleteToken(ctx context.Context, studID string) error {
+	err := c.l2.DeleteToken(ctx, studID)
+
+	_ = c.l1.DeleteToken(ctx, studID)
+	c.publish(ctx, studID)
+
+	return err
+}
+
+func (c *TieredCache) SaveDayMarks(ctx context.Context, studID string, marks models.DayMarks) error {
+	if err := c.l2.SaveDayMarks(ctx, studID, marks); err != nil {
+		return err
+	}
+
+	_ = c.l1.SaveDayMarks(ctx, studID, marks)
+	c.publish(ctx, studID)
+
+	return nil
+}
+
+func (c *TieredCache) SaveAverageMarks(ctx context.Context, studID string, marks models.AverageMarks) error {
+	if err := c.l2.SaveAverageMarks(ctx, studID, marks); err != nil {
+		return err
+	}
+
+	_ = c.l1.SaveAverageMarks(ctx, studID, marks)
+	c.publish(ctx, studID)
+
+	return nil
+}
+
+func (c *TieredCache) SaveFinalMarks(ctx context.Context, studID string, marks models.FinalMarks) error {
+	if err := c.l2.SaveFinalMarks(ctx, studID, marks); err != nil {
+		return err
+	}
+
+	_ = c.l1.SaveFinalMarks(ctx, studID, marks)
+	c.publish(ctx, studID)
+
+	return nil
+}
+
+func (c *TieredCache) GetDayMarks(ctx context.Context, studID, date string) (models.DayMarks, error) {
+	if marks, err := c.l1.GetDayMarks(ctx, studID, date); err == nil {
+		return marks, nil
+	}
+
+	marks, err := c.l2.GetDayMarks(ctx, studID, date)
+	if err != nil {
+		return models.DayMarks{}, err
+	}
+
+	_ = c.l1.SaveDayMarks(ctx, studID, marks)
+
+	return marks, nil
+}
+
+func (c *TieredCache) GetAverageMarks(ctx context.Context, studID string, period int32) (models.AverageMarks, error) {
+	if marks, err := c.l1.GetAverageMarks(ctx, studID, period); err == nil {
+		return marks, nil
+	}
+
+	marks, err := c.l2.GetAverageMarks(ctx, studID, period)
+	if err != nil {
+		return models.AverageMarks{}, err
+	}
+
+	_ = c.l1.SaveAverageMarks(ctx, studID, marks)
+
+	return marks, nil
+}
+
+func (c *TieredCache) GetFinalMarks(ctx context.Context, studID string) (models.FinalMarks, error) {
+	if marks, err := c.l1.GetFinalMarks(ctx, studID); err == nil {
+		return marks, nil
+	}
+
+	marks, err := c.l2.GetFinalMarks(ctx, studID)
+	if err != nil {
+		return models.FinalMarks{}, err
+	}
+
+	_ = c.l1.SaveFinalMarks(ctx, studID, marks)
+
+	return marks, nil
+}
+
+// The last known marks are only read when elschool is failing,
+// so they are not promoted to L1 to keep it for hot fresh data.
+
+func (c *TieredCache) GetLastDayMarks(ctx context.Context, studID, date string) (models.DayMarks, error) {
+	if marks, err := c.l1.GetLastDayMarks(ctx, studID, date); err == nil {
+		return marks, nil
+	}
+
+	return c.l2.GetLastDayMarks(ctx, studID, date)
+}
+
+func (c *TieredCache) GetLastAverageMarks(ctx context.Context, studID string, period int32) (models.AverageMarks, error) {
+	if marks, err := c.l1.GetLastAverageMarks(ctx, studID, period); err == nil {
+		return marks, nil
+	}
+
+	return c.l2.GetLastAverageMarks(ctx, studID, period)
+}
+
+func (c *TieredCache) GetLastFinalMarks(ctx context.Context, studID string) (models.FinalMarks, error) {
+	if marks, err := c.l1.GetLastFinalMarks(ctx, studID); err == nil {
+		return marks, nil
+	}
+
+	return c.l2.GetLastFinalMarks(ctx, studID)
+}
+
+// LastMarks returns the last known marks from Redis, which has those of
+// every replica.
+func (c *TieredCache) LastMarks(ctx context.Context, studID string) (models.LastMarks, error) {
+	return c.l2.LastMarks(ctx, studID)
+}
+
+func (c *TieredCache) InvalidateStudent(ctx context.Context, studID string) error {
+	err := c.l2.InvalidateStudent(ctx, studID)
+
+	_ = c.l1.InvalidateStudent(ctx, studID)
+	c.publish(ctx, studID)
+
+	return err
+}
+
+// CachedStudents returns the students cached in Redis. The L1 of this
+// replica is a subset of it up to the L1 TTL.
+func (c *TieredCache) CachedStudents(ctx context.Context) ([]string, error) {
+	return c.l2.CachedStudents(ctx)
+}
+
+// IncrCounter counts in Redis only, so that all replicas share the counter.
+func (c *TieredCache) IncrCounter(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	return c.l2.IncrCounter(ctx, key, ttl)
+}
+
+func (c *TieredCache) GetCounter(ctx context.Context, key string) (int64, error) {
+	return c.l2.GetCounter(ctx, key)
+}
+
+// AddTicket and TakeTicket skip L1, a ticket is taken once by any replica.
+func (c *TieredCache) AddTicket(ctx context.Context, ticket models.StreamTicket) error {
+	return c.l2.AddTicket(ctx, ticket)
+}
+
+func (c *TieredCache) TakeTicket(ctx context.Context, id string) (models.StreamTicket, error) {
+	return c.l2.TakeTicket(ctx, id)
+}
+
+func (c *TieredCache) Ping(ctx context.Context) error {
+	return c.l2.Ping(ctx)
+}
+
+func (c *TieredCache) Close() error {
+	if err := c.pubsub.Close(); err != nil {
+		c.log.Warn("failed to close invalidation subscription", "error", err)
+	}
+
+	_ = c.l1.Close()
+
+	return c.l2.Close()
+}
+
+// publish tells other replicas to drop the student from their L1.
+func (c *TieredCache) publish(ctx context.Context, studID string) {
+	pubCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), publishTimeout)
+	defer cancel()
+
+	if err := c.conn.Publish(pubCtx, invalidationChannel, c.instanceID+" "+studID).Err(); err != nil {
+		c.log.Warn("failed to publish cache invalidation", slog.String("student", studID), "error", err)
+	}
+}
+
+func (c *TieredCache) listen() {
+	for msg := range c.pubsub.Channel() {
+		origin, studID, ok := strings.Cut(msg.Payload, " ")
+		if !ok || origin == c.instanceID {
+			continue
+		}
+
+		_ = c.l1.InvalidateStudent(context.Background(), studID)
+	}
+}
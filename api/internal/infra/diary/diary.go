@@ -0,0 +1,106 @@
+package diary
+
+import (
+	"Elschool-API/internal/domain/models"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+var (
+	ErrUnknownProvider = errors.New("unknown diary provider")
+	ErrUnknownInstance = errors.New("unknown diary instance")
+	// ErrSessionExpired means the session can't be refreshed anymore and the
+	// student has to be authenticated anew.
+	ErrSessionExpired = errors.New("diary session expired")
+	// ErrMarkupChanged means the diary pages don't look the way the provider
+	// expects anymore, so no marks can be read from them.
+	ErrMarkupChanged = errors.New("diary markup changed")
+	// ErrUnavailable means the system couldn't be reached: it is down,
+	// answers with server errors or times out. Unlike other errors it says
+	// nothing about the student, so marks known before may be served.
+	ErrUnavailable = errors.New("diary is unavailable")
+)
+
+// Provider is a school e-diary system marks are fetched from. A system may
+// run several instances, e.g. regional ones, each call names the instance
+// the student is bound to. Sessions are opaque to the caller.
+type Provider interface {
+	// Instances returns names of the instances, the default one first.
+	Instances() []string
+	Capabilities() models.DiaryCapabilities
+
+	Authenticate(ctx context.Context, instance, login, password string) (session string, err error)
+	// RefreshSession makes sure the session is still good, extending it if
+	// the system needs that. The session to go on with is returned.
+	RefreshSession(ctx context.Context, instance, session string) (refreshed string, err error)
+	// SessionExpiry tells when the session runs out, if the session carries
+	// that. Sessions are trusted without refreshing until shortly before.
+	SessionExpiry(session string) (expiry time.Time, ok bool)
+
+	// Fetches fail with ErrSessionExpired when the session is rejected.
+	FetchDayMarks(ctx context.Context, instance, session, date string) (marks models.DayMarks, err error)
+	FetchAverageMarks(ctx context.Context, instance, session string, period int32) (marks models.AverageMarks, err error)
+	FetchFinalMarks(ctx context.Context, instance, session string) (marks models.FinalMarks, err error)
+
+	// Ping checks that every instance is reachable.
+	Ping(ctx context.Context) error
+}
+
+// Registry holds the providers by name. Providers are registered at startup
+// only, so it isn't guarded.
+type Registry struct {
+	providers map[string]Provider
+}
+
+func NewRegistry() *Registry {
+	return &Registry{providers: make(map[string]Provider)}
+}
+
+// Register adds the provider under the name. Registering a name twice is a
+// programming error.
+func (r *Registry) Register(name string, provider Provider) {
+	if _, ok := r.providers[name]; ok {
+		panic(fmt.Sprintf("diary provider %q registered twice", name))
+	}
+	r.providers[name] = provider
+}
+
+func (r *Registry) Provider(name string) (Provider, error) {
+	const op = "infra.diary.Provider"
+
+	provider, ok := r.providers[name]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w: %q", op, ErrUnknownProvider, name)
+	}
+	return provider, nil
+}
+
+// Providers returns names of the registered providers, the default one
+// first.
+func (r *Registry) Providers() []string {
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		if name != models.DefaultProvider {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	if _, ok := r.providers[models.DefaultProvider]; ok {
+		names = append([]string{models.DefaultProvider}, names...)
+	}
+	return names
+}
+
+// Ping checks every provider, the first one failing is reported.
+func (r *Registry) Ping(ctx context.Context) error {
+	for _, name := range r.Providers() {
+		if err := r.providers[name].Ping(ctx); err != nil {
+			return fmt.Errorf("provider %s: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package elschool
+
+import (
+	"Elschool-API/internal/config"
+	"Elschool-API/internal/domain/models"
+	"Elschool-API/internal/infra/breaker"
+	"Elschool-API/internal/infra/diary"
+	"Elschool-API/internal/infra/fetcher"
+	"Elschool-API/internal/infra/instances"
+	"Elschool-API/internal/infra/metrics"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Name is the name Elschool is registered under. It is the default provider.
+const Name = models.DefaultProvider
+
+// Provider is Elschool as a diary provider. Sessions are the JWT cookies
+// Elschool issues on logon.
+type Provider struct {
+	router *instances.Router
+}
+
+func New(cfg config.InfraConfig, metricsInfra *metrics.Metrics) *Provider {
+	return &Provider{router: instances.New(cfg, metricsInfra)}
+}
+
+func (p *Provider) Instances() []string {
+	return p.router.Instances()
+}
+
+func (p *Provider) Capabilities() models.DiaryCapabilities {
+	return models.DiaryCapabilities{DayMarks: true, AverageMarks: true, FinalMarks: true}
+}
+
+func (p *Provider) Authenticate(ctx context.Context, instance, login, password string) (session string, err error) {
+	session, err = p.router.AuthStudent(ctx, instance, login, password)
+	return session, unavailable(err)
+}
+
+// RefreshSession checks the JWT. Elschool doesn't extend sessions, a JWT is
+// good until it is rejected.
+func (p *Provider) RefreshSession(ctx context.Context, instance, session string) (refreshed string, err error) {
+	const op = "infra.diary.elschool.RefreshSession"
+
+	valid, err := p.router.CheckToken(ctx, instance, session)
+	if err != nil {
+		return "", unavailable(err)
+	}
+	if !valid {
+		return "", fmt.Errorf("%s: %w", op, diary.ErrSessionExpired)
+	}
+	return session, nil
+}
+
+// SessionExpiry reads the exp claim of the JWT. The signature isn't checked,
+// Elschool does that, the claim only saves asking it about a fresh JWT.
+func (p *Provider) SessionExpiry(session string) (expiry time.Time, ok bool) {
+	parts := strings.Split(session, ".")
+	if len(parts) != 3 {
+		return time.Time{}, false
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err = json.Unmarshal(payload, &claims); err != nil || claims.Exp == 0 {
+		return time.Time{}, false
+	}
+
+	return time.Unix(claims.Exp, 0), true
+}
+
+func (p *Provider) FetchDayMarks(ctx context.Context, instance, session, date string) (marks models.DayMarks, err error) {
+	marks, err = p.router.FetchDayMarks(ctx, instance, session, date)
+	return marks, fetchError(err)
+}
+
+func (p *Provider) FetchAverageMarks(ctx context.Context, instance, session string, period int32) (marks models.AverageMarks, err error) {
+	marks, err = p.router.FetchAverageMarks(ctx, instance, session, period)
+	return marks, fetchError(err)
+}
+
+func (p *Provider) FetchFinalMarks(ctx context.Context, instance, session string) (marks models.FinalMarks, err error) {
+	marks, err = p.router.FetchFinalMarks(ctx, instance, session)
+	return marks, fetchError(err)
+}
+
+func (p *Provider) Ping(ctx context.Context) error {
+	return p.router.Ping(ctx)
+}
+
+// fetchError reports a JWT Elschool rejected as an expired session, other
+// errors go through unavailable.
+func fetchError(err error) error {
+	if errors.Is(err, fetcher.ErrSessionRejected) {
+		return fmt.Errorf("%w: %w", diary.ErrSessionExpired, err)
+	}
+	return unavailable(err)
+}
+
+// unavailable marks failures the breaker counts, and its rejections, as
+// Elschool being unavailable.
+func unavailable(err error) error {
+	if errors.Is(err, breaker.ErrOpen) || errors.Is(err, breaker.ErrFailed) {
+		return fmt.Errorf("%w: %w", diary.ErrUnavailable, err)
+	}
+	return err
+}
@@ -0,0 +1,186 @@
+package events
+
+import (
+	"Elschool-API/internal/config"
+	"Elschool-API/internal/domain/models"
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// subscriberBuffer is how many events a subscriber may lag behind before it
+// is dropped. Dropped clients reconnect and catch up from the log.
+const subscriberBuffer = 16
+
+type subscriber struct {
+	studID string
+	events chan models.MarksEvent
+}
+
+// Log keeps the latest marks events of all students in a bounded ring and
+// fans new ones out to subscribers. Event ids are prefixed with the start
+// time of the process, so ids issued before a restart are recognized as lost.
+// Such a log serves a single replica, Shared spreads events to all of them.
+type Log struct {
+	mu     sync.Mutex
+	epoch  string
+	seq    uint64
+	ring   []models.MarksEvent
+	next   int
+	filled bool
+	subs   map[*subscriber]struct{}
+}
+
+func New(cfg *config.EventsConfig) *Log {
+	return &Log{
+		epoch: strconv.FormatInt(time.Now().UnixNano(), 36),
+		ring:  make([]models.MarksEvent, max(cfg.LogSize, 1)),
+		subs:  make(map[*subscriber]struct{}),
+	}
+}
+
+func (l *Log) PublishMarks(event models.MarksEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	event.ID = l.epoch + "-" + strconv.FormatUint(l.seq+1, 10)
+	event.CreatedAt = time.Now().UTC()
+
+	l.add(event)
+}
+
+// add keeps the numbered event and sends it to the subscribers of its
+// student, l.mu must be held. An event of another epoch or one after a gap
+// starts the ring over, so streams from before it are reset rather than
+// resumed past the events missing.
+func (l *Log) add(event models.MarksEvent) {
+	epoch, seq, ok := splitID(event.ID)
+	if !ok {
+		return
+	}
+
+	if epoch != l.epoch || seq != l.seq+1 {
+		l.epoch = epoch
+		l.next = 0
+		l.filled = false
+	}
+	l.seq = seq
+
+	l.ring[l.next] = event
+	l.next = (l.next + 1) % len(l.ring)
+	if l.next == 0 {
+		l.filled = true
+	}
+
+	for sub := range l.subs {
+		if sub.studID != event.StudentID {
+			continue
+		}
+
+		select {
+		case sub.events <- event:
+		default:
+			l.unsubscribe(sub)
+		}
+	}
+}
+
+// SubscribeMarks replays the student's events after lastEventID and streams
+// new ones until ctx is done.
+func (l *Log) SubscribeMarks(ctx context.Context, studID, lastEventID string) (models.MarksSubscription, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	sub := &subscriber{studID: studID, events: make(chan models.MarksEvent, subscriberBuffer)}
+	l.subs[sub] = struct{}{}
+
+	go func() {
+		<-ctx.Done()
+
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.unsubscribe(sub)
+	}()
+
+	result := models.MarksSubscription{Events: sub.events}
+
+	if lastEventID == "" {
+		return result, nil
+	}
+
+	seq, ok := l.parseID(lastEventID)
+	if !ok || seq < l.oldestSeq()-1 {
+		result.Reset = true
+		return result, nil
+	}
+
+	for i := range l.size() {
+		event := l.ring[(l.start()+i)%len(l.ring)]
+		if event.StudentID != studID {
+			continue
+		}
+		if eventSeq, _ := l.parseID(event.ID); eventSeq > seq {
+			result.Backlog = append(result.Backlog, event)
+		}
+	}
+
+	return result, nil
+}
+
+func (l *Log) unsubscribe(sub *subscriber) {
+	if _, ok := l.subs[sub]; !ok {
+		return
+	}
+
+	delete(l.subs, sub)
+	close(sub.events)
+}
+
+func (l *Log) parseID(id string) (uint64, bool) {
+	epoch, seq, ok := splitID(id)
+	if !ok || epoch != l.epoch || seq > l.seq {
+		return 0, false
+	}
+
+	return seq, true
+}
+
+func splitID(id string) (epoch string, seq uint64, ok bool) {
+	epoch, n, ok := strings.Cut(id, "-")
+	if !ok {
+		return "", 0, false
+	}
+
+	seq, err := strconv.ParseUint(n, 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+
+	return epoch, seq, true
+}
+
+func (l *Log) size() int {
+	if l.filled {
+		return len(l.ring)
+	}
+	return l.next
+}
+
+func (l *Log) start() int {
+	if l.filled {
+		return l.next
+	}
+	return 0
+}
+
+// oldestSeq is the sequence number of the oldest event still in the ring.
+func (l *Log) oldestSeq() uint64 {
+	return l.seq - uint64(l.size()) + 1
+}
+
+// Close has nothing to release, the log lives in the process.
+func (l *Log) Close() error {
+	return nil
+}
@@ -0,0 +1,106 @@
+package events
+
+import (
+	"Elschool-API/internal/config"
+	"Elschool-API/internal/domain/models"
+	"context"
+	"encoding/json"
+	"github.com/go-redis/redis/v8"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	eventsChannel  = "elschool-api:events:marks"
+	epochKey       = "elschool-api:events:epoch"
+	seqKey         = "elschool-api:events:seq"
+	publishTimeout = time.Second
+)
+
+// publishScript numbers the event and publishes it in one step, so replicas
+// receive events in the order of their ids. The epoch changes only when
+// Redis loses it along with the sequence.
+var publishScript = redis.NewScript(`
+local epoch = redis.call("GET", KEYS[1])
+if not epoch then
+	epoch = ARGV[1]
+	redis.call("SET", KEYS[1], epoch)
+end
+local id = epoch .. "-" .. redis.call("INCR", KEYS[2])
+redis.call("PUBLISH", ARGV[2], id .. " " .. ARGV[3])
+return id
+`)
+
+// Shared is the log of a replica sharing Redis with others. Events are
+// numbered by Redis and published to every replica, including the one that
+// observed the change, and each keeps them in its own ring. So a stream gets
+// changes whichever replica fetched the marks, and resumes on any replica.
+// Events published while the subscription reconnects are lost, the gap
+// resets streams behind it.
+type Shared struct {
+	*Log
+	log    *slog.Logger
+	conn   *redis.Client
+	pubsub *redis.PubSub
+}
+
+func NewShared(log *slog.Logger, conn *redis.Client, cfg *config.EventsConfig) *Shared {
+	s := &Shared{
+		Log:    New(cfg),
+		log:    log.With(slog.String("component", "infra.events.shared")),
+		conn:   conn,
+		pubsub: conn.Subscribe(context.Background(), eventsChannel),
+	}
+
+	go s.listen()
+
+	return s
+}
+
+// PublishMarks hands the event to every replica. It is kept once it comes
+// back over the subscription.
+func (s *Shared) PublishMarks(event models.MarksEvent) {
+	event.CreatedAt = time.Now().UTC()
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		s.log.Warn("failed to encode marks event", slog.String("student", event.StudentID), "error", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+	defer cancel()
+
+	epoch := strconv.FormatInt(time.Now().UnixNano(), 36)
+	keys := []string{epochKey, seqKey}
+	if err = publishScript.Run(ctx, s.conn, keys, epoch, eventsChannel, payload).Err(); err != nil {
+		s.log.Warn("failed to publish marks event", slog.String("student", event.StudentID), "error", err)
+	}
+}
+
+func (s *Shared) listen() {
+	for msg := range s.pubsub.Channel() {
+		id, payload, ok := strings.Cut(msg.Payload, " ")
+		if !ok {
+			continue
+		}
+
+		var event models.MarksEvent
+		if err := json.Unmarshal([]byte(payload), &event); err != nil {
+			s.log.Warn("failed to decode marks event", slog.String("id", id), "error", err)
+			continue
+		}
+		event.ID = id
+
+		s.Log.mu.Lock()
+		s.Log.add(event)
+		s.Log.mu.Unlock()
+	}
+}
+
+// Close ends the subscription, events of other replicas stop coming.
+func (s *Shared) Close() error {
+	return s.pubsub.Close()
+}
@@ -0,0 +1,61 @@
+package events
+
+import (
+	"Elschool-API/internal/config"
+	"Elschool-API/internal/domain/models"
+	"Elschool-API/internal/infra/cache"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+)
+
+// TicketStore keeps tickets where every replica finds them, a ticket may be
+// issued by one replica and redeemed by another.
+type TicketStore interface {
+	AddTicket(ctx context.Context, ticket models.StreamTicket) (err error)
+	// TakeTicket returns the ticket and deletes it, cache.ErrTicketNotFound
+	// if there is none.
+	TakeTicket(ctx context.Context, id string) (ticket models.StreamTicket, err error)
+}
+
+// Tickets issues stream tickets and keeps them in the store until they are
+// redeemed or expire.
+type Tickets struct {
+	store TicketStore
+	ttl   time.Duration
+}
+
+func NewTickets(store TicketStore, cfg *config.EventsConfig) *Tickets {
+	return &Tickets{store: store, ttl: cfg.TicketTTL}
+}
+
+// IssueTicket sets the id and the expiry of the ticket and keeps it.
+func (t *Tickets) IssueTicket(ctx context.Context, ticket models.StreamTicket) (models.StreamTicket, error) {
+	const op = "infra.events.IssueTicket"
+
+	ticket.ID = uuid.NewString()
+	ticket.ExpiresAt = time.Now().Add(t.ttl).UTC()
+
+	if err := t.store.AddTicket(ctx, ticket); err != nil {
+		return models.StreamTicket{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return ticket, nil
+}
+
+// RedeemTicket returns the ticket and forgets it, so it is used once only.
+func (t *Tickets) RedeemTicket(ctx context.Context, id string) (models.StreamTicket, error) {
+	const op = "infra.events.RedeemTicket"
+
+	ticket, err := t.store.TakeTicket(ctx, id)
+	if err != nil {
+		return models.StreamTicket{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !time.Now().Before(ticket.ExpiresAt) {
+		return models.StreamTicket{}, fmt.Errorf("%s: %w", op, cache.ErrTicketNotFound)
+	}
+
+	return ticket, nil
+}
@@ -2,6 +2,8 @@ package fetcher
 
 import (
 	"Elschool-API/internal/domain/models"
+	"Elschool-API/internal/infra/breaker"
+	"Elschool-API/internal/infra/metrics"
 	"Elschool-API/internal/infra/parser"
 	"context"
 	"errors"
@@ -14,6 +16,8 @@ import (
 
 var (
 	ErrCantFetch = errors.New("fetching failed")
+	// ErrSessionRejected means elschool sent the student to log on again.
+	ErrSessionRejected = errors.New("session rejected")
 )
 
 const (
@@ -21,121 +25,139 @@ const (
 	Diaries       = "/users/diaries"
 	Grades        = "/users/diaries/grades"
 	Results       = "/users/diaries/results"
+	Logon         = "/Logon"
 	JwtCookieName = "JWToken"
 )
 
 type Fetcher struct {
 	httpClient *http.Client
 	parser     parser.Parser
+	breaker    *breaker.Breaker
 	url        string
 }
 
-func New(url string) *Fetcher {
+func New(url string, breakerInfra *breaker.Breaker, metricsInfra *metrics.Metrics) *Fetcher {
 	return &Fetcher{
 		httpClient: &http.Client{
 			Timeout: 15 * time.Second,
 		},
-		url: url, parser: *parser.New(),
+		url: baseURL(url), parser: *parser.New(metricsInfra), breaker: breakerInfra,
 	}
 }
 
+// baseURL makes https the scheme of a bare host. A url with a scheme is kept,
+// so the fetcher can talk plain http to a local fake Elschool.
+func baseURL(url string) string {
+	if strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://") {
+		return strings.TrimSuffix(url, "/")
+	}
+	return HttpsPrefix + url
+}
+
 func (f *Fetcher) FetchDayMarks(ctx context.Context, jwt, date string) (marks models.DayMarks, err error) {
 	const op = "infra.fetcher.FetchDayMarks"
 
-	headers, err := f.getUrlHeaders(ctx, jwt)
-	if err != nil {
-		return models.DayMarks{}, fmt.Errorf("%s:  %w", op, err)
-	}
-
-	url := HttpsPrefix + f.url + Grades + headers
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	page, err := f.fetchPage(ctx, jwt, Grades)
 	if err != nil {
-		return models.DayMarks{}, fmt.Errorf("%s:  %w", op, err)
+		return models.DayMarks{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	req.AddCookie(&http.Cookie{
-		Name:  JwtCookieName,
-		Value: jwt,
-	})
-
-	resp, err := f.httpClient.Do(req)
+	marks, err = f.parser.ParseDayMarks(date, page)
 	if err != nil {
 		return models.DayMarks{}, fmt.Errorf("%s: %w", op, err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return models.DayMarks{}, fmt.Errorf("%s: %w", op, resp.StatusCode)
-	}
+	return marks, nil
+}
 
-	bodyBytes, err := io.ReadAll(resp.Body)
+func (f *Fetcher) FetchAverageMarks(ctx context.Context, jwt string, period int32) (marks models.AverageMarks, err error) {
+	const op = "infra.fetcher.FetchAverageMarks"
+
+	page, err := f.fetchPage(ctx, jwt, Grades)
 	if err != nil {
-		return models.DayMarks{}, fmt.Errorf("%s: %w", op, err)
+		return models.AverageMarks{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	marks, err = f.parser.ParseDayMarks(date, string(bodyBytes))
+	marks, err = f.parser.ParseAverageMarks(period, page)
 	if err != nil {
-		return models.DayMarks{}, fmt.Errorf("%s: %w", op, err)
+		return models.AverageMarks{}, fmt.Errorf("%s: %w", op, err)
 	}
 
 	return marks, nil
 }
 
-func (f *Fetcher) FetchAverageMarks(ctx context.Context, jwt string, period int32) (marks models.AverageMarks, err error) {
-	const op = "infra.fetcher.FetchAverageMarks"
+func (f *Fetcher) FetchFinalMarks(ctx context.Context, jwt string) (marks models.FinalMarks, err error) {
+	const op = "infra.fetcher.FetchFinalMarks"
 
-	headers, err := f.getUrlHeaders(ctx, jwt)
+	page, err := f.fetchPage(ctx, jwt, Results)
 	if err != nil {
-		return models.AverageMarks{}, fmt.Errorf("%s: %w", op, err)
+		return models.FinalMarks{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	url := HttpsPrefix + f.url + Grades + headers
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	marks, err = f.parser.ParseFinalMarks(page)
 	if err != nil {
-		return models.AverageMarks{}, fmt.Errorf("%s: %w", op, err)
+		return models.FinalMarks{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	req.AddCookie(&http.Cookie{
-		Name:  JwtCookieName,
-		Value: jwt,
-	})
+	return marks, nil
+}
 
-	resp, err := f.httpClient.Do(req)
+// Ping checks that elschool responds at all. It bypasses the breaker, so
+// health checks neither trip it nor get rejected while it is open.
+func (f *Fetcher) Ping(ctx context.Context) error {
+	const op = "infra.fetcher.Ping"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, f.url, nil)
 	if err != nil {
-		return models.AverageMarks{}, fmt.Errorf("%s: %w", op, err)
+		return fmt.Errorf("%s: %w", op, err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return models.AverageMarks{}, fmt.Errorf("%s: %w", op, resp.StatusCode)
+	httpClient := &http.Client{
+		Timeout: 15 * time.Second, CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
 	}
 
-	bodyBytes, err := io.ReadAll(resp.Body)
+	resp, err := httpClient.Do(req)
 	if err != nil {
-		return models.AverageMarks{}, fmt.Errorf("%s: %w", op, err)
+		return fmt.Errorf("%s: %w", op, err)
 	}
+	defer resp.Body.Close()
 
-	marks, err = f.parser.ParseAverageMarks(period, string(bodyBytes))
-	if err != nil {
-		return models.AverageMarks{}, fmt.Errorf("%s: %w", op, err)
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("%s: %w: status %d", op, ErrCantFetch, resp.StatusCode)
 	}
 
-	return marks, nil
+	return nil
 }
 
-func (f *Fetcher) FetchFinalMarks(ctx context.Context, jwt string) (marks models.FinalMarks, err error) {
-	const op = "infra.fetcher.FetchFinalMarks"
+func (f *Fetcher) fetchPage(ctx context.Context, jwt, path string) (page string, err error) {
+	const op = "infra.fetcher.fetchPage"
+
+	err = f.breaker.Execute(func() error {
+		headers, err := f.getUrlHeaders(ctx, jwt)
+		if err != nil {
+			return err
+		}
+
+		page, err = f.getPage(ctx, jwt, path+headers)
+		return err
+	})
 
-	headers, err := f.getUrlHeaders(ctx, jwt)
 	if err != nil {
-		return models.FinalMarks{}, fmt.Errorf("%s: %w", op, err)
+		return "", fmt.Errorf("%s: %w", op, err)
 	}
 
-	url := HttpsPrefix + f.url + Results + headers
+	return page, nil
+}
+
+func (f *Fetcher) getPage(ctx context.Context, jwt, path string) (page string, err error) {
+	const op = "infra.fetcher.getPage"
 
+	url := f.url + path
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		return models.FinalMarks{}, fmt.Errorf("%s: %w", op, err)
+		return "", fmt.Errorf("%s: %w", op, err)
 	}
 
 	req.AddCookie(&http.Cookie{
@@ -145,32 +167,35 @@ func (f *Fetcher) FetchFinalMarks(ctx context.Context, jwt string) (marks models
 
 	resp, err := f.httpClient.Do(req)
 	if err != nil {
-		return models.FinalMarks{}, fmt.Errorf("%s: %w", op, err)
+		return "", fmt.Errorf("%s: %w", op, err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return models.FinalMarks{}, fmt.Errorf("%s: %w", op, resp.StatusCode)
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return "", fmt.Errorf("%s: %w: status %d", op, ErrCantFetch, resp.StatusCode)
 	}
 
-	bodyBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return models.FinalMarks{}, fmt.Errorf("%s: %w", op, err)
+	if sessionRejected(resp) {
+		return "", breaker.Harmless(fmt.Errorf("%s: %w: status %d", op, ErrSessionRejected, resp.StatusCode))
 	}
 
-	marks, err = f.parser.ParseFinalMarks(string(bodyBytes))
+	if resp.StatusCode != http.StatusOK {
+		return "", breaker.Harmless(fmt.Errorf("%s: %w: status %d", op, ErrCantFetch, resp.StatusCode))
+	}
+
+	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return models.FinalMarks{}, fmt.Errorf("%s: %w", op, err)
+		return "", fmt.Errorf("%s: %w", op, err)
 	}
 
-	return marks, nil
+	return string(bodyBytes), nil
 }
 
 func (f *Fetcher) getUrlHeaders(ctx context.Context, jwt string) (headers string, err error) {
 	const op = "infra.fetcher.getUrlHeaders"
-	url := HttpsPrefix + f.url + Diaries
+	url := f.url + Diaries
 
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return "", fmt.Errorf("%s: %w", op, err)
 	}
@@ -192,6 +217,14 @@ func (f *Fetcher) getUrlHeaders(ctx context.Context, jwt string) (headers string
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return "", fmt.Errorf("%s: %w: status %d", op, ErrCantFetch, resp.StatusCode)
+	}
+
+	if sessionRejected(resp) {
+		return "", breaker.Harmless(fmt.Errorf("%s: %w: status %d", op, ErrSessionRejected, resp.StatusCode))
+	}
+
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return "", fmt.Errorf("%s: %w", op, err)
@@ -202,7 +235,7 @@ func (f *Fetcher) getUrlHeaders(ctx context.Context, jwt string) (headers string
 	endIdx := strings.Index(bodyStr, "here")
 
 	if startIdx == -1 || endIdx == -1 {
-		return "", fmt.Errorf("%s: %w", op, ErrCantFetch)
+		return "", breaker.Harmless(fmt.Errorf("%s: %w", op, ErrCantFetch))
 	}
 
 	headers = bodyStr[startIdx : endIdx-2]
@@ -218,3 +251,17 @@ func (f *Fetcher) getUrlHeaders(ctx context.Context, jwt string) (headers string
 
 	return headers, nil
 }
+
+// sessionRejected tells whether elschool refused the JWT, either outright or
+// by redirecting to the logon page.
+func sessionRejected(resp *http.Response) bool {
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return true
+	}
+	if resp.StatusCode != http.StatusFound {
+		return false
+	}
+
+	location, err := resp.Location()
+	return err == nil && strings.HasPrefix(location.Path, Logon)
+}
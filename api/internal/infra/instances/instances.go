@@ -0,0 +1,121 @@
+package instances
+
+import (
+	"Elschool-API/internal/config"
+	"Elschool-API/internal/domain/models"
+	"Elschool-API/internal/infra/auth"
+	"Elschool-API/internal/infra/breaker"
+	"Elschool-API/internal/infra/diary"
+	"Elschool-API/internal/infra/fetcher"
+	"Elschool-API/internal/infra/metrics"
+	"context"
+	"fmt"
+	"slices"
+)
+
+var (
+	ErrUnknownInstance = diary.ErrUnknownInstance
+)
+
+// instance talks to a single Elschool host. Each one has a breaker of its
+// own, so an outage of one instance doesn't cut students of the others off.
+type instance struct {
+	auth    *auth.UserAuthClient
+	fetcher *fetcher.Fetcher
+}
+
+// Router sends requests to the Elschool instance the student is bound to.
+// The infra url is the default instance, further ones are configured by name.
+type Router struct {
+	instances map[string]instance
+}
+
+func New(cfg config.InfraConfig, metricsInfra *metrics.Metrics) *Router {
+	r := &Router{instances: make(map[string]instance, len(cfg.Instances)+1)}
+
+	for name, url := range cfg.Instances {
+		r.add(name, url, breaker.New("elschool:"+name, cfg.Breaker, metricsInfra), metricsInfra)
+	}
+	// The default instance keeps the breaker name it had before instances
+	// were introduced, so its metrics go on.
+	r.add(models.DefaultInstance, cfg.Url, breaker.New("elschool", cfg.Breaker, metricsInfra), metricsInfra)
+
+	return r
+}
+
+func (r *Router) add(name, url string, breakerInfra *breaker.Breaker, metricsInfra *metrics.Metrics) {
+	r.instances[name] = instance{auth: auth.New(url, breakerInfra), fetcher: fetcher.New(url, breakerInfra, metricsInfra)}
+}
+
+// Instances returns names of the configured instances, the default one first.
+func (r *Router) Instances() []string {
+	names := make([]string, 0, len(r.instances))
+	for name := range r.instances {
+		if name != models.DefaultInstance {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	return append([]string{models.DefaultInstance}, names...)
+}
+
+func (r *Router) AuthStudent(ctx context.Context, name, login, password string) (jwt string, err error) {
+	inst, err := r.instance(name)
+	if err != nil {
+		return "", err
+	}
+	return inst.auth.AuthStudent(ctx, login, password)
+}
+
+func (r *Router) CheckToken(ctx context.Context, name, jwt string) (status bool, err error) {
+	inst, err := r.instance(name)
+	if err != nil {
+		return false, err
+	}
+	return inst.auth.CheckToken(ctx, jwt)
+}
+
+func (r *Router) FetchDayMarks(ctx context.Context, name, jwt, date string) (marks models.DayMarks, err error) {
+	inst, err := r.instance(name)
+	if err != nil {
+		return models.DayMarks{}, err
+	}
+	return inst.fetcher.FetchDayMarks(ctx, jwt, date)
+}
+
+func (r *Router) FetchAverageMarks(ctx context.Context, name, jwt string, period int32) (marks models.AverageMarks, err error) {
+	inst, err := r.instance(name)
+	if err != nil {
+		return models.AverageMarks{}, err
+	}
+	return inst.fetcher.FetchAverageMarks(ctx, jwt, period)
+}
+
+func (r *Router) FetchFinalMarks(ctx context.Context, name, jwt string) (marks models.FinalMarks, err error) {
+	inst, err := r.instance(name)
+	if err != nil {
+		return models.FinalMarks{}, err
+	}
+	return inst.fetcher.FetchFinalMarks(ctx, jwt)
+}
+
+// Ping checks every instance, the first one failing is reported.
+func (r *Router) Ping(ctx context.Context) error {
+	for _, name := range r.Instances() {
+		if err := r.instances[name].fetcher.Ping(ctx); err != nil {
+			return fmt.Errorf("instance %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (r *Router) instance(name string) (instance, error) {
+	const op = "infra.instances.instance"
+
+	inst, ok := r.instances[name]
+	if !ok {
+		return instance{}, fmt.Errorf("%s: %w: %q", op, ErrUnknownInstance, name)
+	}
+	return inst, nil
+}
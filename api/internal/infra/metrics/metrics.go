@@ -13,9 +13,14 @@ const (
 	StatusErr      = "err"
 	StatusHit      = "hit"
 	StatusMiss     = "miss"
+	StatusStale    = "stale"
 	ServiceUser    = "user"
 	ServiceMarks   = "marks"
 	ServiceStudent = "student"
+	ServiceAPIKeys = "api_keys"
+	ServiceAdmin   = "admin"
+	ServiceGC      = "gc"
+	ServiceQuota   = "quota"
 	TypeDay        = "day"
 	TypeAverage    = "average"
 	TypeFinal      = "final"
@@ -26,10 +31,18 @@ const (
 	ActionRead     = "read"
 	ActionUpdate   = "update"
 	ActionAdd      = "add"
+	ActionRotate   = "rotate"
+	ActionRevoke   = "revoke"
+	GCOrphaned     = "orphaned"
+	GCUnused       = "unused"
+	GCCache        = "cache"
 )
 
 type Metrics struct {
 	UserRegistrations     *prometheus.CounterVec
+	UserTokenActions      *prometheus.CounterVec
+	UserDeletions         *prometheus.CounterVec
+	UserExports           *prometheus.CounterVec
 	StudentActions        *prometheus.CounterVec
 	MarksRequests         *prometheus.CounterVec
 	CacheModifyTotal      *prometheus.CounterVec
@@ -40,6 +53,13 @@ type Metrics struct {
 	ElschoolFetchDuration *prometheus.HistogramVec
 	ElschoolAuthTotal     *prometheus.CounterVec
 	ElschoolAuthDuration  *prometheus.HistogramVec
+	ElschoolBreakerState  *prometheus.GaugeVec
+	ParserFailuresTotal   *prometheus.CounterVec
+	GRPCRequestDuration   *prometheus.HistogramVec
+	GRPCPanicsTotal       *prometheus.CounterVec
+	GCRunsTotal           *prometheus.CounterVec
+	GCRemovedTotal        *prometheus.CounterVec
+	QuotaExceeded         *prometheus.CounterVec
 }
 
 func New(config *config.MetricsConfig) (*Metrics, error) {
@@ -52,6 +72,27 @@ func New(config *config.MetricsConfig) (*Metrics, error) {
 		},
 		[]string{"service", "status"},
 	)
+	m.UserTokenActions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "user_token_actions_total",
+			Help: "Total number of user token rotations and revocations",
+		},
+		[]string{"action", "status"},
+	)
+	m.UserDeletions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "user_deletions_total",
+			Help: "Total number of user deletions",
+		},
+		[]string{"status"},
+	)
+	m.UserExports = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "user_exports_total",
+			Help: "Total number of user data exports",
+		},
+		[]string{"status"},
+	)
 	m.StudentActions = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "student_requests_total",
@@ -125,8 +166,65 @@ func New(config *config.MetricsConfig) (*Metrics, error) {
 		[]string{"method"},
 	)
 
+	m.ElschoolBreakerState = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "elschool_breaker_state",
+			Help: "State of the circuit breaker around elschool (0 - closed, 1 - half-open, 2 - open)",
+		},
+		[]string{"name"},
+	)
+	m.ParserFailuresTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "elschool_parser_failures_total",
+			Help: "Total number of elschool page parts that couldn't be parsed, by parser and reason",
+		},
+		[]string{"parser", "reason"},
+	)
+
+	m.GRPCRequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "grpc_server_handling_seconds",
+			Help:    "Duration of gRPC calls by method and status code",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"method", "code"},
+	)
+	m.GRPCPanicsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "grpc_server_panics_total",
+			Help: "Total number of panics recovered in gRPC handlers",
+		},
+		[]string{"method"},
+	)
+
+	m.GCRunsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gc_runs_total",
+			Help: "Total number of garbage collection runs",
+		},
+		[]string{"status"},
+	)
+	m.GCRemovedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gc_removed_total",
+			Help: "Total number of students and cache entries removed by garbage collection",
+		},
+		[]string{"kind", "dry_run"},
+	)
+
+	m.QuotaExceeded = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "quota_exceeded_total",
+			Help: "Total number of requests rejected by quotas",
+		},
+		[]string{"quota"},
+	)
+
 	prometheus.MustRegister(
 		m.UserRegistrations,
+		m.UserTokenActions,
+		m.UserDeletions,
+		m.UserExports,
 		m.StudentActions,
 		m.MarksRequests,
 		m.CacheModifyTotal,
@@ -137,6 +235,13 @@ func New(config *config.MetricsConfig) (*Metrics, error) {
 		m.ElschoolFetchDuration,
 		m.ElschoolAuthTotal,
 		m.ElschoolAuthDuration,
+		m.ElschoolBreakerState,
+		m.ParserFailuresTotal,
+		m.GRPCRequestDuration,
+		m.GRPCPanicsTotal,
+		m.GCRunsTotal,
+		m.GCRemovedTotal,
+		m.QuotaExceeded,
 	)
 
 	go func() {
@@ -2,29 +2,84 @@ package parser
 
 import (
 	"Elschool-API/internal/domain/models"
+	"Elschool-API/internal/infra/diary"
+	"Elschool-API/internal/infra/metrics"
 	"errors"
 	"fmt"
 	"github.com/PuerkitoBio/goquery"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 var (
-	ErrCantParse = errors.New("cant parse marks")
+	ErrCantParse     = errors.New("cant parse marks")
+	ErrMarkupChanged = diary.ErrMarkupChanged
 )
 
+// Reasons of parse warnings. They label the parser failures metric.
 const (
+	ReasonMissingElement = "missing_element"
+	ReasonBadDate        = "bad_date"
+	ReasonBadMark        = "bad_mark"
+	ReasonMarkupChanged  = "markup_changed"
+)
+
+const (
+	gradesTable      = ".GradesTable:not(.MobileGrades)"
+	gradesRows       = gradesTable + " tbody tr"
+	gradesLesson     = ".grades-lesson"
+	gradesMarks      = ".grades-marks .mark-span"
+	markPopover      = "data-popover-content"
+	mobileGrades     = ".MobileGrades tbody"
+	gradesAverage    = ".grades-average"
+	resultsTable     = ".DivForResultsTable .ResultsTable:not(.MobileResults)"
+	resultsRows      = resultsTable + " tbody tr"
+	resultsLesson    = "td"
+	resultsMark      = "td.results-mark"
+	markDatePrefix   = "Дата проставления:"
+	maxWarningRaw    = 200
 	biggestMarkInt   = 5
 	biggestMarkFloat = 5.0
 )
 
 type Parser struct {
+	metrics *metrics.Metrics
+}
+
+func New(metricsInfra *metrics.Metrics) *Parser {
+	return &Parser{metrics: metricsInfra}
+}
+
+// report collects warnings of a single parse and counts them.
+type report struct {
+	metrics  *metrics.Metrics
+	parser   string
+	warnings []models.ParseWarning
+}
+
+func (p *Parser) report(parser string) *report {
+	return &report{metrics: p.metrics, parser: parser}
 }
 
-func New() *Parser {
-	return &Parser{}
+func (r *report) warn(selector string, row int, raw, reason string) {
+	raw = strings.TrimSpace(raw)
+	if utf8.RuneCountInString(raw) > maxWarningRaw {
+		raw = string([]rune(raw)[:maxWarningRaw])
+	}
+
+	r.warnings = append(r.warnings, models.ParseWarning{Selector: selector, Row: row, Raw: raw, Reason: reason})
+	r.metrics.ParserFailuresTotal.WithLabelValues(r.parser, reason).Inc()
 }
 
+func (r *report) markupChanged(op, selector string) error {
+	r.metrics.ParserFailuresTotal.WithLabelValues(r.parser, ReasonMarkupChanged).Inc()
+	return fmt.Errorf("%s: %w: no %q", op, ErrMarkupChanged, selector)
+}
+
+// ParseDayMarks reads marks given on the date. Marks that can't be read are
+// skipped and reported as warnings. A page without the grades table, or
+// with rows none of which names a lesson, fails with ErrMarkupChanged.
 func (p *Parser) ParseDayMarks(date, html string) (marks models.DayMarks, err error) {
 	const op = "infra.parser.ParseDayMarks"
 	marks.Marks = make(map[string][]int32)
@@ -36,106 +91,157 @@ func (p *Parser) ParseDayMarks(date, html string) (marks models.DayMarks, err er
 		return models.DayMarks{}, fmt.Errorf("%s: %w", op, ErrCantParse)
 	}
 
-	doc.Find(".GradesTable tbody tr").Each(func(i int, tr *goquery.Selection) {
-		lesson := tr.Find(".grades-lesson").Text()
-		spans := tr.Find(".grades-marks .mark-span")
+	r := p.report(metrics.TypeDay)
+	if doc.Find(gradesTable).Length() == 0 {
+		return models.DayMarks{}, r.markupChanged(op, gradesTable)
+	}
 
-		spans.Each(func(i int, span *goquery.Selection) {
-			dataContent, _ := span.Attr("data-popover-content")
-			dateParts := strings.Split(dataContent, "<p>")
-			if len(dateParts) < 2 {
-				err = fmt.Errorf("%s: %w", op, ErrCantParse)
+	rows := doc.Find(gradesRows)
+	lessons := 0
+
+	rows.Each(func(i int, tr *goquery.Selection) {
+		row := i + 1
+
+		lessonCell := tr.Find(gradesLesson)
+		if lessonCell.Length() == 0 {
+			r.warn(gradesLesson, row, tr.Text(), ReasonMissingElement)
+			return
+		}
+		lessons++
+		lesson := lessonCell.Text()
+
+		tr.Find(gradesMarks).Each(func(_ int, span *goquery.Selection) {
+			popover, ok := span.Attr(markPopover)
+			if !ok {
+				r.warn(gradesMarks, row, span.Text(), ReasonMissingElement)
 				return
 			}
 
-			markDate := strings.TrimSpace(strings.Split(dateParts[1], "</p>")[0])[len("Дата проставления: "):]
-
-			if marks.Date == markDate {
-				markStr := span.Text()
-				mark, parseErr := strconv.ParseInt(strings.TrimSpace(markStr), 10, 32)
-				if parseErr != nil {
-					err = fmt.Errorf("%s: %w", op, ErrCantParse)
-					return
-				}
+			markDate, ok := markDate(popover)
+			if !ok {
+				r.warn(gradesMarks, row, popover, ReasonBadDate)
+				return
+			}
+			if marks.Date != markDate {
+				return
+			}
 
-				if marks.WorstMark > int32(mark) {
-					marks.WorstMark = int32(mark)
-				}
+			markStr := span.Text()
+			mark, parseErr := strconv.ParseInt(strings.TrimSpace(markStr), 10, 32)
+			if parseErr != nil {
+				r.warn(gradesMarks, row, markStr, ReasonBadMark)
+				return
+			}
 
-				marks.Marks[lesson] = append(marks.Marks[lesson], int32(mark))
+			if marks.WorstMark > int32(mark) {
+				marks.WorstMark = int32(mark)
 			}
+
+			marks.Marks[lesson] = append(marks.Marks[lesson], int32(mark))
 		})
 	})
 
-	if err != nil {
-		return models.DayMarks{}, err
+	if rows.Length() > 0 && lessons == 0 {
+		return models.DayMarks{}, r.markupChanged(op, gradesLesson)
 	}
+
 	if len(marks.Marks) == 0 {
 		marks.WorstMark = 0
 	}
+	marks.Warnings = r.warnings
 
 	return marks, nil
 }
 
+// ParseAverageMarks reads average marks for the period from the mobile
+// grades tables. Averages that can't be read are reported as warnings.
 func (p *Parser) ParseAverageMarks(period int32, html string) (marks models.AverageMarks, err error) {
 	const op = "infra.parser.ParseAverageMarks"
 
 	marks.Marks = make(map[string]string)
 	marks.Period = period
-	marks.WorstMark = fmt.Sprintf("%.2f", biggestMarkFloat)
+	worstMark := biggestMarkFloat
 
 	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
 	if err != nil {
 		return models.AverageMarks{}, fmt.Errorf("%s: %w", op, ErrCantParse)
 	}
 
-	doc.Find(".MobileGrades tbody").Each(func(i int, tbody *goquery.Selection) {
-		var periodCount int32
-		periodCount = 0
+	r := p.report(metrics.TypeAverage)
+	if doc.Find(gradesTable).Length() == 0 {
+		return models.AverageMarks{}, r.markupChanged(op, gradesTable)
+	}
+
+	tbodies := doc.Find(mobileGrades)
+	if tbodies.Length() == 0 && doc.Find(gradesRows).Length() > 0 {
+		return models.AverageMarks{}, r.markupChanged(op, mobileGrades)
+	}
+
+	averages := 0
+
+	tbodies.Each(func(i int, tbody *goquery.Selection) {
+		row := i + 1
+
 		subject := strings.TrimSpace(tbody.Prev().Text())
 		subject = strings.ReplaceAll(subject, "\n", "")
+		if subject == "" {
+			r.warn(mobileGrades+" thead", row, "", ReasonMissingElement)
+			return
+		}
 
-		tbody.Find("tr").Each(func(i int, tr *goquery.Selection) {
-			periodCount += 1
-			tr.Find(".grades-period-name").Text()
+		var periodCount int32
+		tbody.Find("tr").Each(func(_ int, tr *goquery.Selection) {
+			periodCount++
 
-			averageMarkText := tr.Find(".grades-average").Text()
-			averageMarkText = strings.Replace(averageMarkText, ",", ".", 1)
+			averageCell := tr.Find(gradesAverage)
+			if averageCell.Length() == 0 {
+				r.warn(gradesAverage, row, tr.Text(), ReasonMissingElement)
+				return
+			}
+			averages++
 
-			averageMark, parseErr := strconv.ParseFloat(averageMarkText, 32)
-			if parseErr != nil {
-				if averageMarkText != "" {
-					err = fmt.Errorf("%s: %w", op, ErrCantParse)
-				}
+			if periodCount != period {
+				return
+			}
+
+			averageMarkText := strings.TrimSpace(averageCell.Text())
+			if averageMarkText == "" {
 				return
 			}
-			worstMark, parseErr := strconv.ParseFloat(marks.WorstMark, 32)
+			averageMarkText = strings.Replace(averageMarkText, ",", ".", 1)
+
+			averageMark, parseErr := strconv.ParseFloat(averageMarkText, 32)
 			if parseErr != nil {
-				err = fmt.Errorf("W%s: %w", op, ErrCantParse)
+				r.warn(gradesAverage, row, averageMarkText, ReasonBadMark)
 				return
 			}
 
-			if periodCount == period {
-				if worstMark > averageMark {
-					marks.WorstMark = averageMarkText
-				}
-				marks.Marks[subject] = averageMarkText
+			if worstMark > averageMark {
+				worstMark = averageMark
+				marks.WorstMark = averageMarkText
 			}
+			marks.Marks[subject] = averageMarkText
 		})
 	})
 
-	if err != nil {
-
-		return models.AverageMarks{}, err
+	if tbodies.Length() > 0 && averages == 0 {
+		return models.AverageMarks{}, r.markupChanged(op, gradesAverage)
 	}
 
+	if marks.WorstMark == "" {
+		marks.WorstMark = fmt.Sprintf("%.2f", biggestMarkFloat)
+	}
 	if len(marks.Marks) == 0 {
 		marks.WorstMark = "0"
 	}
+	marks.Warnings = r.warnings
 
 	return marks, nil
 }
 
+// ParseFinalMarks reads final marks of every lesson. A mark that can't be
+// read is reported as a warning and counted as no mark, so the marks of a
+// lesson keep their positions.
 func (p *Parser) ParseFinalMarks(html string) (marks models.FinalMarks, err error) {
 	const op = "infra.parser.ParseFinalMarks"
 
@@ -147,40 +253,74 @@ func (p *Parser) ParseFinalMarks(html string) (marks models.FinalMarks, err erro
 		return models.FinalMarks{}, fmt.Errorf("%s: %w", op, ErrCantParse)
 	}
 
-	doc.Find(".DivForResultsTable .ResultsTable:not(.MobileResults) tbody tr").Each(func(i int, tr *goquery.Selection) {
-		lesson := tr.Find("td").First().Text()
+	r := p.report(metrics.TypeFinal)
+	if doc.Find(resultsTable).Length() == 0 {
+		return models.FinalMarks{}, r.markupChanged(op, resultsTable)
+	}
+
+	rows := doc.Find(resultsRows)
+	lessons := 0
+
+	rows.Each(func(i int, tr *goquery.Selection) {
+		row := i + 1
+
+		cells := tr.Find(resultsMark)
+		if cells.Length() == 0 {
+			r.warn(resultsMark, row, tr.Text(), ReasonMissingElement)
+			return
+		}
+		lessons++
+
+		lesson := tr.Find(resultsLesson).First().Text()
 		var marksForLesson []int32
 
-		tr.Find("td.results-mark").Each(func(i int, td *goquery.Selection) {
+		cells.Each(func(_ int, td *goquery.Selection) {
 			markText := strings.TrimSpace(td.Text())
 
 			if markText == "" {
 				marksForLesson = append(marksForLesson, 0)
-			} else {
-				mark, parseErr := strconv.Atoi(markText)
-				if parseErr != nil {
-					err = fmt.Errorf("error parsing mark: %w", parseErr)
-					return
-				}
-
-				if marks.WorstMark > int32(mark) {
-					marks.WorstMark = int32(mark)
-				}
-
-				marksForLesson = append(marksForLesson, int32(mark))
+				return
 			}
+
+			mark, parseErr := strconv.Atoi(markText)
+			if parseErr != nil {
+				r.warn(resultsMark, row, markText, ReasonBadMark)
+				marksForLesson = append(marksForLesson, 0)
+				return
+			}
+
+			if marks.WorstMark > int32(mark) {
+				marks.WorstMark = int32(mark)
+			}
+
+			marksForLesson = append(marksForLesson, int32(mark))
 		})
 
 		marks.Marks[lesson] = marksForLesson
 	})
 
-	if err != nil {
-		return models.FinalMarks{}, fmt.Errorf("%s: %w", op, err)
+	if rows.Length() > 0 && lessons == 0 {
+		return models.FinalMarks{}, r.markupChanged(op, resultsMark)
 	}
 
 	if len(marks.Marks) == 0 {
 		marks.WorstMark = 0
 	}
+	marks.Warnings = r.warnings
 
 	return marks, nil
 }
+
+// markDate takes the date a mark was given on out of its popover, e.g.
+// "Дата урока: 28.09.2022<p>Дата проставления: 28.10.2022".
+func markDate(popover string) (date string, ok bool) {
+	_, after, found := strings.Cut(popover, markDatePrefix)
+	if !found {
+		return "", false
+	}
+
+	date, _, _ = strings.Cut(after, "<")
+	date = strings.TrimSpace(date)
+
+	return date, date != ""
+}
@@ -0,0 +1,256 @@
+package secrets
+
+import (
+	"Elschool-API/internal/config"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var (
+	ErrNoKeys        = errors.New("no master keys configured")
+	ErrUnknownKey    = errors.New("unknown master key")
+	ErrBadKey        = errors.New("master key must be 32 bytes")
+	ErrCantOpen      = errors.New("failed to decrypt sealed values")
+	ErrBadDataLength = errors.New("sealed data is too short")
+)
+
+const keySize = 32
+
+// Keyring holds the master keys used to wrap per-row data keys and the key
+// used to hash logins. Only the active master key wraps new data keys, the
+// others are kept to open rows that have not been rotated yet.
+type Keyring struct {
+	active  string
+	keys    map[string][]byte
+	hmacKey []byte
+}
+
+// Sealed is a set of values encrypted with one random data key, which is in
+// turn encrypted with the master key KeyID.
+type Sealed struct {
+	KeyID   string
+	DataKey []byte
+	Values  [][]byte
+}
+
+type keyringFile struct {
+	Active  string            `json:"active"`
+	Keys    map[string]string `json:"keys"`
+	HMACKey string            `json:"hmac_key"`
+}
+
+// LoadKeyring reads the keyring file if one is configured and then applies
+// keys passed through the environment on top of it.
+func LoadKeyring(cfg *config.SecretsConfig) (*Keyring, error) {
+	const op = "infra.secrets.LoadKeyring"
+
+	file := keyringFile{Keys: make(map[string]string)}
+
+	if cfg.KeyringPath != "" {
+		data, err := os.ReadFile(cfg.KeyringPath)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if err = json.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if cfg.MasterKeys != "" {
+		for _, pair := range strings.Split(cfg.MasterKeys, ",") {
+			id, key, ok := strings.Cut(strings.TrimSpace(pair), ":")
+			if !ok {
+				return nil, fmt.Errorf("%s: malformed master key %q, id:base64 expected", op, id)
+			}
+			file.Keys[id] = key
+		}
+	}
+	if cfg.ActiveKey != "" {
+		file.Active = cfg.ActiveKey
+	}
+	if cfg.HMACKey != "" {
+		file.HMACKey = cfg.HMACKey
+	}
+
+	k := &Keyring{active: file.Active, keys: make(map[string][]byte)}
+
+	for id, encoded := range file.Keys {
+		key, err := decodeKey(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("%s: key %s: %w", op, id, err)
+		}
+		k.keys[id] = key
+	}
+
+	if len(k.keys) == 0 || file.HMACKey == "" {
+		return nil, fmt.Errorf("%s: %w", op, ErrNoKeys)
+	}
+	if _, ok := k.keys[k.active]; !ok {
+		return nil, fmt.Errorf("%s: active key %q: %w", op, k.active, ErrUnknownKey)
+	}
+
+	hmacKey, err := decodeKey(file.HMACKey)
+	if err != nil {
+		return nil, fmt.Errorf("%s: hmac key: %w", op, err)
+	}
+	k.hmacKey = hmacKey
+
+	return k, nil
+}
+
+// GenerateKey returns a new random key encoded the way the keyring expects it.
+func GenerateKey() (string, error) {
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+func (k *Keyring) ActiveKeyID() string {
+	return k.active
+}
+
+// LoginHash is a deterministic keyed hash of the login, used to find
+// students without storing logins in plaintext.
+func (k *Keyring) LoginHash(login string) []byte {
+	mac := hmac.New(sha256.New, k.hmacKey)
+	mac.Write([]byte(login))
+	return mac.Sum(nil)
+}
+
+// Seal encrypts values with a fresh data key. The aad (e.g. the row id) is
+// bound to the ciphertexts, so they can't be moved to another row.
+func (k *Keyring) Seal(aad string, values ...string) (Sealed, error) {
+	const op = "infra.secrets.Seal"
+
+	dataKey := make([]byte, keySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return Sealed{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	sealed := Sealed{KeyID: k.active, Values: make([][]byte, 0, len(values))}
+
+	for _, value := range values {
+		ciphertext, err := encrypt(dataKey, []byte(value), []byte(aad))
+		if err != nil {
+			return Sealed{}, fmt.Errorf("%s: %w", op, err)
+		}
+		sealed.Values = append(sealed.Values, ciphertext)
+	}
+
+	wrapped, err := encrypt(k.keys[k.active], dataKey, []byte(k.active))
+	if err != nil {
+		return Sealed{}, fmt.Errorf("%s: %w", op, err)
+	}
+	sealed.DataKey = wrapped
+
+	return sealed, nil
+}
+
+func (k *Keyring) Open(aad string, sealed Sealed) (values []string, err error) {
+	const op = "infra.secrets.Open"
+
+	dataKey, err := k.unwrap(sealed.KeyID, sealed.DataKey)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, ciphertext := range sealed.Values {
+		value, err := decrypt(dataKey, ciphertext, []byte(aad))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		values = append(values, string(value))
+	}
+
+	return values, nil
+}
+
+// Rewrap encrypts the data key with the active master key. The values
+// themselves are left untouched, so rotation doesn't need to re-encrypt them.
+func (k *Keyring) Rewrap(keyID string, dataKey []byte) (newKeyID string, newDataKey []byte, err error) {
+	const op = "infra.secrets.Rewrap"
+
+	plainKey, err := k.unwrap(keyID, dataKey)
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	newDataKey, err = encrypt(k.keys[k.active], plainKey, []byte(k.active))
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return k.active, newDataKey, nil
+}
+
+func (k *Keyring) unwrap(keyID string, dataKey []byte) ([]byte, error) {
+	masterKey, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, keyID)
+	}
+
+	return decrypt(masterKey, dataKey, []byte(keyID))
+}
+
+func encrypt(key, plaintext, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func decrypt(key, data, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < gcm.NonceSize() {
+		return nil, ErrBadDataLength
+	}
+
+	plaintext, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], aad)
+	if err != nil {
+		return nil, ErrCantOpen
+	}
+
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func decodeKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != keySize {
+		return nil, ErrBadKey
+	}
+	return key, nil
+}
@@ -0,0 +1,57 @@
+-- +goose NO TRANSACTION
+
+-- +goose Up
+-- SQLite can't drop NOT NULL or constraints in place, so the table is rebuilt.
+-- Foreign keys are off meanwhile so that user_students isn't cascaded.
+-- Existing rows are encrypted by the server on start, which clears their
+-- plaintext columns, the keyring is needed for that.
+-- +goose StatementBegin
+PRAGMA foreign_keys = OFF;
+BEGIN;
+
+CREATE TABLE students_new (
+    id TEXT PRIMARY KEY,
+    login TEXT,
+    password TEXT,
+    login_hash BLOB,
+    login_enc BLOB,
+    password_enc BLOB,
+    data_key BLOB,
+    key_id TEXT,
+    CONSTRAINT students_login_len     CHECK (length(login)    <= 100),
+    CONSTRAINT students_password_len  CHECK (length(password) <= 100)
+);
+
+INSERT INTO students_new (id, login, password) SELECT id, login, password FROM students;
+DROP TABLE students;
+ALTER TABLE students_new RENAME TO students;
+
+CREATE INDEX students_login_hash_idx ON students (login_hash);
+CREATE INDEX students_key_id_idx ON students (key_id);
+
+COMMIT;
+PRAGMA foreign_keys = ON;
+-- +goose StatementEnd
+
+-- +goose Down
+-- Rows have to be decrypted first, otherwise restoring NOT NULL fails.
+-- +goose StatementBegin
+PRAGMA foreign_keys = OFF;
+BEGIN;
+
+CREATE TABLE students_old (
+    id TEXT PRIMARY KEY,
+    login TEXT NOT NULL,
+    password TEXT NOT NULL,
+    CONSTRAINT students_login_len     CHECK (length(login)    <= 100),
+    CONSTRAINT students_password_len  CHECK (length(password) <= 100),
+    CONSTRAINT unique_login_password UNIQUE (login, password)
+);
+
+INSERT INTO students_old (id, login, password) SELECT id, login, password FROM students;
+DROP TABLE students;
+ALTER TABLE students_old RENAME TO students;
+
+COMMIT;
+PRAGMA foreign_keys = ON;
+-- +goose StatementEnd
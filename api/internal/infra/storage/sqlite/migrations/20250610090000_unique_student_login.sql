@@ -0,0 +1,40 @@
+-- +goose Up
+-- Students are identified by login now, so rows of the same account stored
+-- with different passwords are merged into the one linked to a user last,
+-- its password is the most recently written one. Plaintext rows of a login
+-- stored hashed too are merged when they are encrypted, the hash needs the
+-- keyring.
+-- +goose StatementBegin
+CREATE TEMPORARY TABLE student_merges AS
+SELECT id, keep_id FROM (
+    SELECT id, first_value(id) OVER (
+        PARTITION BY CASE WHEN login_hash IS NULL THEN 'plain:' || login ELSE hex(login_hash) END
+        ORDER BY written_at IS NULL, written_at DESC, id
+    ) AS keep_id
+    FROM students
+    LEFT JOIN (
+        SELECT student_id, max(created_at) AS written_at FROM user_students GROUP BY student_id
+    ) w ON w.student_id = students.id
+) s
+WHERE id <> keep_id;
+
+INSERT OR IGNORE INTO user_students (user_id, student_id, created_at)
+SELECT us.user_id, m.keep_id, us.created_at
+FROM user_students us
+JOIN student_merges m ON m.id = us.student_id;
+
+DELETE FROM students WHERE id IN (SELECT id FROM student_merges);
+
+DROP TABLE student_merges;
+
+DROP INDEX IF EXISTS students_login_hash_idx;
+CREATE UNIQUE INDEX students_login_hash_key ON students (login_hash);
+CREATE UNIQUE INDEX students_login_key ON students (login);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS students_login_key;
+DROP INDEX IF EXISTS students_login_hash_key;
+CREATE INDEX students_login_hash_idx ON students (login_hash);
+-- +goose StatementEnd
@@ -0,0 +1,34 @@
+-- +goose Up
+-- Entries recorded before have the affected user and student in the target only.
+-- +goose StatementBegin
+ALTER TABLE audit_log ADD COLUMN user_id TEXT;
+ALTER TABLE audit_log ADD COLUMN student_id TEXT;
+ALTER TABLE audit_log ADD COLUMN service TEXT NOT NULL DEFAULT '';
+
+UPDATE audit_log SET user_id = substr(target, instr(target, 'user:') + 5, 36) WHERE instr(target, 'user:') > 0;
+UPDATE audit_log SET student_id = substr(target, instr(target, 'student:') + 8, 36) WHERE instr(target, 'student:') > 0;
+
+CREATE INDEX audit_log_user_id_idx ON audit_log (user_id, id);
+CREATE INDEX audit_log_student_id_idx ON audit_log (student_id, id);
+
+CREATE TRIGGER audit_log_no_update BEFORE UPDATE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
+
+CREATE TRIGGER audit_log_no_delete BEFORE DELETE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS audit_log_no_delete;
+DROP TRIGGER IF EXISTS audit_log_no_update;
+DROP INDEX IF EXISTS audit_log_student_id_idx;
+DROP INDEX IF EXISTS audit_log_user_id_idx;
+ALTER TABLE audit_log DROP COLUMN service;
+ALTER TABLE audit_log DROP COLUMN student_id;
+ALTER TABLE audit_log DROP COLUMN user_id;
+-- +goose StatementEnd
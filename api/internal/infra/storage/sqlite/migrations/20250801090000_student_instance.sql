@@ -0,0 +1,20 @@
+-- +goose Up
+-- Students are bound to an Elschool instance now. The same login may exist on
+-- different instances, so logins are unique per instance only.
+-- +goose StatementBegin
+ALTER TABLE students ADD COLUMN instance TEXT NOT NULL DEFAULT 'default';
+
+DROP INDEX IF EXISTS students_login_hash_key;
+DROP INDEX IF EXISTS students_login_key;
+CREATE UNIQUE INDEX students_instance_login_hash_key ON students (instance, login_hash);
+CREATE UNIQUE INDEX students_instance_login_key ON students (instance, login);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS students_instance_login_key;
+DROP INDEX IF EXISTS students_instance_login_hash_key;
+CREATE UNIQUE INDEX students_login_hash_key ON students (login_hash);
+CREATE UNIQUE INDEX students_login_key ON students (login);
+ALTER TABLE students DROP COLUMN instance;
+-- +goose StatementEnd
@@ -0,0 +1,43 @@
+-- +goose Up
+-- Users registered before tokens were introduced have their token as the id,
+-- so the secret is stored in plain wherever the user is referenced. They get
+-- fresh ids, the token keeps working by its hash only.
+-- +goose StatementBegin
+CREATE TEMPORARY TABLE user_rekeys AS
+SELECT u.id AS old_id,
+    lower(hex(randomblob(4))) || '-' || lower(hex(randomblob(2))) || '-4' || substr(lower(hex(randomblob(2))), 2) || '-' ||
+    substr('89ab', 1 + abs(random()) % 4, 1) || substr(lower(hex(randomblob(2))), 2) || '-' || lower(hex(randomblob(6))) AS new_id
+FROM users u
+JOIN user_tokens t ON t.user_id = u.id AND t.token_hash = sha256(lower(u.id));
+
+INSERT INTO users (id, service, created_at, disabled_at, disabled_reason)
+SELECT r.new_id, u.service, u.created_at, u.disabled_at, u.disabled_reason
+FROM users u
+JOIN user_rekeys r ON r.old_id = u.id;
+
+UPDATE user_students SET user_id = (SELECT new_id FROM user_rekeys WHERE old_id = user_students.user_id)
+WHERE user_id IN (SELECT old_id FROM user_rekeys);
+UPDATE user_tokens SET user_id = (SELECT new_id FROM user_rekeys WHERE old_id = user_tokens.user_id)
+WHERE user_id IN (SELECT old_id FROM user_rekeys);
+
+-- Entries name the user in the actor and the target too, also those without
+-- a user_id, each column names a single user.
+DROP TRIGGER audit_log_no_update;
+UPDATE audit_log SET user_id = (SELECT new_id FROM user_rekeys WHERE old_id = audit_log.user_id)
+WHERE user_id IN (SELECT old_id FROM user_rekeys);
+UPDATE audit_log SET actor = (SELECT replace(audit_log.actor, old_id, new_id) FROM user_rekeys WHERE instr(audit_log.actor, old_id) > 0)
+WHERE EXISTS (SELECT 1 FROM user_rekeys WHERE instr(audit_log.actor, old_id) > 0);
+UPDATE audit_log SET target = (SELECT replace(audit_log.target, old_id, new_id) FROM user_rekeys WHERE instr(audit_log.target, old_id) > 0)
+WHERE EXISTS (SELECT 1 FROM user_rekeys WHERE instr(audit_log.target, old_id) > 0);
+CREATE TRIGGER audit_log_no_update BEFORE UPDATE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
+
+DELETE FROM users WHERE id IN (SELECT old_id FROM user_rekeys);
+
+DROP TABLE user_rekeys;
+-- +goose StatementEnd
+
+-- +goose Down
+-- Old ids are tokens, they aren't kept.
@@ -0,0 +1,87 @@
+package sqlite
+
+import (
+	"Elschool-API/internal/config"
+	"Elschool-API/internal/infra/secrets"
+	"Elschool-API/internal/infra/storage/sqlstore"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"github.com/mattn/go-sqlite3"
+	"github.com/pressly/goose/v3"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// driverName is sqlite3 with the functions migrations rely on.
+const driverName = "sqlite3_elschool"
+
+func init() {
+	sql.Register(driverName, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.RegisterFunc("sha256", func(value string) []byte {
+				hash := sha256.Sum256([]byte(value))
+				return hash[:]
+			}, true)
+		},
+	})
+}
+
+// dialect of SQLite. It has a single writer, which takes the database lock
+// as transactions begin, so rows aren't locked and reads are consistent.
+// Timestamps are stored as text in different formats, date() understands all
+// of them.
+var dialect = sqlstore.Dialect{
+	IsUniqueViolation:     func(err error) bool { return hasCode(err, sqlite3.ErrConstraintUnique) },
+	IsForeignKeyViolation: func(err error) bool { return hasCode(err, sqlite3.ErrConstraintForeignKey) },
+	Day:                   func(column string) string { return "date(" + column + ")" },
+}
+
+func New(db *sql.DB, keyring *secrets.Keyring) *sqlstore.Storage {
+	return sqlstore.New(db, keyring, dialect)
+}
+
+func hasCode(err error, code sqlite3.ErrNoExtended) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == code
+}
+
+// InitDB opens the database and applies the migrations.
+func InitDB(cfg *config.StorageConfig) (db *sql.DB, err error) {
+	db, err = Open(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	goose.SetBaseFS(migrations)
+
+	if err = goose.SetDialect("sqlite3"); err != nil {
+		return nil, err
+	}
+
+	if err = goose.Up(db, "migrations"); err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// Open opens the database as it is.
+func Open(cfg *config.StorageConfig) (db *sql.DB, err error) {
+	connStr := fmt.Sprintf("file:%s?_foreign_keys=on&_busy_timeout=5000&_journal_mode=WAL&_txlock=immediate", cfg.Path)
+
+	db, err = sql.Open(driverName, connStr)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Ping()
+	if err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
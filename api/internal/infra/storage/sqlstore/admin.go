@@ -0,0 +1,220 @@
+package sqlstore
+
+import (
+	"Elschool-API/internal/domain/models"
+	"Elschool-API/internal/infra/storage"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const userInfoQuery = `SELECT u.id, u.service, u.created_at, u.disabled_at, COALESCE(u.disabled_reason, ''),
+	(SELECT count(*) FROM user_students us WHERE us.user_id = u.id),
+	(SELECT count(*) FROM user_tokens t WHERE t.user_id = u.id AND t.revoked_at IS NULL AND (t.expires_at IS NULL OR t.expires_at > ?))
+FROM users u WHERE `
+
+func (s *Storage) FindUser(ctx context.Context, userID string) (user models.UserInfo, err error) {
+	const op = "infra.storage.sqlstore.FindUser"
+
+	user, err = scanUserInfo(s.db.QueryRowContext(ctx, s.rebind(userInfoQuery+"u.id = ?"), time.Now().UTC(), userID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.UserInfo{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+
+		return models.UserInfo{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// FindUserByToken returns the user the token was issued to, even if the token
+// has expired or was revoked since.
+func (s *Storage) FindUserByToken(ctx context.Context, token string) (user models.UserInfo, err error) {
+	const op = "infra.storage.sqlstore.FindUserByToken"
+
+	user, err = scanUserInfo(s.db.QueryRowContext(ctx,
+		s.rebind(userInfoQuery+"u.id = (SELECT user_id FROM user_tokens WHERE token_hash = ?)"),
+		time.Now().UTC(), storage.HashToken(token),
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.UserInfo{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+
+		return models.UserInfo{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// SetUserDisabled disables the user, or enables it back if disabledAt is
+// zero. Tokens of a disabled user can't be resolved.
+func (s *Storage) SetUserDisabled(ctx context.Context, userID string, disabledAt time.Time, reason string) (err error) {
+	const op = "infra.storage.sqlstore.SetUserDisabled"
+
+	res, err := s.db.ExecContext(ctx, s.rebind("UPDATE users SET disabled_at = ?, disabled_reason = NULLIF(?, '') WHERE id = ?"), nullTime(disabledAt), reason, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	return nil
+}
+
+const studentInfoQuery = `SELECT s.id, s.key_id, (SELECT count(*) FROM user_students us WHERE us.student_id = s.id)
+FROM students s WHERE `
+
+func (s *Storage) FindStudentInfo(ctx context.Context, studID string) (student models.StudentInfo, err error) {
+	const op = "infra.storage.sqlstore.FindStudentInfo"
+
+	student, err = scanStudentInfo(s.db.QueryRowContext(ctx, s.rebind(studentInfoQuery+"s.id = ?"), studID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.StudentInfo{}, fmt.Errorf("%s: %w", op, storage.ErrStudentNotFound)
+		}
+
+		return models.StudentInfo{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return student, nil
+}
+
+func (s *Storage) FindStudentInfoByLogin(ctx context.Context, login string) (student models.StudentInfo, err error) {
+	const op = "infra.storage.sqlstore.FindStudentInfoByLogin"
+
+	student, err = scanStudentInfo(s.db.QueryRowContext(ctx,
+		s.rebind(studentInfoQuery+"s.login_hash = ? LIMIT 1"),
+		s.keyring.LoginHash(login),
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.StudentInfo{}, fmt.Errorf("%s: %w", op, storage.ErrStudentNotFound)
+		}
+
+		return models.StudentInfo{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return student, nil
+}
+
+func (s *Storage) ListRelations(ctx context.Context, filter models.RelationFilter) (relations []models.Relation, err error) {
+	const op = "infra.storage.sqlstore.ListRelations"
+
+	var conds []string
+	var args []any
+
+	if filter.UserID != "" {
+		args = append(args, filter.UserID)
+		conds = append(conds, "user_id = ?")
+	}
+	if filter.StudentID != "" {
+		args = append(args, filter.StudentID)
+		conds = append(conds, "student_id = ?")
+	}
+
+	query := "SELECT user_id, student_id, created_at FROM user_students"
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	args = append(args, filter.Limit, filter.Offset)
+	query += " ORDER BY created_at, user_id, student_id LIMIT ? OFFSET ?"
+
+	rows, err := s.db.QueryContext(ctx, s.rebind(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var relation models.Relation
+		if err := rows.Scan(&relation.UserID, &relation.StudentID, &relation.CreatedAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		relations = append(relations, relation)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return relations, nil
+}
+
+// Stats counts rows of every table. Growth only has the days since the given
+// one on which something was created.
+func (s *Storage) Stats(ctx context.Context, since time.Time) (stats models.Stats, err error) {
+	const op = "infra.storage.sqlstore.Stats"
+
+	err = s.db.QueryRowContext(ctx, `SELECT
+		(SELECT count(*) FROM users),
+		(SELECT count(*) FROM users WHERE disabled_at IS NOT NULL),
+		(SELECT count(*) FROM students),
+		(SELECT count(*) FROM user_students)`,
+	).Scan(&stats.Users, &stats.DisabledUsers, &stats.Students, &stats.Relations)
+	if err != nil {
+		return models.Stats{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	day := s.dialect.Day("created_at")
+	rows, err := s.db.QueryContext(ctx, s.rebind(`SELECT day, sum(users), sum(relations) FROM (
+		SELECT `+day+` AS day, 1 AS users, 0 AS relations FROM users WHERE `+day+` >= ?
+		UNION ALL
+		SELECT `+day+`, 0, 1 FROM user_students WHERE `+day+` >= ?
+	) created GROUP BY day ORDER BY day`), since.UTC().Format(time.DateOnly), since.UTC().Format(time.DateOnly))
+	if err != nil {
+		return models.Stats{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var point models.GrowthPoint
+		if err := rows.Scan(&point.Date, &point.Users, &point.Relations); err != nil {
+			return models.Stats{}, fmt.Errorf("%s: %w", op, err)
+		}
+		stats.Growth = append(stats.Growth, point)
+	}
+
+	if err := rows.Err(); err != nil {
+		return models.Stats{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return stats, nil
+}
+
+func scanUserInfo(row scanner) (user models.UserInfo, err error) {
+	var disabledAt sql.NullTime
+
+	err = row.Scan(&user.ID, &user.Service, &user.CreatedAt, &disabledAt, &user.DisabledReason, &user.Students, &user.ActiveTokens)
+	if err != nil {
+		return models.UserInfo{}, err
+	}
+	user.DisabledAt = disabledAt.Time
+
+	return user, nil
+}
+
+func scanStudentInfo(row scanner) (student models.StudentInfo, err error) {
+	var keyID sql.NullString
+
+	err = row.Scan(&student.ID, &keyID, &student.Users)
+	if err != nil {
+		return models.StudentInfo{}, err
+	}
+	student.Encrypted = keyID.Valid
+	student.KeyID = keyID.String
+
+	return student, nil
+}
@@ -0,0 +1,100 @@
+package sqlstore
+
+import (
+	"Elschool-API/internal/domain/models"
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// SaveAuditEntry appends the entry, within the transaction in the context if
+// there is one, so that it is recorded only together with the change.
+func (s *Storage) SaveAuditEntry(ctx context.Context, entry models.AuditEntry) (err error) {
+	const op = "infra.storage.sqlstore.SaveAuditEntry"
+
+	if txRef, txErr := s.getTransaction(ctx); txErr == nil {
+		err = s.insertAuditEntry(ctx, txRef.Tx, entry)
+	} else {
+		err = s.insertAuditEntry(ctx, s.db, entry)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ListAuditEntries returns entries matching the filter, newest first.
+func (s *Storage) ListAuditEntries(ctx context.Context, filter models.AuditFilter) (entries []models.AuditEntry, err error) {
+	const op = "infra.storage.sqlstore.ListAuditEntries"
+
+	var conds []string
+	var args []any
+
+	if filter.UserID != "" {
+		args = append(args, filter.UserID)
+		conds = append(conds, "user_id = ?")
+	}
+	if filter.StudentID != "" {
+		args = append(args, filter.StudentID)
+		conds = append(conds, "student_id = ?")
+	}
+	if filter.Before > 0 {
+		args = append(args, filter.Before)
+		conds = append(conds, "id < ?")
+	}
+
+	query := "SELECT " + auditColumns + " FROM audit_log"
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += " ORDER BY id DESC LIMIT ?"
+
+	entries, err = queryAuditEntries(ctx, s.db, s.rebind(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return entries, nil
+}
+
+const auditColumns = "id, actor, action, target, COALESCE(CAST(user_id AS TEXT), ''), COALESCE(CAST(student_id AS TEXT), ''), service, status, request_id, created_at"
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func (s *Storage) insertAuditEntry(ctx context.Context, e execer, entry models.AuditEntry) error {
+	query := `INSERT INTO audit_log (actor, action, target, user_id, student_id, service, status, request_id, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	args := []any{entry.Actor, entry.Action, entry.Target, nullString(entry.UserID), nullString(entry.StudentID), entry.Service, entry.Status, entry.RequestID, entry.CreatedAt.UTC()}
+
+	_, err := e.ExecContext(ctx, s.rebind(query), args...)
+	return err
+}
+
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func queryAuditEntries(ctx context.Context, q querier, query string, args ...any) (entries []models.AuditEntry, err error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry models.AuditEntry
+		err := rows.Scan(&entry.ID, &entry.Actor, &entry.Action, &entry.Target, &entry.UserID, &entry.StudentID,
+			&entry.Service, &entry.Status, &entry.RequestID, &entry.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
@@ -0,0 +1,55 @@
+// Package sqlstore implements the storage on top of database/sql. Queries are
+// shared by the databases, they are written with ? placeholders and whatever
+// differs between the databases comes with their Dialect.
+package sqlstore
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+)
+
+// Dialect is what the storage needs to know about the database.
+type Dialect struct {
+	// Placeholder returns the placeholder of the n-th query argument,
+	// counting from 1, nil keeps ?.
+	Placeholder func(n int) string
+	// IsUniqueViolation and IsForeignKeyViolation recognize the constraint
+	// errors of the driver.
+	IsUniqueViolation     func(err error) bool
+	IsForeignKeyViolation func(err error) bool
+	// ForUpdate locks the selected rows until the transaction ends,
+	// SkipLocked does as well, but leaves out rows others have locked.
+	// Databases with a single writer have nothing to lock.
+	ForUpdate  string
+	SkipLocked string
+	// Snapshot begins transactions which read a consistent snapshot.
+	Snapshot *sql.TxOptions
+	// Day formats the timestamp column as YYYY-MM-DD.
+	Day func(column string) string
+}
+
+// DollarPlaceholder numbers placeholders as $1, $2 and so on.
+func DollarPlaceholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+// rebind puts the placeholders of the dialect into the query. Queries don't
+// have question marks other than placeholders.
+func (s *Storage) rebind(query string) string {
+	if s.dialect.Placeholder == nil {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r != '?' {
+			b.WriteRune(r)
+			continue
+		}
+		n++
+		b.WriteString(s.dialect.Placeholder(n))
+	}
+	return b.String()
+}
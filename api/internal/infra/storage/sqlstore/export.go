@@ -0,0 +1,80 @@
+package sqlstore
+
+import (
+	"Elschool-API/internal/domain/models"
+	"Elschool-API/internal/infra/storage"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ExportUser reads everything stored about the user from a single snapshot.
+// Audit entries are the ones affecting the user.
+func (s *Storage) ExportUser(ctx context.Context, userID string) (export models.UserExport, err error) {
+	const op = "infra.storage.sqlstore.ExportUser"
+
+	tx, err := s.db.BeginTx(ctx, s.dialect.Snapshot)
+	if err != nil {
+		return models.UserExport{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	export.User, err = scanUserInfo(tx.QueryRowContext(ctx, s.rebind(userInfoQuery+"u.id = ?"), time.Now().UTC(), userID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.UserExport{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+
+		return models.UserExport{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := tx.QueryContext(ctx, s.rebind("SELECT created_at, expires_at, revoked_at FROM user_tokens WHERE user_id = ? ORDER BY created_at"), userID)
+	if err != nil {
+		return models.UserExport{}, fmt.Errorf("%s: %w", op, err)
+	}
+	for rows.Next() {
+		var token models.TokenRecord
+		var expiresAt, revokedAt sql.NullTime
+		if err := rows.Scan(&token.CreatedAt, &expiresAt, &revokedAt); err != nil {
+			rows.Close()
+			return models.UserExport{}, fmt.Errorf("%s: %w", op, err)
+		}
+		token.ExpiresAt, token.RevokedAt = expiresAt.Time, revokedAt.Time
+		export.Tokens = append(export.Tokens, token)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return models.UserExport{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err = tx.QueryContext(ctx,
+		s.rebind("SELECT s.id, us.created_at, s.last_used_at FROM user_students us JOIN students s ON s.id = us.student_id WHERE us.user_id = ? ORDER BY us.created_at"),
+		userID,
+	)
+	if err != nil {
+		return models.UserExport{}, fmt.Errorf("%s: %w", op, err)
+	}
+	for rows.Next() {
+		var student models.LinkedStudent
+		var lastUsedAt sql.NullTime
+		if err := rows.Scan(&student.ID, &student.LinkedAt, &lastUsedAt); err != nil {
+			rows.Close()
+			return models.UserExport{}, fmt.Errorf("%s: %w", op, err)
+		}
+		student.LastUsedAt = lastUsedAt.Time
+		export.Students = append(export.Students, student)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return models.UserExport{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	export.Audit, err = queryAuditEntries(ctx, tx, s.rebind("SELECT "+auditColumns+" FROM audit_log WHERE user_id = ? ORDER BY id"), userID)
+	if err != nil {
+		return models.UserExport{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return export, nil
+}
@@ -0,0 +1,148 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TouchStudent records that marks of the student were requested.
+func (s *Storage) TouchStudent(ctx context.Context, studID string, usedAt time.Time) (err error) {
+	const op = "infra.storage.sqlstore.TouchStudent"
+
+	_, err = s.db.ExecContext(ctx, s.rebind("UPDATE students SET last_used_at = ? WHERE id = ?"), usedAt.UTC(), studID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// FindOrphanedStudents returns students no user has, ordered by id and
+// starting after the given one.
+func (s *Storage) FindOrphanedStudents(ctx context.Context, after string, limit int) (students []string, err error) {
+	const op = "infra.storage.sqlstore.FindOrphanedStudents"
+
+	students, err = queryIDs(ctx, s.db,
+		s.rebind("SELECT id FROM students s WHERE id > ? AND NOT EXISTS (SELECT 1 FROM user_students us WHERE us.student_id = s.id) ORDER BY id LIMIT ?"),
+		after, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return students, nil
+}
+
+// FindUnusedStudents returns students last used before the given time,
+// ordered by id and starting after the given one.
+func (s *Storage) FindUnusedStudents(ctx context.Context, usedBefore time.Time, after string, limit int) (students []string, err error) {
+	const op = "infra.storage.sqlstore.FindUnusedStudents"
+
+	students, err = queryIDs(ctx, s.db,
+		s.rebind("SELECT id FROM students WHERE id > ? AND last_used_at < ? ORDER BY id LIMIT ?"),
+		after, usedBefore.UTC(), limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return students, nil
+}
+
+// DeleteOrphanedStudent deletes the student unless a user has got it since
+// it was found.
+func (s *Storage) DeleteOrphanedStudent(ctx context.Context, studID string) (deleted bool, err error) {
+	const op = "infra.storage.sqlstore.DeleteOrphanedStudent"
+
+	res, err := s.db.ExecContext(ctx, s.rebind("DELETE FROM students WHERE id = ? AND NOT EXISTS (SELECT 1 FROM user_students us WHERE us.student_id = students.id)"), studID)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return rowsAffected > 0, nil
+}
+
+// DeleteUnusedStudent deletes the student with its relations unless it has
+// been used since it was found.
+func (s *Storage) DeleteUnusedStudent(ctx context.Context, studID string, usedBefore time.Time) (deleted bool, err error) {
+	const op = "infra.storage.sqlstore.DeleteUnusedStudent"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	defer func() {
+		if err == nil {
+			err = tx.Commit()
+		} else {
+			tx.Rollback()
+		}
+	}()
+
+	var id string
+	err = tx.QueryRowContext(ctx, s.rebind("SELECT id FROM students WHERE id = ? AND last_used_at < ?"+s.dialect.ForUpdate), studID, usedBefore.UTC()).Scan(&id)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err = tx.ExecContext(ctx, s.rebind("DELETE FROM user_students WHERE student_id = ?"), studID); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err = tx.ExecContext(ctx, s.rebind("DELETE FROM students WHERE id = ?"), studID); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return true, nil
+}
+
+// ExistingStudents returns which of the students are stored.
+func (s *Storage) ExistingStudents(ctx context.Context, studIDs []string) (existing []string, err error) {
+	const op = "infra.storage.sqlstore.ExistingStudents"
+
+	if len(studIDs) == 0 {
+		return nil, nil
+	}
+
+	args := make([]any, len(studIDs))
+	for i, id := range studIDs {
+		args[i] = id
+	}
+
+	existing, err = queryIDs(ctx, s.db, s.rebind("SELECT id FROM students WHERE id IN (?"+strings.Repeat(", ?", len(studIDs)-1)+")"), args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return existing, nil
+}
+
+func queryIDs(ctx context.Context, db *sql.DB, query string, args ...any) (ids []string, err error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
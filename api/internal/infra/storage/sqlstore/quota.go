@@ -0,0 +1,73 @@
+package sqlstore
+
+import (
+	"Elschool-API/internal/domain/models"
+	"Elschool-API/internal/infra/storage"
+	"context"
+	"fmt"
+)
+
+// CountUserStudents returns how many students the user has. Within a
+// transaction the user row is written first, so of concurrent repeatable read
+// transactions linking students to the same user only one commits and the
+// others fail instead of passing a quota check together. Databases with a
+// single writer serialize them anyway.
+func (s *Storage) CountUserStudents(ctx context.Context, userID string) (count int, err error) {
+	const op = "infra.storage.sqlstore.CountUserStudents"
+
+	txRef, err := s.getTransaction(ctx)
+	if err != nil {
+		err = s.db.QueryRowContext(ctx, s.rebind("SELECT COUNT(*) FROM user_students WHERE user_id = ?"), userID).Scan(&count)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		return count, nil
+	}
+	tx := txRef.Tx
+
+	res, err := tx.ExecContext(ctx, s.rebind("UPDATE users SET id = id WHERE id = ?"), userID)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if rowsAffected == 0 {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	err = tx.QueryRowContext(ctx, s.rebind("SELECT COUNT(*) FROM user_students WHERE user_id = ?"), userID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return count, nil
+}
+
+// ListUserStudentUsers returns students of the user with the number of users
+// each of them has.
+func (s *Storage) ListUserStudentUsers(ctx context.Context, userID string) (students []models.StudentUsers, err error) {
+	const op = "infra.storage.sqlstore.ListUserStudentUsers"
+
+	rows, err := s.db.QueryContext(ctx, s.rebind(`SELECT us.student_id, (SELECT COUNT(*) FROM user_students o WHERE o.student_id = us.student_id)
+		FROM user_students us WHERE us.user_id = ? ORDER BY us.student_id`), userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var student models.StudentUsers
+		if err := rows.Scan(&student.StudentID, &student.Users); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		students = append(students, student)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return students, nil
+}
@@ -0,0 +1,920 @@
+package sqlstore
+
+import (
+	"Elschool-API/internal/domain/models"
+	"Elschool-API/internal/infra/secrets"
+	"Elschool-API/internal/infra/storage"
+	"Elschool-API/internal/infra/storage/transaction"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type Storage struct {
+	db      *sql.DB
+	keyring *secrets.Keyring
+	dialect Dialect
+}
+
+func New(db *sql.DB, keyring *secrets.Keyring, dialect Dialect) *Storage {
+	return &Storage{db: db, keyring: keyring, dialect: dialect}
+}
+
+// CreateUser stores the user with its first token and records the registration
+// in the audit log.
+func (s *Storage) CreateUser(ctx context.Context, userID, service, token string, expiresAt time.Time, audit models.AuditEntry) (err error) {
+	const op = "infra.storage.sqlstore.CreateUser"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	defer func() {
+		if err == nil {
+			err = tx.Commit()
+		} else {
+			tx.Rollback()
+		}
+	}()
+
+	_, err = tx.ExecContext(ctx, s.rebind("INSERT INTO users (id, service) VALUES (?, ?)"), userID, service)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = tx.ExecContext(ctx, s.rebind("INSERT INTO user_tokens (token_hash, user_id, expires_at) VALUES (?, ?, ?)"), storage.HashToken(token), userID, nullTime(expiresAt))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = s.insertAuditEntry(ctx, tx, audit); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ResolveUserToken returns the user the token was issued to, unless the token
+// has expired or was revoked, or the user was disabled.
+func (s *Storage) ResolveUserToken(ctx context.Context, token string) (userID string, err error) {
+	const op = "infra.storage.sqlstore.ResolveUserToken"
+
+	stmt, err := s.db.PrepareContext(ctx, s.rebind("SELECT t.user_id FROM user_tokens t JOIN users u ON u.id = t.user_id WHERE t.token_hash = ? AND t.revoked_at IS NULL AND (t.expires_at IS NULL OR t.expires_at > ?) AND u.disabled_at IS NULL"))
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	err = stmt.QueryRowContext(ctx, storage.HashToken(token), time.Now().UTC()).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return userID, nil
+}
+
+// RotateUserToken issues the new token and makes the old one expire at
+// graceUntil, unless it expires earlier anyway.
+func (s *Storage) RotateUserToken(ctx context.Context, oldToken, newToken string, expiresAt, graceUntil time.Time) (oldExpiresAt time.Time, err error) {
+	const op = "infra.storage.sqlstore.RotateUserToken"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	defer func() {
+		if err == nil {
+			err = tx.Commit()
+		} else {
+			tx.Rollback()
+		}
+	}()
+
+	var userID string
+	var expires sql.NullTime
+
+	err = tx.QueryRowContext(ctx,
+		s.rebind("SELECT t.user_id, t.expires_at FROM user_tokens t JOIN users u ON u.id = t.user_id WHERE t.token_hash = ? AND t.revoked_at IS NULL AND (t.expires_at IS NULL OR t.expires_at > ?) AND u.disabled_at IS NULL"),
+		storage.HashToken(oldToken), time.Now().UTC(),
+	).Scan(&userID, &expires)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return time.Time{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+
+		return time.Time{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	oldExpiresAt = graceUntil
+	if expires.Valid && expires.Time.Before(graceUntil) {
+		oldExpiresAt = expires.Time
+	}
+
+	_, err = tx.ExecContext(ctx, s.rebind("UPDATE user_tokens SET expires_at = ? WHERE token_hash = ?"), oldExpiresAt, storage.HashToken(oldToken))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = tx.ExecContext(ctx, s.rebind("INSERT INTO user_tokens (token_hash, user_id, expires_at) VALUES (?, ?, ?)"), storage.HashToken(newToken), userID, nullTime(expiresAt))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return oldExpiresAt, nil
+}
+
+func (s *Storage) RevokeUserToken(ctx context.Context, token string) (err error) {
+	const op = "infra.storage.sqlstore.RevokeUserToken"
+
+	stmt, err := s.db.PrepareContext(ctx, s.rebind("UPDATE user_tokens SET revoked_at = ? WHERE token_hash = ? AND revoked_at IS NULL"))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, time.Now().UTC(), storage.HashToken(token))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	return nil
+}
+
+// DeleteUser deletes the user with its tokens and relations. Students of the
+// user which no other user has are deleted as well.
+func (s *Storage) DeleteUser(ctx context.Context, userID string) (deleted models.UserDeletion, err error) {
+	const op = "infra.storage.sqlstore.DeleteUser"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.UserDeletion{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	defer func() {
+		if err == nil {
+			err = tx.Commit()
+		} else {
+			tx.Rollback()
+		}
+	}()
+
+	rows, err := tx.QueryContext(ctx, s.rebind("SELECT student_id FROM user_students WHERE user_id = ?"), userID)
+	if err != nil {
+		return models.UserDeletion{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var students []string
+	for rows.Next() {
+		var studID string
+		if err = rows.Scan(&studID); err != nil {
+			rows.Close()
+			return models.UserDeletion{}, fmt.Errorf("%s: %w", op, err)
+		}
+		students = append(students, studID)
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return models.UserDeletion{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if deleted.Tokens, err = execCount(ctx, tx, s.rebind("DELETE FROM user_tokens WHERE user_id = ?"), userID); err != nil {
+		return models.UserDeletion{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if deleted.Relations, err = execCount(ctx, tx, s.rebind("DELETE FROM user_students WHERE user_id = ?"), userID); err != nil {
+		return models.UserDeletion{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	users, err := execCount(ctx, tx, s.rebind("DELETE FROM users WHERE id = ?"), userID)
+	if err != nil {
+		return models.UserDeletion{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if users == 0 {
+		return models.UserDeletion{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	for _, studID := range students {
+		n, err := execCount(ctx, tx, s.rebind("DELETE FROM students WHERE id = ? AND NOT EXISTS (SELECT 1 FROM user_students WHERE student_id = students.id)"), studID)
+		if err != nil {
+			return models.UserDeletion{}, fmt.Errorf("%s: %w", op, err)
+		}
+		if n > 0 {
+			deleted.Students = append(deleted.Students, studID)
+		}
+	}
+
+	return deleted, nil
+}
+
+func (s *Storage) CreateAPIKey(ctx context.Context, key models.APIKey, secret string) (err error) {
+	const op = "infra.storage.sqlstore.CreateAPIKey"
+
+	stmt, err := s.db.PrepareContext(ctx, s.rebind("INSERT INTO api_keys (id, service, key_hash, scopes, created_at) VALUES (?, ?, ?, ?, ?)"))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, key.ID, key.Service, storage.HashToken(secret), strings.Join(key.Scopes, ","), key.CreatedAt.UTC())
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// FindAPIKey returns the key with that secret if it hasn't been revoked.
+func (s *Storage) FindAPIKey(ctx context.Context, secret string) (key models.APIKey, err error) {
+	const op = "infra.storage.sqlstore.FindAPIKey"
+
+	stmt, err := s.db.PrepareContext(ctx, s.rebind("SELECT "+apiKeyColumns+" FROM api_keys WHERE key_hash = ? AND revoked_at IS NULL"))
+	if err != nil {
+		return models.APIKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	key, err = scanAPIKey(stmt.QueryRowContext(ctx, storage.HashToken(secret)))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.APIKey{}, fmt.Errorf("%s: %w", op, storage.ErrAPIKeyNotFound)
+		}
+
+		return models.APIKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return key, nil
+}
+
+// ListAPIKeys returns keys of the service, or all keys if service is empty.
+func (s *Storage) ListAPIKeys(ctx context.Context, service string) (keys []models.APIKey, err error) {
+	const op = "infra.storage.sqlstore.ListAPIKeys"
+
+	stmt, err := s.db.PrepareContext(ctx, s.rebind("SELECT "+apiKeyColumns+" FROM api_keys WHERE ? = '' OR service = ? ORDER BY created_at"))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, service, service)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		keys = append(keys, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return keys, nil
+}
+
+func (s *Storage) RevokeAPIKey(ctx context.Context, keyID string) (err error) {
+	const op = "infra.storage.sqlstore.RevokeAPIKey"
+
+	stmt, err := s.db.PrepareContext(ctx, s.rebind("UPDATE api_keys SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL"))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, time.Now().UTC(), keyID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrAPIKeyNotFound)
+	}
+
+	return nil
+}
+
+func (s *Storage) CreateStudent(ctx context.Context, studID, provider, instance, login, password string) (err error) {
+	const op = "infra.storage.sqlstore.CreateStudent"
+
+	txRef, err := s.getTransaction(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	tx := txRef.Tx
+
+	sealed, err := s.keyring.Seal(studID, login, password)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	stmt, err := tx.PrepareContext(ctx, s.rebind("INSERT INTO students (id, provider, instance, login_hash, login_enc, password_enc, data_key, key_id, last_used_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)"))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, studID, provider, instance, s.keyring.LoginHash(login), sealed.Values[0], sealed.Values[1], sealed.DataKey, sealed.KeyID, time.Now().UTC())
+
+	if err != nil {
+		if s.dialect.IsUniqueViolation(err) {
+			return fmt.Errorf("%s: %w", op, storage.ErrStudentExists)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) DeleteStudent(ctx context.Context, studID string) error {
+	const op = "infra.storage.sqlstore.DeleteStudent"
+
+	txRef, err := s.getTransaction(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	tx := txRef.Tx
+
+	stmt, err := tx.PrepareContext(ctx, s.rebind("DELETE FROM students WHERE id = ?"))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, studID)
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrStudentNotFound)
+	}
+
+	return nil
+}
+
+// FindStudentByLogin looks the student of the provider instance up by the
+// login hash.
+func (s *Storage) FindStudentByLogin(ctx context.Context, provider, instance, login string) (student models.Student, err error) {
+	const op = "infra.storage.sqlstore.FindStudentByLogin"
+
+	txRef, err := s.getTransaction(ctx)
+	if err != nil {
+		return models.Student{}, fmt.Errorf("%s: %w", op, err)
+	}
+	tx := txRef.Tx
+
+	stmt, err := tx.PrepareContext(ctx, s.rebind("SELECT "+studentColumns+" FROM students WHERE provider = ? AND instance = ? AND login_hash = ?"))
+	if err != nil {
+		return models.Student{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	student, err = s.scanStudent(stmt.QueryRowContext(ctx, provider, instance, s.keyring.LoginHash(login)))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Student{}, fmt.Errorf("%s: %w", op, storage.ErrStudentNotFound)
+		}
+
+		return models.Student{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return student, nil
+}
+
+// FindStudentProvider returns the diary provider and its instance the student
+// is bound to, within the transaction in the context if there is one.
+func (s *Storage) FindStudentProvider(ctx context.Context, studID string) (provider, instance string, err error) {
+	const op = "infra.storage.sqlstore.FindStudentProvider"
+
+	query := "SELECT provider, instance FROM students WHERE id = ?"
+	if txRef, txErr := s.getTransaction(ctx); txErr == nil {
+		err = txRef.Tx.QueryRowContext(ctx, s.rebind(query), studID).Scan(&provider, &instance)
+	} else {
+		err = s.db.QueryRowContext(ctx, s.rebind(query), studID).Scan(&provider, &instance)
+	}
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", "", fmt.Errorf("%s: %w", op, storage.ErrStudentNotFound)
+		}
+
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return provider, instance, nil
+}
+
+// UpdateStudentCredentials seals the new credentials with a fresh data key,
+// keeping the student id.
+func (s *Storage) UpdateStudentCredentials(ctx context.Context, studID, login, password string) (err error) {
+	const op = "infra.storage.sqlstore.UpdateStudentCredentials"
+
+	txRef, err := s.getTransaction(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	tx := txRef.Tx
+
+	sealed, err := s.keyring.Seal(studID, login, password)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	stmt, err := tx.PrepareContext(ctx, s.rebind("UPDATE students SET login = NULL, password = NULL, login_hash = ?, login_enc = ?, password_enc = ?, data_key = ?, key_id = ? WHERE id = ?"))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, s.keyring.LoginHash(login), sealed.Values[0], sealed.Values[1], sealed.DataKey, sealed.KeyID, studID)
+
+	if err != nil {
+		if s.dialect.IsUniqueViolation(err) {
+			return fmt.Errorf("%s: %w", op, storage.ErrStudentExists)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrStudentNotFound)
+	}
+
+	return nil
+}
+
+func (s *Storage) ReadStudent(ctx context.Context, studID string) (student models.Student, err error) {
+	const op = "infra.storage.sqlstore.ReadStudent"
+
+	stmt, err := s.db.PrepareContext(ctx, s.rebind("SELECT "+studentColumns+" FROM students WHERE id = ?"))
+	if err != nil {
+		return models.Student{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	student, err = s.scanStudent(stmt.QueryRowContext(ctx, studID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Student{}, fmt.Errorf("%s: %w", op, storage.ErrStudentNotFound)
+		}
+
+		return models.Student{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return student, nil
+}
+
+func (s *Storage) AddRelation(ctx context.Context, userID, studID string) (err error) {
+	const op = "infra.storage.sqlstore.AddRelation"
+
+	txRef, err := s.getTransaction(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	tx := txRef.Tx
+
+	stmt, err := tx.PrepareContext(ctx, s.rebind("INSERT INTO user_students (user_id, student_id) VALUES (?, ?)"))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, userID, studID)
+
+	if err != nil {
+		if s.dialect.IsForeignKeyViolation(err) {
+			return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *Storage) DeleteRelation(ctx context.Context, userID, studID string) error {
+	const op = "infra.storage.sqlstore.DeleteRelation"
+
+	txRef, err := s.getTransaction(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	tx := txRef.Tx
+
+	stmt, err := tx.PrepareContext(ctx, s.rebind("DELETE FROM user_students WHERE user_id = ? AND student_id = ?"))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, userID, studID)
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrRelationNotFound)
+	}
+
+	return nil
+}
+
+func (s *Storage) GetStudentRelations(ctx context.Context, studID string) (users []string, err error) {
+	const op = "infra.storage.sqlstore.GetStudentRelations"
+
+	txRef, err := s.getTransaction(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	tx := txRef.Tx
+
+	stmt, err := tx.PrepareContext(ctx, s.rebind("SELECT 1 FROM students WHERE id = ?"))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	var exists int
+	row := stmt.QueryRowContext(ctx, studID)
+
+	err = row.Scan(&exists)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, storage.ErrStudentNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	stmt, err = tx.PrepareContext(ctx, s.rebind("SELECT user_id FROM user_students WHERE student_id = ?"))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, studID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userId string
+		if err := rows.Scan(&userId); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		users = append(users, userId)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return users, nil
+}
+
+func (s *Storage) CheckRelation(ctx context.Context, userID, studID string) (err error) {
+	const op = "infra.storage.sqlstore.CheckRelation"
+
+	stmt, err := s.db.PrepareContext(ctx, s.rebind("SELECT 1 FROM user_students WHERE user_id = ? AND student_id = ?"))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	var exists int
+	row := stmt.QueryRowContext(ctx, userID, studID)
+
+	err = row.Scan(&exists)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			stmt, err = s.db.PrepareContext(ctx, s.rebind("SELECT 1 FROM users WHERE id = ?"))
+			if err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+
+			defer stmt.Close()
+
+			var exists int
+			row := stmt.QueryRowContext(ctx, userID)
+
+			err = row.Scan(&exists)
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+				}
+				return fmt.Errorf("%s: %w", op, err)
+			}
+
+			return fmt.Errorf("%s: %w", op, storage.ErrStudentNotFound)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// EncryptStudents seals credentials of rows stored before encryption was
+// introduced. Rows are processed in short transactions, so the service keeps
+// serving requests meanwhile.
+func (s *Storage) EncryptStudents(ctx context.Context, batchSize int) (encrypted int, err error) {
+	const op = "infra.storage.sqlstore.EncryptStudents"
+
+	encrypted, err = s.inBatches(ctx, func(tx *sql.Tx) (int, error) {
+		rows, err := tx.QueryContext(ctx, s.rebind("SELECT id, provider, instance, login, password FROM students WHERE login_hash IS NULL LIMIT ?"+s.dialect.SkipLocked), batchSize)
+		if err != nil {
+			return 0, err
+		}
+
+		var students []models.Student
+		for rows.Next() {
+			var student models.Student
+			if err := rows.Scan(&student.Token, &student.Provider, &student.Instance, &student.Login, &student.Password); err != nil {
+				rows.Close()
+				return 0, err
+			}
+			students = append(students, student)
+		}
+		rows.Close()
+
+		if err := rows.Err(); err != nil {
+			return 0, err
+		}
+
+		for _, student := range students {
+			// The same login may have been stored again since, in that case
+			// the row written last is kept and the other is merged into it.
+			var existing string
+			err := tx.QueryRowContext(ctx, s.rebind("SELECT id FROM students WHERE provider = ? AND instance = ? AND login_hash = ?"), student.Provider, student.Instance, s.keyring.LoginHash(student.Login)).Scan(&existing)
+			if err == nil {
+				newer, err := s.studentNewer(ctx, tx, student.Token, existing)
+				if err != nil {
+					return 0, err
+				}
+				if !newer {
+					if err = s.mergeStudent(ctx, tx, student.Token, existing); err != nil {
+						return 0, err
+					}
+					continue
+				}
+				if err = s.mergeStudent(ctx, tx, existing, student.Token); err != nil {
+					return 0, err
+				}
+			} else if !errors.Is(err, sql.ErrNoRows) {
+				return 0, err
+			}
+
+			sealed, err := s.keyring.Seal(student.Token, student.Login, student.Password)
+			if err != nil {
+				return 0, err
+			}
+
+			_, err = tx.ExecContext(ctx,
+				s.rebind("UPDATE students SET login = NULL, password = NULL, login_hash = ?, login_enc = ?, password_enc = ?, data_key = ?, key_id = ? WHERE id = ?"),
+				s.keyring.LoginHash(student.Login), sealed.Values[0], sealed.Values[1], sealed.DataKey, sealed.KeyID, student.Token,
+			)
+			if err != nil {
+				return 0, err
+			}
+		}
+
+		return len(students), nil
+	})
+
+	if err != nil {
+		return encrypted, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return encrypted, nil
+}
+
+// RotateStudentKeys re-wraps data keys of rows sealed with a master key other
+// than the active one. Encrypted credentials stay as they are.
+func (s *Storage) RotateStudentKeys(ctx context.Context, batchSize int) (rotated int, err error) {
+	const op = "infra.storage.sqlstore.RotateStudentKeys"
+
+	activeKeyID := s.keyring.ActiveKeyID()
+
+	rotated, err = s.inBatches(ctx, func(tx *sql.Tx) (int, error) {
+		rows, err := tx.QueryContext(ctx, s.rebind("SELECT id, key_id, data_key FROM students WHERE key_id <> ? LIMIT ?"+s.dialect.SkipLocked), activeKeyID, batchSize)
+		if err != nil {
+			return 0, err
+		}
+
+		var sealed []secrets.Sealed
+		var ids []string
+		for rows.Next() {
+			var id string
+			var row secrets.Sealed
+			if err := rows.Scan(&id, &row.KeyID, &row.DataKey); err != nil {
+				rows.Close()
+				return 0, err
+			}
+			ids = append(ids, id)
+			sealed = append(sealed, row)
+		}
+		rows.Close()
+
+		if err := rows.Err(); err != nil {
+			return 0, err
+		}
+
+		for i, row := range sealed {
+			keyID, dataKey, err := s.keyring.Rewrap(row.KeyID, row.DataKey)
+			if err != nil {
+				return 0, err
+			}
+
+			_, err = tx.ExecContext(ctx, s.rebind("UPDATE students SET key_id = ?, data_key = ? WHERE id = ?"), keyID, dataKey, ids[i])
+			if err != nil {
+				return 0, err
+			}
+		}
+
+		return len(sealed), nil
+	})
+
+	if err != nil {
+		return rotated, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return rotated, nil
+}
+
+// studentNewer reports whether the student was linked to a user after the
+// other one was last. Credentials are written when a student is linked, so
+// its password is the more recent one.
+func (s *Storage) studentNewer(ctx context.Context, tx *sql.Tx, id, otherID string) (newer bool, err error) {
+	err = tx.QueryRowContext(ctx,
+		s.rebind("SELECT COALESCE((SELECT max(created_at) FROM user_students WHERE student_id = ?) > (SELECT max(created_at) FROM user_students WHERE student_id = ?), false)"),
+		id, otherID,
+	).Scan(&newer)
+	return newer, err
+}
+
+// mergeStudent moves relations of one student to another and deletes it.
+func (s *Storage) mergeStudent(ctx context.Context, tx *sql.Tx, fromID, toID string) error {
+	_, err := tx.ExecContext(ctx,
+		s.rebind("INSERT INTO user_students (user_id, student_id, created_at) SELECT user_id, ?, created_at FROM user_students WHERE student_id = ? ON CONFLICT DO NOTHING"),
+		toID, fromID,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, s.rebind("DELETE FROM students WHERE id = ?"), fromID)
+	return err
+}
+
+func (s *Storage) inBatches(ctx context.Context, batch func(tx *sql.Tx) (int, error)) (total int, err error) {
+	for {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return total, err
+		}
+
+		n, err := batch(tx)
+		if err != nil {
+			tx.Rollback()
+			return total, err
+		}
+
+		if err = tx.Commit(); err != nil {
+			return total, err
+		}
+
+		total += n
+		if n == 0 {
+			return total, nil
+		}
+	}
+}
+
+func execCount(ctx context.Context, tx *sql.Tx, query string, args ...any) (int, error) {
+	res, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	if t.IsZero() {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
+
+const studentColumns = "id, provider, instance, login_enc, password_enc, data_key, key_id"
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanStudent reads a row selected with studentColumns. Rows are encrypted
+// on start, so there are no plaintext credentials to fall back to.
+func (s *Storage) scanStudent(row scanner) (student models.Student, err error) {
+	var sealed secrets.Sealed
+	var loginEnc, passwordEnc []byte
+
+	err = row.Scan(&student.Token, &student.Provider, &student.Instance, &loginEnc, &passwordEnc, &sealed.DataKey, &sealed.KeyID)
+	if err != nil {
+		return models.Student{}, err
+	}
+
+	sealed.Values = [][]byte{loginEnc, passwordEnc}
+
+	values, err := s.keyring.Open(student.Token, sealed)
+	if err != nil {
+		return models.Student{}, err
+	}
+	student.Login, student.Password = values[0], values[1]
+
+	return student, nil
+}
+
+const apiKeyColumns = "id, service, scopes, created_at, revoked_at"
+
+func scanAPIKey(row scanner) (key models.APIKey, err error) {
+	var scopes string
+	var revokedAt sql.NullTime
+
+	err = row.Scan(&key.ID, &key.Service, &scopes, &key.CreatedAt, &revokedAt)
+	if err != nil {
+		return models.APIKey{}, err
+	}
+
+	if scopes != "" {
+		key.Scopes = strings.Split(scopes, ",")
+	}
+	key.RevokedAt = revokedAt.Time
+
+	return key, nil
+}
+
+func (s *Storage) getTransaction(ctx context.Context) (tx *transaction.DbTransaction, err error) {
+	const op = "infra.storage.sqlstore.getTransaction"
+
+	var ok bool
+	if tx, ok = ctx.Value("tx").(*transaction.DbTransaction); !ok {
+		return nil, fmt.Errorf("%s: %w", op, storage.ErrFailedToGetTX)
+	}
+	return tx, nil
+}
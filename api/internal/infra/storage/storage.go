@@ -1,11 +1,28 @@
 package storage
 
-import "errors"
+import (
+	"crypto/sha256"
+	"errors"
+	"strings"
+)
+
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
 
 var (
 	ErrUserNotFound     = errors.New("user not found")
 	ErrStudentNotFound  = errors.New("student not found")
 	ErrStudentExists    = errors.New("student already exists")
 	ErrRelationNotFound = errors.New("relation not found")
+	ErrAPIKeyNotFound   = errors.New("api key not found")
 	ErrFailedToGetTX    = errors.New("failed to get the transaction")
 )
+
+// HashToken is what gets stored instead of the user token. Tokens are random
+// uuids, so a plain hash is enough.
+func HashToken(token string) []byte {
+	hash := sha256.Sum256([]byte(strings.ToLower(token)))
+	return hash[:]
+}
@@ -0,0 +1,268 @@
+package admin
+
+import (
+	"Elschool-API/internal/domain/models"
+	"Elschool-API/internal/infra/cache"
+	"Elschool-API/internal/infra/metrics"
+	"Elschool-API/internal/infra/storage"
+	"Elschool-API/internal/service"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+const (
+	DefaultStatsDays = 30
+	MaxStatsDays     = 366
+)
+
+type AdminStorage interface {
+	FindUser(ctx context.Context, userID string) (user models.UserInfo, err error)
+	FindUserByToken(ctx context.Context, userToken string) (user models.UserInfo, err error)
+	SetUserDisabled(ctx context.Context, userID string, disabledAt time.Time, reason string) (err error)
+	FindStudentInfo(ctx context.Context, studentToken string) (student models.StudentInfo, err error)
+	FindStudentInfoByLogin(ctx context.Context, login string) (student models.StudentInfo, err error)
+	ListRelations(ctx context.Context, filter models.RelationFilter) (relations []models.Relation, err error)
+	Stats(ctx context.Context, since time.Time) (stats models.Stats, err error)
+}
+
+type AuditStorage interface {
+	SaveAuditEntry(ctx context.Context, entry models.AuditEntry) (err error)
+	ListAuditEntries(ctx context.Context, filter models.AuditFilter) (entries []models.AuditEntry, err error)
+}
+
+type StudentCache interface {
+	InvalidateStudent(ctx context.Context, studentToken string) (err error)
+}
+
+type AdminService struct {
+	log          *slog.Logger
+	metrics      *metrics.Metrics
+	adminStorage AdminStorage
+	auditStorage AuditStorage
+	studCache    StudentCache
+}
+
+func New(log *slog.Logger, adminStorage AdminStorage, auditStorage AuditStorage, studCache StudentCache, metricsInfra *metrics.Metrics) *AdminService {
+	return &AdminService{
+		log:          log,
+		metrics:      metricsInfra,
+		adminStorage: adminStorage,
+		auditStorage: auditStorage,
+		studCache:    studCache,
+	}
+}
+
+func (a *AdminService) GetUser(ctx context.Context, userID string) (user models.UserInfo, err error) {
+	const op = "service.admin.GetUser"
+
+	user, err = a.adminStorage.FindUser(ctx, userID)
+	a.countStorage(metrics.ActionRead, err)
+
+	return user, a.wrapUserErr(op, err)
+}
+
+func (a *AdminService) GetUserByToken(ctx context.Context, userToken string) (user models.UserInfo, err error) {
+	const op = "service.admin.GetUserByToken"
+
+	user, err = a.adminStorage.FindUserByToken(ctx, userToken)
+	a.countStorage(metrics.ActionRead, err)
+
+	return user, a.wrapUserErr(op, err)
+}
+
+func (a *AdminService) GetStudent(ctx context.Context, studID string) (student models.StudentInfo, err error) {
+	const op = "service.admin.GetStudent"
+
+	student, err = a.adminStorage.FindStudentInfo(ctx, studID)
+	a.countStorage(metrics.ActionRead, err)
+
+	return student, a.wrapStudentErr(op, err)
+}
+
+func (a *AdminService) GetStudentByLogin(ctx context.Context, login string) (student models.StudentInfo, err error) {
+	const op = "service.admin.GetStudentByLogin"
+
+	student, err = a.adminStorage.FindStudentInfoByLogin(ctx, login)
+	a.countStorage(metrics.ActionRead, err)
+
+	return student, a.wrapStudentErr(op, err)
+}
+
+func (a *AdminService) ListRelations(ctx context.Context, filter models.RelationFilter) (relations []models.Relation, err error) {
+	const op = "service.admin.ListRelations"
+
+	relations, err = a.adminStorage.ListRelations(ctx, filter)
+	a.countStorage(metrics.ActionRead, err)
+
+	if err != nil {
+		a.log.Error("failed to list relations", slog.String("op", op), "error", err)
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return relations, nil
+}
+
+func (a *AdminService) ListAuditLog(ctx context.Context, filter models.AuditFilter) (entries []models.AuditEntry, err error) {
+	const op = "service.admin.ListAuditLog"
+
+	entries, err = a.auditStorage.ListAuditEntries(ctx, filter)
+	a.countStorage(metrics.ActionRead, err)
+
+	if err != nil {
+		a.log.Error("failed to list audit entries", slog.String("op", op), "error", err)
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return entries, nil
+}
+
+// Stats returns totals and the daily growth for the last days, today
+// included. Days on which nothing was created are reported as zeros.
+func (a *AdminService) Stats(ctx context.Context, days int) (stats models.Stats, err error) {
+	const op = "service.admin.Stats"
+
+	if days <= 0 {
+		days = DefaultStatsDays
+	}
+	days = min(days, MaxStatsDays)
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	since := today.AddDate(0, 0, 1-days)
+
+	stats, err = a.adminStorage.Stats(ctx, since)
+	a.countStorage(metrics.ActionRead, err)
+
+	if err != nil {
+		a.log.Error("failed to get stats", slog.String("op", op), "error", err)
+		return models.Stats{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	created := make(map[string]models.GrowthPoint, len(stats.Growth))
+	for _, point := range stats.Growth {
+		created[point.Date] = point
+	}
+
+	stats.Growth = make([]models.GrowthPoint, 0, days)
+	for day := since; !day.After(today); day = day.AddDate(0, 0, 1) {
+		date := day.Format(time.DateOnly)
+		point, ok := created[date]
+		if !ok {
+			point = models.GrowthPoint{Date: date}
+		}
+		stats.Growth = append(stats.Growth, point)
+	}
+
+	return stats, nil
+}
+
+// InvalidateStudentCache drops the student jwt and every cached mark, so the
+// next request goes to elschool.
+func (a *AdminService) InvalidateStudentCache(ctx context.Context, studID string) (err error) {
+	const op = "service.admin.InvalidateStudentCache"
+
+	log := a.log.With(slog.String("op", op), slog.String("student", studID))
+
+	if _, err = a.GetStudent(ctx, studID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = a.studCache.InvalidateStudent(ctx, studID)
+	if err != nil && !errors.Is(err, cache.ErrTokenNotFound) {
+		log.Error("failed to invalidate student cache", "error", err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	log.Info("student cache invalidated")
+
+	return nil
+}
+
+// DisableUser makes tokens of the user stop resolving until it is enabled
+// back. Relations and tokens themselves are kept.
+func (a *AdminService) DisableUser(ctx context.Context, userID, reason string) (user models.UserInfo, err error) {
+	const op = "service.admin.DisableUser"
+
+	return a.setUserDisabled(ctx, op, userID, time.Now().UTC(), reason)
+}
+
+func (a *AdminService) EnableUser(ctx context.Context, userID string) (user models.UserInfo, err error) {
+	const op = "service.admin.EnableUser"
+
+	return a.setUserDisabled(ctx, op, userID, time.Time{}, "")
+}
+
+// RecordAudit saves the entry to the audit log. It is also logged, so the
+// action is traceable even if saving fails.
+func (a *AdminService) RecordAudit(ctx context.Context, entry models.AuditEntry) (err error) {
+	const op = "service.admin.RecordAudit"
+
+	a.log.Info("admin action",
+		slog.String("actor", entry.Actor),
+		slog.String("action", entry.Action),
+		slog.String("target", entry.Target),
+		slog.String("status", entry.Status),
+		slog.String("request_id", entry.RequestID),
+	)
+
+	err = a.auditStorage.SaveAuditEntry(ctx, entry)
+	a.countStorage(metrics.ActionWrite, err)
+
+	if err != nil {
+		a.log.Error("failed to save audit entry", slog.String("op", op), "error", err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (a *AdminService) setUserDisabled(ctx context.Context, op, userID string, disabledAt time.Time, reason string) (user models.UserInfo, err error) {
+	log := a.log.With(slog.String("op", op), slog.String("user", userID))
+
+	err = a.adminStorage.SetUserDisabled(ctx, userID, disabledAt, reason)
+	a.countStorage(metrics.ActionUpdate, err)
+
+	if err != nil {
+		return models.UserInfo{}, a.wrapUserErr(op, err)
+	}
+	log.Info("user updated", slog.Bool("disabled", !disabledAt.IsZero()))
+
+	return a.GetUser(ctx, userID)
+}
+
+func (a *AdminService) wrapUserErr(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, storage.ErrUserNotFound) {
+		return fmt.Errorf("%s: %w", op, service.ErrUserNotFound)
+	}
+
+	a.log.Error("failed to access user", slog.String("op", op), "error", err)
+	return fmt.Errorf("%s: %w", op, err)
+}
+
+func (a *AdminService) wrapStudentErr(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, storage.ErrStudentNotFound) {
+		return fmt.Errorf("%s: %w", op, service.ErrStudentNotFound)
+	}
+
+	a.log.Error("failed to access student", slog.String("op", op), "error", err)
+	return fmt.Errorf("%s: %w", op, err)
+}
+
+// countStorage counts the storage request, missing rows aren't failures.
+func (a *AdminService) countStorage(action string, err error) {
+	status := metrics.StatusOk
+	if err != nil && !errors.Is(err, storage.ErrUserNotFound) && !errors.Is(err, storage.ErrStudentNotFound) {
+		status = metrics.StatusErr
+	}
+
+	a.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceAdmin, action, status).Inc()
+}
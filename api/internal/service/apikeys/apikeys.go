@@ -0,0 +1,154 @@
+package apikeys
+
+import (
+	"Elschool-API/internal/config"
+	"Elschool-API/internal/domain/models"
+	"Elschool-API/internal/infra/metrics"
+	"Elschool-API/internal/infra/storage"
+	"Elschool-API/internal/service"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+const (
+	secretPrefix = "esk_"
+	secretSize   = 32
+
+	bootstrapKeyID = "bootstrap"
+)
+
+type APIKeyStorage interface {
+	CreateAPIKey(ctx context.Context, key models.APIKey, secret string) (err error)
+	FindAPIKey(ctx context.Context, secret string) (key models.APIKey, err error)
+	ListAPIKeys(ctx context.Context, service string) (keys []models.APIKey, err error)
+	RevokeAPIKey(ctx context.Context, keyID string) (err error)
+}
+
+type APIKeyService struct {
+	log          *slog.Logger
+	metrics      *metrics.Metrics
+	keyStorage   APIKeyStorage
+	bootstrapKey string
+}
+
+func New(log *slog.Logger, keyStorage APIKeyStorage, metricsInfra *metrics.Metrics, cfg config.APIKeysConfig) *APIKeyService {
+	return &APIKeyService{log: log, keyStorage: keyStorage, metrics: metricsInfra, bootstrapKey: cfg.BootstrapKey}
+}
+
+// CreateKey issues a key for the client service. The secret is only returned
+// here, storage keeps its hash.
+func (a *APIKeyService) CreateKey(ctx context.Context, clientService string, scopes []string) (secret string, key models.APIKey, err error) {
+	const op = "service.apikeys.CreateKey"
+
+	log := a.log.With(slog.String("op", op), slog.String("service", clientService))
+	log.Info("creating api key")
+
+	for _, scope := range scopes {
+		if !slices.Contains(models.Scopes, scope) {
+			log.Error("unknown scope", slog.String("scope", scope))
+			return "", models.APIKey{}, fmt.Errorf("%s: %w: %s", op, service.ErrInvalidScope, scope)
+		}
+	}
+
+	keyID, err := uuid.NewRandom()
+	if err != nil {
+		log.Error("failed to gen uuid", "error", err)
+		return "", models.APIKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	raw := make([]byte, secretSize)
+	if _, err = rand.Read(raw); err != nil {
+		log.Error("failed to gen secret", "error", err)
+		return "", models.APIKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+	secret = secretPrefix + hex.EncodeToString(raw)
+
+	key = models.APIKey{
+		ID:        keyID.String(),
+		Service:   clientService,
+		Scopes:    slices.Compact(slices.Sorted(slices.Values(scopes))),
+		CreatedAt: time.Now().UTC(),
+	}
+
+	err = a.keyStorage.CreateAPIKey(ctx, key, secret)
+	if err != nil {
+		log.Error("failed to save api key", "error", err)
+		a.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceAPIKeys, metrics.ActionWrite, metrics.StatusErr).Inc()
+		return "", models.APIKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+	a.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceAPIKeys, metrics.ActionWrite, metrics.StatusOk).Inc()
+	log.Info("api key created", slog.String("key", key.ID))
+
+	return secret, key, nil
+}
+
+func (a *APIKeyService) ListKeys(ctx context.Context, clientService string) (keys []models.APIKey, err error) {
+	const op = "service.apikeys.ListKeys"
+
+	keys, err = a.keyStorage.ListAPIKeys(ctx, clientService)
+	if err != nil {
+		a.log.Error("failed to list api keys", slog.String("op", op), "error", err)
+		a.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceAPIKeys, metrics.ActionRead, metrics.StatusErr).Inc()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	a.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceAPIKeys, metrics.ActionRead, metrics.StatusOk).Inc()
+
+	return keys, nil
+}
+
+func (a *APIKeyService) RevokeKey(ctx context.Context, keyID string) (err error) {
+	const op = "service.apikeys.RevokeKey"
+
+	log := a.log.With(slog.String("op", op), slog.String("key", keyID))
+	log.Info("revoking api key")
+
+	err = a.keyStorage.RevokeAPIKey(ctx, keyID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAPIKeyNotFound) {
+			log.Error("no such api key", "error", err)
+			a.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceAPIKeys, metrics.ActionUpdate, metrics.StatusOk).Inc()
+			return fmt.Errorf("%s: %w", op, service.ErrAPIKeyNotFound)
+		}
+
+		log.Error("failed to revoke api key", "error", err)
+		a.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceAPIKeys, metrics.ActionUpdate, metrics.StatusErr).Inc()
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	a.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceAPIKeys, metrics.ActionUpdate, metrics.StatusOk).Inc()
+	log.Info("api key revoked")
+
+	return nil
+}
+
+// Authorize returns the key with that secret. The bootstrap key from config
+// has every scope, so the first keys can be issued with it.
+func (a *APIKeyService) Authorize(ctx context.Context, secret string) (key models.APIKey, err error) {
+	const op = "service.apikeys.Authorize"
+
+	if a.bootstrapKey != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(a.bootstrapKey)) == 1 {
+		return models.APIKey{ID: bootstrapKeyID, Scopes: models.Scopes}, nil
+	}
+
+	key, err = a.keyStorage.FindAPIKey(ctx, secret)
+	if err != nil {
+		if errors.Is(err, storage.ErrAPIKeyNotFound) {
+			a.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceAPIKeys, metrics.ActionRead, metrics.StatusOk).Inc()
+			return models.APIKey{}, fmt.Errorf("%s: %w", op, service.ErrAPIKeyNotFound)
+		}
+
+		a.log.Error("failed to find api key", slog.String("op", op), "error", err)
+		a.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceAPIKeys, metrics.ActionRead, metrics.StatusErr).Inc()
+		return models.APIKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+	a.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceAPIKeys, metrics.ActionRead, metrics.StatusOk).Inc()
+
+	return key, nil
+}
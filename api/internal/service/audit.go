@@ -0,0 +1,45 @@
+package service
+
+import (
+	"Elschool-API/internal/domain/models"
+	"context"
+	"errors"
+)
+
+// Client tells where a request came from. The transport puts it into the
+// context, so that it can be recorded in the audit log.
+type Client struct {
+	RequestID string
+	// Service is the client service the api key was issued to, it is empty
+	// unless api keys are enabled.
+	Service string
+}
+
+type clientCtxKey struct{}
+
+func WithClient(ctx context.Context, client Client) context.Context {
+	return context.WithValue(ctx, clientCtxKey{}, client)
+}
+
+func ClientFromContext(ctx context.Context) Client {
+	client, _ := ctx.Value(clientCtxKey{}).(Client)
+	return client
+}
+
+// AuditStatus is the outcome of an action as recorded in the audit log.
+func AuditStatus(err error) string {
+	switch {
+	case err == nil:
+		return models.AuditStatusOk
+	case errors.Is(err, ErrUserNotFound):
+		return models.AuditStatusUserNotFound
+	case errors.Is(err, ErrStudentNotFound):
+		return models.AuditStatusStudentNotFound
+	case errors.Is(err, ErrUnavailable):
+		return models.AuditStatusUnavailable
+	case errors.Is(err, ErrQuotaExceeded):
+		return models.AuditStatusQuotaExceeded
+	default:
+		return models.AuditStatusFailed
+	}
+}
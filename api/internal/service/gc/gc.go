@@ -0,0 +1,210 @@
+package gc
+
+import (
+	"Elschool-API/internal/config"
+	"Elschool-API/internal/domain/models"
+	"Elschool-API/internal/infra/cache"
+	"Elschool-API/internal/infra/metrics"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+type StudentStorage interface {
+	FindOrphanedStudents(ctx context.Context, after string, limit int) (studentTokens []string, err error)
+	FindUnusedStudents(ctx context.Context, usedBefore time.Time, after string, limit int) (studentTokens []string, err error)
+	DeleteOrphanedStudent(ctx context.Context, studentToken string) (deleted bool, err error)
+	DeleteUnusedStudent(ctx context.Context, studentToken string, usedBefore time.Time) (deleted bool, err error)
+	ExistingStudents(ctx context.Context, studentTokens []string) (existing []string, err error)
+}
+
+type StudentCache interface {
+	CachedStudents(ctx context.Context) (studentTokens []string, err error)
+	InvalidateStudent(ctx context.Context, studentToken string) (err error)
+}
+
+// Collector removes students nobody has, students nobody has asked marks
+// for in a while and cache entries of students which are gone.
+type Collector struct {
+	log         *slog.Logger
+	metrics     *metrics.Metrics
+	studStorage StudentStorage
+	studCache   StudentCache
+
+	unusedFor time.Duration
+	dryRun    bool
+	batchSize int
+}
+
+func New(log *slog.Logger, studStorage StudentStorage, studCache StudentCache, metricsInfra *metrics.Metrics, cfg config.GCConfig) *Collector {
+	batchSize := cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+
+	return &Collector{
+		log:         log,
+		metrics:     metricsInfra,
+		studStorage: studStorage,
+		studCache:   studCache,
+		unusedFor:   cfg.UnusedFor,
+		dryRun:      cfg.DryRun,
+		batchSize:   batchSize,
+	}
+}
+
+// Collect runs a single collection. On failure the report tells what was
+// removed before it.
+func (c *Collector) Collect(ctx context.Context) (report models.GCReport, err error) {
+	const op = "service.gc.Collect"
+
+	log := c.log.With(slog.String("op", op), slog.Bool("dry_run", c.dryRun))
+	log.Info("collecting garbage")
+
+	report.DryRun = c.dryRun
+
+	defer func() {
+		status := metrics.StatusOk
+		if err != nil {
+			status = metrics.StatusErr
+		}
+		c.metrics.GCRunsTotal.WithLabelValues(status).Inc()
+	}()
+
+	report.Orphaned, err = c.collectStudents(ctx, log, metrics.GCOrphaned,
+		func(ctx context.Context, after string) ([]string, error) {
+			return c.studStorage.FindOrphanedStudents(ctx, after, c.batchSize)
+		},
+		c.studStorage.DeleteOrphanedStudent,
+	)
+	if err != nil {
+		log.Error("failed to collect orphaned students", "error", err)
+		return report, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if c.unusedFor > 0 {
+		usedBefore := time.Now().Add(-c.unusedFor)
+
+		report.Unused, err = c.collectStudents(ctx, log, metrics.GCUnused,
+			func(ctx context.Context, after string) ([]string, error) {
+				return c.studStorage.FindUnusedStudents(ctx, usedBefore, after, c.batchSize)
+			},
+			func(ctx context.Context, studID string) (bool, error) {
+				return c.studStorage.DeleteUnusedStudent(ctx, studID, usedBefore)
+			},
+		)
+		if err != nil {
+			log.Error("failed to collect unused students", "error", err)
+			return report, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	report.Cache, err = c.collectCache(ctx, log)
+	if err != nil {
+		log.Error("failed to collect cache", "error", err)
+		return report, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("garbage collected",
+		slog.Int("orphaned", report.Orphaned),
+		slog.Int("unused", report.Unused),
+		slog.Int("cache", report.Cache),
+	)
+
+	return report, nil
+}
+
+// collectStudents walks the found students in batches ordered by id. The
+// delete call checks the condition again, so students which got a user or
+// were used since they were found are kept.
+func (c *Collector) collectStudents(ctx context.Context, log *slog.Logger, kind string,
+	find func(ctx context.Context, after string) ([]string, error),
+	remove func(ctx context.Context, studID string) (bool, error),
+) (removed int, err error) {
+	after := uuid.Nil.String()
+
+	for {
+		students, err := find(ctx, after)
+		if err != nil {
+			return removed, err
+		}
+
+		for _, studID := range students {
+			if !c.dryRun {
+				deleted, err := remove(ctx, studID)
+				if err != nil {
+					return removed, err
+				}
+				if !deleted {
+					continue
+				}
+
+				c.invalidate(ctx, log, studID)
+			}
+
+			log.Info("student collected", slog.String("kind", kind), slog.String("student", studID))
+			c.metrics.GCRemovedTotal.WithLabelValues(kind, strconv.FormatBool(c.dryRun)).Inc()
+			removed++
+		}
+
+		if len(students) < c.batchSize {
+			return removed, nil
+		}
+		after = students[len(students)-1]
+	}
+}
+
+// collectCache drops cached jwts and marks of students which aren't stored.
+func (c *Collector) collectCache(ctx context.Context, log *slog.Logger) (removed int, err error) {
+	cached, err := c.studCache.CachedStudents(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for start := 0; start < len(cached); start += c.batchSize {
+		batch := cached[start:min(start+c.batchSize, len(cached))]
+
+		existing, err := c.studStorage.ExistingStudents(ctx, batch)
+		if err != nil {
+			return removed, err
+		}
+
+		stored := make(map[string]struct{}, len(existing))
+		for _, studID := range existing {
+			stored[studID] = struct{}{}
+		}
+
+		for _, studID := range batch {
+			if _, ok := stored[studID]; ok {
+				continue
+			}
+
+			if !c.dryRun {
+				if err := c.studCache.InvalidateStudent(ctx, studID); err != nil && !errors.Is(err, cache.ErrTokenNotFound) {
+					return removed, err
+				}
+			}
+
+			log.Info("cache collected", slog.String("student", studID))
+			c.metrics.GCRemovedTotal.WithLabelValues(metrics.GCCache, strconv.FormatBool(c.dryRun)).Inc()
+			removed++
+		}
+	}
+
+	return removed, nil
+}
+
+// invalidate drops the deleted student's cache. Entries left on failure are
+// collected by a later run.
+func (c *Collector) invalidate(ctx context.Context, log *slog.Logger, studID string) {
+	if err := c.studCache.InvalidateStudent(ctx, studID); err != nil && !errors.Is(err, cache.ErrTokenNotFound) {
+		log.Warn("failed to invalidate collected student cache", slog.String("student", studID), "error", err)
+		c.metrics.CacheModifyTotal.WithLabelValues(metrics.ServiceGC, metrics.ActionDelete, metrics.StatusErr).Inc()
+		return
+	}
+	c.metrics.CacheModifyTotal.WithLabelValues(metrics.ServiceGC, metrics.ActionDelete, metrics.StatusOk).Inc()
+}
@@ -1,7 +1,10 @@
 package marks
 
 import (
+	"Elschool-API/internal/config"
 	"Elschool-API/internal/domain/models"
+	"Elschool-API/internal/infra/cache"
+	"Elschool-API/internal/infra/diary"
 	"Elschool-API/internal/infra/metrics"
 	"Elschool-API/internal/infra/storage"
 	"Elschool-API/internal/service"
@@ -9,17 +12,30 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
+	"slices"
+	"sync"
 	"time"
 )
 
+const (
+	touchInterval = time.Hour
+	touchTimeout  = 5 * time.Second
+)
+
 type StudentStorage interface {
 	ReadStudent(ctx context.Context, studentToken string) (student models.Student, err error)
+	FindStudentProvider(ctx context.Context, studentToken string) (provider, instance string, err error)
 	CheckRelation(ctx context.Context, userToken, studentToken string) (err error)
+	TouchStudent(ctx context.Context, studentToken string, usedAt time.Time) (err error)
+}
+
+type UserTokenStorage interface {
+	ResolveUserToken(ctx context.Context, userToken string) (userID string, err error)
 }
 
-type StudentAuth interface {
-	AuthStudent(ctx context.Context, login, password string) (jwt string, err error)
-	CheckToken(ctx context.Context, jwt string) (status bool, err error)
+type DiaryProviders interface {
+	Provider(name string) (provider diary.Provider, err error)
 }
 
 type TokenCache interface {
@@ -28,12 +44,6 @@ type TokenCache interface {
 	DeleteToken(ctx context.Context, studentToken string) (err error)
 }
 
-type Fetcher interface {
-	FetchDayMarks(ctx context.Context, jwt, date string) (marks models.DayMarks, err error)
-	FetchAverageMarks(ctx context.Context, jwt string, period int32) (marks models.AverageMarks, err error)
-	FetchFinalMarks(ctx context.Context, jwt string) (marks models.FinalMarks, err error)
-}
-
 type MarksCache interface {
 	SaveDayMarks(ctx context.Context, studentToken string, marks models.DayMarks) (err error)
 	SaveAverageMarks(ctx context.Context, studentToken string, marks models.AverageMarks) (err error)
@@ -41,20 +51,49 @@ type MarksCache interface {
 	GetDayMarks(ctx context.Context, studentToken, date string) (marks models.DayMarks, err error)
 	GetAverageMarks(ctx context.Context, studentToken string, period int32) (marks models.AverageMarks, err error)
 	GetFinalMarks(ctx context.Context, studentToken string) (marks models.FinalMarks, err error)
+	GetLastDayMarks(ctx context.Context, studentToken, date string) (marks models.DayMarks, err error)
+	GetLastAverageMarks(ctx context.Context, studentToken string, period int32) (marks models.AverageMarks, err error)
+	GetLastFinalMarks(ctx context.Context, studentToken string) (marks models.FinalMarks, err error)
+}
+
+// Quotas limits how often a user may ask for marks. Subscriptions to marks
+// events aren't counted, they are long-lived.
+type Quotas interface {
+	AllowMarksRequest(ctx context.Context, userID string) (err error)
+}
+
+type MarksEvents interface {
+	PublishMarks(event models.MarksEvent)
+	SubscribeMarks(ctx context.Context, studentToken, lastEventID string) (sub models.MarksSubscription, err error)
+}
+
+type StreamTickets interface {
+	IssueTicket(ctx context.Context, ticket models.StreamTicket) (issued models.StreamTicket, err error)
+	RedeemTicket(ctx context.Context, id string) (ticket models.StreamTicket, err error)
 }
 
 type MarksService struct {
-	log         *slog.Logger
-	metrics     *metrics.Metrics
-	studStorage StudentStorage
-	tokenCache  TokenCache
-	marksCache  MarksCache
-	studAuth    StudentAuth
-	fetcher     Fetcher
+	log           *slog.Logger
+	metrics       *metrics.Metrics
+	studStorage   StudentStorage
+	usrTokStorage UserTokenStorage
+	tokenCache    TokenCache
+	marksCache    MarksCache
+	providers     DiaryProviders
+	events        MarksEvents
+	tickets       StreamTickets
+	quotas        Quotas
+
+	freshTimeout         time.Duration
+	refreshTimeout       time.Duration
+	sessionRefreshMargin time.Duration
+
+	// touched holds when each student's use was last stored.
+	touched sync.Map
 }
 
-func New(log *slog.Logger, studStorage StudentStorage, tokenCache TokenCache, marksCache MarksCache, studAuth StudentAuth, fetcher Fetcher, metricsInfra *metrics.Metrics) *MarksService {
-	return &MarksService{log: log, studStorage: studStorage, tokenCache: tokenCache, marksCache: marksCache, studAuth: studAuth, fetcher: fetcher, metrics: metricsInfra}
+func New(log *slog.Logger, studStorage StudentStorage, usrTokStorage UserTokenStorage, tokenCache TokenCache, marksCache MarksCache, providers DiaryProviders, events MarksEvents, tickets StreamTickets, quotas Quotas, metricsInfra *metrics.Metrics, cfg config.MarksConfig) *MarksService {
+	return &MarksService{log: log, studStorage: studStorage, usrTokStorage: usrTokStorage, tokenCache: tokenCache, marksCache: marksCache, providers: providers, events: events, tickets: tickets, quotas: quotas, metrics: metricsInfra, freshTimeout: cfg.FreshTimeout, refreshTimeout: cfg.RefreshTimeout, sessionRefreshMargin: cfg.SessionRefreshMargin}
 }
 
 type Marks interface {
@@ -63,9 +102,20 @@ type Marks interface {
 	GetFinalMarks(ctx context.Context, userToken, studentToken string) (marks models.FinalMarks, err error)
 }
 
-func (m *MarksService) GetDayMarks(ctx context.Context, userID, studID, date string) (marks models.DayMarks, err error) {
+func (m *MarksService) GetDayMarks(ctx context.Context, userToken, studID, date string) (marks models.DayMarks, err error) {
 	const op = "services.marks.GetDayMarks"
 
+	userID, err := m.resolveUser(ctx, userToken)
+	if err != nil {
+		m.metrics.MarksRequests.WithLabelValues(metrics.TypeDay, metrics.StatusErr).Inc()
+		return models.DayMarks{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = m.quotas.AllowMarksRequest(ctx, userID); err != nil {
+		m.metrics.MarksRequests.WithLabelValues(metrics.TypeDay, metrics.StatusErr).Inc()
+		return models.DayMarks{}, fmt.Errorf("%s: %w", op, err)
+	}
+
 	log := m.log.With(slog.String("op", op), slog.String("user", userID), slog.String("student", studID))
 	log.Info("getting day marks")
 
@@ -90,6 +140,8 @@ func (m *MarksService) GetDayMarks(ctx context.Context, userID, studID, date str
 	}
 	m.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceMarks, metrics.ActionRead, metrics.StatusOk).Inc()
 
+	m.touchStudent(ctx, log, studID)
+
 	marks, err = m.marksCache.GetDayMarks(ctx, studID, date)
 	if err == nil {
 		log.Info("day marks found in cache")
@@ -101,50 +153,44 @@ func (m *MarksService) GetDayMarks(ctx context.Context, userID, studID, date str
 
 	log.Info("failed to get day marks from cache", "error", err)
 
-	jwt, err := m.getToken(ctx, studID)
-
+	marks, stale, err := revalidate(ctx, m.freshTimeout, m.refreshTimeout,
+		func(ctx context.Context) (models.DayMarks, error) {
+			return m.fetchDayMarks(ctx, log, studID, date)
+		},
+		func(ctx context.Context) (models.DayMarks, error) {
+			return m.marksCache.GetLastDayMarks(ctx, studID, date)
+		},
+	)
 	if err != nil {
-		log.Error("failed to get jwt", "error", err)
 		m.metrics.MarksRequests.WithLabelValues(metrics.TypeDay, metrics.StatusErr).Inc()
 		return models.DayMarks{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	start := time.Now()
-	defer func() {
-		m.metrics.ElschoolFetchDuration.WithLabelValues(metrics.TypeDay).
-			Observe(time.Since(start).Seconds())
-	}()
-
-	marks, err = m.fetcher.FetchDayMarks(ctx, jwt, date)
-	if err != nil {
-		log.Error("failed to fetch day marks", "error", err)
-		m.metrics.ElschoolFetchTotal.WithLabelValues(metrics.TypeDay, metrics.StatusErr).Inc()
-		m.metrics.MarksRequests.WithLabelValues(metrics.TypeDay, metrics.StatusErr).Inc()
-		return models.DayMarks{}, fmt.Errorf("%s: %w", op, err)
+	if stale {
+		log.Warn("serving stale day marks", slog.Time("fetched_at", marks.FetchedAt))
+		marks.Stale = true
+		m.metrics.MarksRequests.WithLabelValues(metrics.TypeDay, metrics.StatusStale).Inc()
+		return marks, nil
 	}
-	log.Info("day marks fetched")
-	m.metrics.ElschoolFetchTotal.WithLabelValues(metrics.TypeDay, metrics.StatusOk).Inc()
 	m.metrics.MarksRequests.WithLabelValues(metrics.TypeDay, metrics.StatusOk).Inc()
 
-	go func() {
-		cacheCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-
-		errCache := m.marksCache.SaveDayMarks(cacheCtx, studID, marks)
-		if errCache != nil {
-			log.Warn("failed to cache day marks", "error", errCache)
-			m.metrics.CacheModifyTotal.WithLabelValues(metrics.ServiceMarks, metrics.ActionWrite, metrics.StatusErr).Inc()
-		} else {
-			m.metrics.CacheModifyTotal.WithLabelValues(metrics.ServiceMarks, metrics.ActionWrite, metrics.StatusOk).Inc()
-		}
-	}()
-
 	return marks, nil
 }
 
-func (m *MarksService) GetAverageMarks(ctx context.Context, userID, studID string, period int32) (marks models.AverageMarks, err error) {
+func (m *MarksService) GetAverageMarks(ctx context.Context, userToken, studID string, period int32) (marks models.AverageMarks, err error) {
 	const op = "services.marks.GetAverageMarks"
 
+	userID, err := m.resolveUser(ctx, userToken)
+	if err != nil {
+		m.metrics.MarksRequests.WithLabelValues(metrics.TypeAverage, metrics.StatusErr).Inc()
+		return models.AverageMarks{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = m.quotas.AllowMarksRequest(ctx, userID); err != nil {
+		m.metrics.MarksRequests.WithLabelValues(metrics.TypeAverage, metrics.StatusErr).Inc()
+		return models.AverageMarks{}, fmt.Errorf("%s: %w", op, err)
+	}
+
 	log := m.log.With(slog.String("op", op), slog.String("user", userID), slog.String("student", studID))
 	log.Info("getting average marks")
 
@@ -169,6 +215,8 @@ func (m *MarksService) GetAverageMarks(ctx context.Context, userID, studID strin
 	}
 	m.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceMarks, metrics.ActionRead, metrics.StatusOk).Inc()
 
+	m.touchStudent(ctx, log, studID)
+
 	marks, errCache := m.marksCache.GetAverageMarks(ctx, studID, period)
 	if errCache == nil {
 		log.Info("average marks found in cache")
@@ -180,49 +228,44 @@ func (m *MarksService) GetAverageMarks(ctx context.Context, userID, studID strin
 
 	log.Info("failed to get average marks from cache", "error", errCache)
 
-	jwt, err := m.getToken(ctx, studID)
-
+	marks, stale, err := revalidate(ctx, m.freshTimeout, m.refreshTimeout,
+		func(ctx context.Context) (models.AverageMarks, error) {
+			return m.fetchAverageMarks(ctx, log, studID, period)
+		},
+		func(ctx context.Context) (models.AverageMarks, error) {
+			return m.marksCache.GetLastAverageMarks(ctx, studID, period)
+		},
+	)
 	if err != nil {
-		log.Error("failed to get jwt", "error", err)
 		m.metrics.MarksRequests.WithLabelValues(metrics.TypeAverage, metrics.StatusErr).Inc()
 		return models.AverageMarks{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	start := time.Now()
-	defer func() {
-		m.metrics.ElschoolFetchDuration.WithLabelValues(metrics.TypeAverage).
-			Observe(time.Since(start).Seconds())
-	}()
-
-	marks, err = m.fetcher.FetchAverageMarks(ctx, jwt, period)
-	if err != nil {
-		log.Error("failed to fetch average marks", "error", err)
-		m.metrics.ElschoolFetchTotal.WithLabelValues(metrics.TypeAverage, metrics.StatusErr).Inc()
-		m.metrics.MarksRequests.WithLabelValues(metrics.TypeAverage, metrics.StatusErr).Inc()
-		return models.AverageMarks{}, fmt.Errorf("%s: %w", op, err)
+	if stale {
+		log.Warn("serving stale average marks", slog.Time("fetched_at", marks.FetchedAt))
+		marks.Stale = true
+		m.metrics.MarksRequests.WithLabelValues(metrics.TypeAverage, metrics.StatusStale).Inc()
+		return marks, nil
 	}
-	log.Info("average marks fetched")
-	m.metrics.ElschoolFetchTotal.WithLabelValues(metrics.TypeAverage, metrics.StatusOk).Inc()
 	m.metrics.MarksRequests.WithLabelValues(metrics.TypeAverage, metrics.StatusOk).Inc()
 
-	go func() {
-		cacheCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-		errCache = m.marksCache.SaveAverageMarks(cacheCtx, studID, marks)
-		if errCache != nil {
-			log.Warn("failed to cache average marks", "error", errCache)
-			m.metrics.CacheModifyTotal.WithLabelValues(metrics.ServiceMarks, metrics.ActionWrite, metrics.StatusErr).Inc()
-		} else {
-			m.metrics.CacheModifyTotal.WithLabelValues(metrics.ServiceMarks, metrics.ActionWrite, metrics.StatusOk).Inc()
-		}
-	}()
-
 	return marks, nil
 }
 
-func (m *MarksService) GetFinalMarks(ctx context.Context, userID, studID string) (marks models.FinalMarks, err error) {
+func (m *MarksService) GetFinalMarks(ctx context.Context, userToken, studID string) (marks models.FinalMarks, err error) {
 	const op = "services.marks.GetFinalMarks"
 
+	userID, err := m.resolveUser(ctx, userToken)
+	if err != nil {
+		m.metrics.MarksRequests.WithLabelValues(metrics.TypeFinal, metrics.StatusErr).Inc()
+		return models.FinalMarks{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = m.quotas.AllowMarksRequest(ctx, userID); err != nil {
+		m.metrics.MarksRequests.WithLabelValues(metrics.TypeFinal, metrics.StatusErr).Inc()
+		return models.FinalMarks{}, fmt.Errorf("%s: %w", op, err)
+	}
+
 	log := m.log.With(slog.String("op", op), slog.String("user", userID), slog.String("student", studID))
 	log.Info("getting final marks")
 
@@ -247,6 +290,8 @@ func (m *MarksService) GetFinalMarks(ctx context.Context, userID, studID string)
 	}
 	m.metrics.StorageRequestsTotal.WithLabelValues(metrics.TypeFinal, metrics.ActionRead, metrics.StatusOk).Inc()
 
+	m.touchStudent(ctx, log, studID)
+
 	marks, err = m.marksCache.GetFinalMarks(ctx, studID)
 	if err == nil {
 		log.Info("final marks found in cache")
@@ -258,35 +303,259 @@ func (m *MarksService) GetFinalMarks(ctx context.Context, userID, studID string)
 
 	log.Info("failed to get final marks from cache", "error", err)
 
-	jwt, err := m.getToken(ctx, studID)
+	marks, stale, err := revalidate(ctx, m.freshTimeout, m.refreshTimeout,
+		func(ctx context.Context) (models.FinalMarks, error) {
+			return m.fetchFinalMarks(ctx, log, studID)
+		},
+		func(ctx context.Context) (models.FinalMarks, error) {
+			return m.marksCache.GetLastFinalMarks(ctx, studID)
+		},
+	)
+	if err != nil {
+		m.metrics.MarksRequests.WithLabelValues(metrics.TypeFinal, metrics.StatusErr).Inc()
+		return models.FinalMarks{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if stale {
+		log.Warn("serving stale final marks", slog.Time("fetched_at", marks.FetchedAt))
+		marks.Stale = true
+		m.metrics.MarksRequests.WithLabelValues(metrics.TypeFinal, metrics.StatusStale).Inc()
+		return marks, nil
+	}
+	m.metrics.MarksRequests.WithLabelValues(metrics.TypeFinal, metrics.StatusOk).Inc()
+
+	return marks, nil
+}
+
+func (m *MarksService) fetchDayMarks(ctx context.Context, log *slog.Logger, studID, date string) (marks models.DayMarks, err error) {
+	const op = "services.marks.fetchDayMarks"
+
+	provider, instance, err := m.studentProvider(ctx, log, studID)
+	if err != nil {
+		return models.DayMarks{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !provider.Capabilities().DayMarks {
+		log.Error("day marks aren't supported by the provider")
+		return models.DayMarks{}, fmt.Errorf("%s: %w", op, service.ErrNotSupported)
+	}
 
+	jwt, err := m.getToken(ctx, studID, provider, instance)
 	if err != nil {
 		log.Error("failed to get jwt", "error", err)
-		m.metrics.MarksRequests.WithLabelValues(metrics.TypeFinal, metrics.StatusOk).Inc()
-		return models.FinalMarks{}, fmt.Errorf("%s: %w", op, err)
+		if errors.Is(err, diary.ErrUnavailable) {
+			return models.DayMarks{}, fmt.Errorf("%s: %w", op, service.ErrUnavailable)
+		}
+		return models.DayMarks{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	start := time.Now()
+	marks, err = provider.FetchDayMarks(ctx, instance, jwt, date)
+	if errors.Is(err, diary.ErrSessionExpired) {
+		if jwt, err = m.renewToken(ctx, log, studID, provider, instance, err); err == nil {
+			marks, err = provider.FetchDayMarks(ctx, instance, jwt, date)
+		}
+	}
+	m.metrics.ElschoolFetchDuration.WithLabelValues(metrics.TypeDay).Observe(time.Since(start).Seconds())
+
+	if err != nil {
+		log.Error("failed to fetch day marks", "error", err)
+		m.metrics.ElschoolFetchTotal.WithLabelValues(metrics.TypeDay, metrics.StatusErr).Inc()
+		if errors.Is(err, diary.ErrUnavailable) {
+			return models.DayMarks{}, fmt.Errorf("%s: %w", op, service.ErrUnavailable)
+		}
+		if errors.Is(err, diary.ErrMarkupChanged) {
+			return models.DayMarks{}, fmt.Errorf("%s: %w", op, service.ErrMarkupChanged)
+		}
+		return models.DayMarks{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(marks.Warnings) > 0 {
+		log.Warn("day marks parsed partially", slog.Int("warnings", len(marks.Warnings)), slog.Any("first", marks.Warnings[0]))
+	}
+	log.Info("day marks fetched")
+	m.metrics.ElschoolFetchTotal.WithLabelValues(metrics.TypeDay, metrics.StatusOk).Inc()
+
+	marks.FetchedAt = time.Now().UTC()
+
+	go func() {
+		cacheCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		last, errLast := m.marksCache.GetLastDayMarks(cacheCtx, studID, date)
+
+		saved := marks
+		if errLast == nil && len(saved.Warnings) > 0 {
+			saved.Marks = withSkippedSubjects(last.Marks, saved.Marks)
+		}
+
+		errCache := m.marksCache.SaveDayMarks(cacheCtx, studID, saved)
+
+		if errLast == nil {
+			if subjects := changedSubjects(last.Marks, saved.Marks, slices.Equal[[]int32]); len(subjects) > 0 {
+				m.events.PublishMarks(models.MarksEvent{StudentID: studID, Type: models.MarksTypeDay, Subjects: subjects, Day: &saved})
+			}
+		}
+
+		if errCache != nil {
+			log.Warn("failed to cache day marks", "error", errCache)
+			m.metrics.CacheModifyTotal.WithLabelValues(metrics.ServiceMarks, metrics.ActionWrite, metrics.StatusErr).Inc()
+		} else {
+			m.metrics.CacheModifyTotal.WithLabelValues(metrics.ServiceMarks, metrics.ActionWrite, metrics.StatusOk).Inc()
+		}
+	}()
+
+	return marks, nil
+}
+
+func (m *MarksService) fetchAverageMarks(ctx context.Context, log *slog.Logger, studID string, period int32) (marks models.AverageMarks, err error) {
+	const op = "services.marks.fetchAverageMarks"
+
+	provider, instance, err := m.studentProvider(ctx, log, studID)
+	if err != nil {
+		return models.AverageMarks{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !provider.Capabilities().AverageMarks {
+		log.Error("average marks aren't supported by the provider")
+		return models.AverageMarks{}, fmt.Errorf("%s: %w", op, service.ErrNotSupported)
+	}
+
+	jwt, err := m.getToken(ctx, studID, provider, instance)
+	if err != nil {
+		log.Error("failed to get jwt", "error", err)
+		if errors.Is(err, diary.ErrUnavailable) {
+			return models.AverageMarks{}, fmt.Errorf("%s: %w", op, service.ErrUnavailable)
+		}
+		return models.AverageMarks{}, fmt.Errorf("%s: %w", op, err)
 	}
 
 	start := time.Now()
-	defer func() {
-		m.metrics.ElschoolFetchDuration.WithLabelValues(metrics.TypeFinal).
-			Observe(time.Since(start).Seconds())
+	marks, err = provider.FetchAverageMarks(ctx, instance, jwt, period)
+	if errors.Is(err, diary.ErrSessionExpired) {
+		if jwt, err = m.renewToken(ctx, log, studID, provider, instance, err); err == nil {
+			marks, err = provider.FetchAverageMarks(ctx, instance, jwt, period)
+		}
+	}
+	m.metrics.ElschoolFetchDuration.WithLabelValues(metrics.TypeAverage).Observe(time.Since(start).Seconds())
+
+	if err != nil {
+		log.Error("failed to fetch average marks", "error", err)
+		m.metrics.ElschoolFetchTotal.WithLabelValues(metrics.TypeAverage, metrics.StatusErr).Inc()
+		if errors.Is(err, diary.ErrUnavailable) {
+			return models.AverageMarks{}, fmt.Errorf("%s: %w", op, service.ErrUnavailable)
+		}
+		if errors.Is(err, diary.ErrMarkupChanged) {
+			return models.AverageMarks{}, fmt.Errorf("%s: %w", op, service.ErrMarkupChanged)
+		}
+		return models.AverageMarks{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(marks.Warnings) > 0 {
+		log.Warn("average marks parsed partially", slog.Int("warnings", len(marks.Warnings)), slog.Any("first", marks.Warnings[0]))
+	}
+	log.Info("average marks fetched")
+	m.metrics.ElschoolFetchTotal.WithLabelValues(metrics.TypeAverage, metrics.StatusOk).Inc()
+
+	marks.FetchedAt = time.Now().UTC()
+
+	go func() {
+		cacheCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		last, errLast := m.marksCache.GetLastAverageMarks(cacheCtx, studID, period)
+
+		saved := marks
+		if errLast == nil && len(saved.Warnings) > 0 {
+			saved.Marks = withSkippedSubjects(last.Marks, saved.Marks)
+		}
+
+		errCache := m.marksCache.SaveAverageMarks(cacheCtx, studID, saved)
+
+		if errLast == nil {
+			if subjects := changedSubjects(last.Marks, saved.Marks, func(a, b string) bool { return a == b }); len(subjects) > 0 {
+				m.events.PublishMarks(models.MarksEvent{StudentID: studID, Type: models.MarksTypeAverage, Subjects: subjects, Average: &saved})
+			}
+		}
+
+		if errCache != nil {
+			log.Warn("failed to cache average marks", "error", errCache)
+			m.metrics.CacheModifyTotal.WithLabelValues(metrics.ServiceMarks, metrics.ActionWrite, metrics.StatusErr).Inc()
+		} else {
+			m.metrics.CacheModifyTotal.WithLabelValues(metrics.ServiceMarks, metrics.ActionWrite, metrics.StatusOk).Inc()
+		}
 	}()
 
-	marks, err = m.fetcher.FetchFinalMarks(ctx, jwt)
+	return marks, nil
+}
+
+func (m *MarksService) fetchFinalMarks(ctx context.Context, log *slog.Logger, studID string) (marks models.FinalMarks, err error) {
+	const op = "services.marks.fetchFinalMarks"
+
+	provider, instance, err := m.studentProvider(ctx, log, studID)
+	if err != nil {
+		return models.FinalMarks{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !provider.Capabilities().FinalMarks {
+		log.Error("final marks aren't supported by the provider")
+		return models.FinalMarks{}, fmt.Errorf("%s: %w", op, service.ErrNotSupported)
+	}
+
+	jwt, err := m.getToken(ctx, studID, provider, instance)
+	if err != nil {
+		log.Error("failed to get jwt", "error", err)
+		if errors.Is(err, diary.ErrUnavailable) {
+			return models.FinalMarks{}, fmt.Errorf("%s: %w", op, service.ErrUnavailable)
+		}
+		return models.FinalMarks{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	start := time.Now()
+	marks, err = provider.FetchFinalMarks(ctx, instance, jwt)
+	if errors.Is(err, diary.ErrSessionExpired) {
+		if jwt, err = m.renewToken(ctx, log, studID, provider, instance, err); err == nil {
+			marks, err = provider.FetchFinalMarks(ctx, instance, jwt)
+		}
+	}
+	m.metrics.ElschoolFetchDuration.WithLabelValues(metrics.TypeFinal).Observe(time.Since(start).Seconds())
+
 	if err != nil {
 		log.Error("failed to fetch final marks", "error", err)
 		m.metrics.ElschoolFetchTotal.WithLabelValues(metrics.TypeFinal, metrics.StatusErr).Inc()
+		if errors.Is(err, diary.ErrUnavailable) {
+			return models.FinalMarks{}, fmt.Errorf("%s: %w", op, service.ErrUnavailable)
+		}
+		if errors.Is(err, diary.ErrMarkupChanged) {
+			return models.FinalMarks{}, fmt.Errorf("%s: %w", op, service.ErrMarkupChanged)
+		}
 		return models.FinalMarks{}, fmt.Errorf("%s: %w", op, err)
 	}
-
+	if len(marks.Warnings) > 0 {
+		log.Warn("final marks parsed partially", slog.Int("warnings", len(marks.Warnings)), slog.Any("first", marks.Warnings[0]))
+	}
 	log.Info("final marks fetched")
 	m.metrics.ElschoolFetchTotal.WithLabelValues(metrics.TypeFinal, metrics.StatusOk).Inc()
-	m.metrics.MarksRequests.WithLabelValues(metrics.TypeFinal, metrics.StatusOk).Inc()
+
+	marks.FetchedAt = time.Now().UTC()
 
 	go func() {
 		cacheCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
-		errCache := m.marksCache.SaveFinalMarks(cacheCtx, studID, marks)
+
+		last, errLast := m.marksCache.GetLastFinalMarks(cacheCtx, studID)
+
+		saved := marks
+		if errLast == nil && len(saved.Warnings) > 0 {
+			saved.Marks = withSkippedSubjects(last.Marks, saved.Marks)
+		}
+
+		errCache := m.marksCache.SaveFinalMarks(cacheCtx, studID, saved)
+
+		if errLast == nil {
+			if subjects := changedSubjects(last.Marks, saved.Marks, slices.Equal[[]int32]); len(subjects) > 0 {
+				m.events.PublishMarks(models.MarksEvent{StudentID: studID, Type: models.MarksTypeFinal, Subjects: subjects, Final: &saved})
+			}
+		}
+
 		if errCache != nil {
 			log.Warn("failed to cache final marks", "error", errCache)
 			m.metrics.CacheModifyTotal.WithLabelValues(metrics.ServiceMarks, metrics.ActionWrite, metrics.StatusErr).Inc()
@@ -298,7 +567,168 @@ func (m *MarksService) GetFinalMarks(ctx context.Context, userID, studID string)
 	return marks, nil
 }
 
-func (m *MarksService) getToken(ctx context.Context, studID string) (token string, err error) {
+// CreateEventsTicket issues a ticket to open a marks events stream of the
+// student with. Streams are opened by browsers, which can't send the user
+// token and the api key, so the ticket stands for them once.
+func (m *MarksService) CreateEventsTicket(ctx context.Context, userToken, studID string) (ticket models.StreamTicket, err error) {
+	const op = "services.marks.CreateEventsTicket"
+
+	userID, err := m.resolveUser(ctx, userToken)
+	if err != nil {
+		return models.StreamTicket{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log := m.log.With(slog.String("op", op), slog.String("user", userID), slog.String("student", studID))
+
+	if err = m.checkWatcher(ctx, log, userID, studID); err != nil {
+		return models.StreamTicket{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	ticket, err = m.tickets.IssueTicket(ctx, models.StreamTicket{UserID: userID, StudentID: studID})
+	if err != nil {
+		log.Error("failed to issue events ticket", "error", err)
+		return models.StreamTicket{}, fmt.Errorf("%s: %w", op, err)
+	}
+	log.Info("events ticket issued")
+
+	return ticket, nil
+}
+
+// WatchMarks redeems the ticket and subscribes to changes of the student's
+// marks, resuming after lastEventID when it is set.
+func (m *MarksService) WatchMarks(ctx context.Context, ticketID, studID, lastEventID string) (sub models.MarksSubscription, err error) {
+	const op = "services.marks.WatchMarks"
+
+	ticket, err := m.tickets.RedeemTicket(ctx, ticketID)
+	if err != nil {
+		if errors.Is(err, cache.ErrTicketNotFound) {
+			return models.MarksSubscription{}, fmt.Errorf("%s: %w", op, service.ErrTicketNotFound)
+		}
+		m.log.Error("failed to redeem events ticket", slog.String("op", op), "error", err)
+		return models.MarksSubscription{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if ticket.StudentID != studID {
+		return models.MarksSubscription{}, fmt.Errorf("%s: %w", op, service.ErrTicketNotFound)
+	}
+
+	log := m.log.With(slog.String("op", op), slog.String("user", ticket.UserID), slog.String("student", studID))
+	log.Info("watching marks")
+
+	// The student may have been unlinked since the ticket was issued.
+	if err = m.checkWatcher(ctx, log, ticket.UserID, studID); err != nil {
+		return models.MarksSubscription{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	m.touchStudent(ctx, log, studID)
+
+	sub, err = m.events.SubscribeMarks(ctx, studID, lastEventID)
+	if err != nil {
+		log.Error("failed to subscribe to marks events", "error", err)
+		return models.MarksSubscription{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return sub, nil
+}
+
+// checkWatcher checks the user has the student whose marks are watched.
+func (m *MarksService) checkWatcher(ctx context.Context, log *slog.Logger, userID, studID string) error {
+	err := m.studStorage.CheckRelation(ctx, userID, studID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			m.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceMarks, metrics.ActionRead, metrics.StatusOk).Inc()
+			log.Error("no such user", "error", err)
+			return service.ErrUserNotFound
+		}
+		if errors.Is(err, storage.ErrStudentNotFound) {
+			m.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceMarks, metrics.ActionRead, metrics.StatusOk).Inc()
+			log.Error("no such student", "error", err)
+			return service.ErrStudentNotFound
+		}
+
+		log.Error("failed to check relation", "error", err)
+		m.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceMarks, metrics.ActionRead, metrics.StatusErr).Inc()
+		return err
+	}
+	m.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceMarks, metrics.ActionRead, metrics.StatusOk).Inc()
+
+	return nil
+}
+
+// touchStudent stores that the student is in use, so the GC keeps it. It is
+// stored at most once per touchInterval and doesn't delay the request.
+func (m *MarksService) touchStudent(ctx context.Context, log *slog.Logger, studID string) {
+	now := time.Now()
+	if last, ok := m.touched.Load(studID); ok && now.Sub(last.(time.Time)) < touchInterval {
+		return
+	}
+	m.touched.Store(studID, now)
+
+	go func() {
+		touchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), touchTimeout)
+		defer cancel()
+
+		if err := m.studStorage.TouchStudent(touchCtx, studID, now); err != nil {
+			log.Warn("failed to store student use", "error", err)
+			m.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceMarks, metrics.ActionUpdate, metrics.StatusErr).Inc()
+			m.touched.Delete(studID)
+			return
+		}
+		m.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceMarks, metrics.ActionUpdate, metrics.StatusOk).Inc()
+	}()
+}
+
+// resolveUser maps the user token to the id relations are stored with.
+func (m *MarksService) resolveUser(ctx context.Context, userToken string) (userID string, err error) {
+	const op = "services.marks.resolveUser"
+
+	userID, err = m.usrTokStorage.ResolveUserToken(ctx, userToken)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			m.log.Error("no such user token", slog.String("op", op), "error", err)
+			m.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceMarks, metrics.ActionRead, metrics.StatusOk).Inc()
+			return "", fmt.Errorf("%s: %w", op, service.ErrUserNotFound)
+		}
+
+		m.log.Error("failed to resolve user token", slog.String("op", op), "error", err)
+		m.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceMarks, metrics.ActionRead, metrics.StatusErr).Inc()
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	m.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceMarks, metrics.ActionRead, metrics.StatusOk).Inc()
+
+	return userID, nil
+}
+
+// studentProvider finds the diary provider and its instance the student is
+// bound to.
+func (m *MarksService) studentProvider(ctx context.Context, log *slog.Logger, studID string) (provider diary.Provider, instance string, err error) {
+	const op = "services.marks.studentProvider"
+
+	name, instance, err := m.studStorage.FindStudentProvider(ctx, studID)
+	if err != nil {
+		if errors.Is(err, storage.ErrStudentNotFound) {
+			log.Error("no such student", "error", err)
+			m.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceMarks, metrics.ActionRead, metrics.StatusOk).Inc()
+			return nil, "", fmt.Errorf("%s: %w", op, service.ErrStudentNotFound)
+		}
+		log.Error("failed to find student provider in storage", "error", err)
+		m.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceMarks, metrics.ActionRead, metrics.StatusErr).Inc()
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+	m.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceMarks, metrics.ActionRead, metrics.StatusOk).Inc()
+
+	provider, err = m.providers.Provider(name)
+	if err != nil {
+		log.Error("student is bound to unknown provider", slog.String("provider", name), "error", err)
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return provider, instance, nil
+}
+
+// getToken returns a session of the student with the provider. A cached
+// session is trusted until shortly before it expires, then it is refreshed,
+// and the student is authenticated anew once it can't be.
+func (m *MarksService) getToken(ctx context.Context, studID string, provider diary.Provider, instance string) (token string, err error) {
 	const op = "services.marks.getToken"
 
 	log := m.log.With(slog.String("op", op), slog.String("student", studID))
@@ -307,35 +737,82 @@ func (m *MarksService) getToken(ctx context.Context, studID string) (token strin
 	token, err = m.tokenCache.FindToken(ctx, studID)
 	if err == nil {
 		log.Info("token found in cache")
-		m.metrics.TokenCacheRateTotal.WithLabelValues(metrics.StatusHit)
+		m.metrics.TokenCacheRateTotal.WithLabelValues(metrics.StatusHit).Inc()
 
-		start := time.Now()
-		status, err := m.studAuth.CheckToken(ctx, token)
-		m.metrics.ElschoolAuthDuration.WithLabelValues(metrics.MethodCheck).Observe(time.Since(start).Seconds())
+		expiry, ok := provider.SessionExpiry(token)
+		if !ok || time.Until(expiry) > m.sessionRefreshMargin {
+			return token, nil
+		}
 
-		if err != nil {
+		refreshed, err := m.refreshToken(ctx, log, provider, instance, token, expiry)
+		if err == nil {
+			if refreshed != token {
+				m.cacheToken(log, studID, refreshed)
+			}
+			return refreshed, nil
+		} else if !errors.Is(err, diary.ErrSessionExpired) {
 			log.Warn("failed check of cached token", "error", err)
-			m.metrics.ElschoolAuthTotal.WithLabelValues(metrics.MethodCheck, metrics.StatusErr).Inc()
-		} else if status {
-			m.metrics.ElschoolAuthTotal.WithLabelValues(metrics.MethodCheck, metrics.StatusOk).Inc()
-			return token, nil
 		} else {
 			log.Warn("wrong cached token", "error", err)
+			m.dropToken(ctx, log, studID)
+		}
+	} else {
+		m.metrics.TokenCacheRateTotal.WithLabelValues(metrics.StatusMiss).Inc()
+		log.Info("failed token search in cache", "error", err)
+	}
+
+	token, err = m.authenticate(ctx, log, studID, provider, instance)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return token, nil
+}
+
+// refreshToken asks the provider to refresh a session close to its expiry.
+// A session past its expiry isn't worth asking about.
+func (m *MarksService) refreshToken(ctx context.Context, log *slog.Logger, provider diary.Provider, instance, token string, expiry time.Time) (refreshed string, err error) {
+	const op = "services.marks.refreshToken"
+
+	if time.Now().After(expiry) {
+		return "", fmt.Errorf("%s: %w", op, diary.ErrSessionExpired)
+	}
+
+	start := time.Now()
+	refreshed, err = provider.RefreshSession(ctx, instance, token)
+	m.metrics.ElschoolAuthDuration.WithLabelValues(metrics.MethodCheck).Observe(time.Since(start).Seconds())
 
-			go func() {
-				errCache := m.tokenCache.DeleteToken(ctx, studID)
-				if errCache != nil {
-					log.Warn("failed invalidating of wrong cached token", "error", errCache)
-					m.metrics.CacheModifyTotal.WithLabelValues(metrics.ServiceMarks, metrics.ActionDelete, metrics.StatusErr).Inc()
-				} else {
-					m.metrics.CacheModifyTotal.WithLabelValues(metrics.ServiceMarks, metrics.ActionDelete, metrics.StatusOk).Inc()
-				}
-			}()
+	if err != nil {
+		if !errors.Is(err, diary.ErrSessionExpired) {
+			m.metrics.ElschoolAuthTotal.WithLabelValues(metrics.MethodCheck, metrics.StatusErr).Inc()
 		}
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	m.metrics.ElschoolAuthTotal.WithLabelValues(metrics.MethodCheck, metrics.StatusOk).Inc()
+
+	return refreshed, nil
+}
+
+// renewToken replaces a session the provider rejected while fetching, a
+// trusted cached session may have been ended early.
+func (m *MarksService) renewToken(ctx context.Context, log *slog.Logger, studID string, provider diary.Provider, instance string, rejected error) (token string, err error) {
+	const op = "services.marks.renewToken"
+
+	log.Warn("session rejected, authenticating anew", "error", rejected)
+	m.dropToken(ctx, log, studID)
+
+	token, err = m.authenticate(ctx, log, studID, provider, instance)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
 	}
-	m.metrics.TokenCacheRateTotal.WithLabelValues(metrics.StatusMiss)
 
-	log.Info("failed token search in cache", "error", err)
+	return token, nil
+}
+
+// authenticate logs the student on with the stored credentials and caches
+// the session.
+func (m *MarksService) authenticate(ctx context.Context, log *slog.Logger, studID string, provider diary.Provider, instance string) (token string, err error) {
+	const op = "services.marks.authenticate"
 
 	student, err := m.studStorage.ReadStudent(ctx, studID)
 	if err != nil {
@@ -351,7 +828,7 @@ func (m *MarksService) getToken(ctx context.Context, studID string) (token strin
 	m.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceMarks, metrics.ActionRead, metrics.StatusOk).Inc()
 
 	start := time.Now()
-	token, err = m.studAuth.AuthStudent(ctx, student.Login, student.Password)
+	token, err = provider.Authenticate(ctx, instance, student.Login, student.Password)
 	m.metrics.ElschoolAuthDuration.WithLabelValues(metrics.MethodAuth).Observe(time.Since(start).Seconds())
 
 	if err != nil {
@@ -361,10 +838,27 @@ func (m *MarksService) getToken(ctx context.Context, studID string) (token strin
 	}
 	m.metrics.ElschoolAuthTotal.WithLabelValues(metrics.MethodAuth, metrics.StatusOk).Inc()
 
+	m.cacheToken(log, studID, token)
+
+	return token, nil
+}
+
+// dropToken invalidates a cached session. It is done before a new session
+// is cached, so the new one can't be dropped instead.
+func (m *MarksService) dropToken(ctx context.Context, log *slog.Logger, studID string) {
+	if err := m.tokenCache.DeleteToken(ctx, studID); err != nil {
+		log.Warn("failed invalidating of wrong cached token", "error", err)
+		m.metrics.CacheModifyTotal.WithLabelValues(metrics.ServiceMarks, metrics.ActionDelete, metrics.StatusErr).Inc()
+		return
+	}
+	m.metrics.CacheModifyTotal.WithLabelValues(metrics.ServiceMarks, metrics.ActionDelete, metrics.StatusOk).Inc()
+}
+
+func (m *MarksService) cacheToken(log *slog.Logger, studID, token string) {
 	go func() {
 		cacheCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
-		err = m.tokenCache.AddToken(cacheCtx, studID, token)
+		err := m.tokenCache.AddToken(cacheCtx, studID, token)
 		if err != nil {
 			log.Warn("failed to cache token", "error", err)
 			m.metrics.CacheModifyTotal.WithLabelValues(metrics.ServiceMarks, metrics.ActionWrite, metrics.StatusErr).Inc()
@@ -372,6 +866,96 @@ func (m *MarksService) getToken(ctx context.Context, studID string) (token strin
 			m.metrics.CacheModifyTotal.WithLabelValues(metrics.ServiceMarks, metrics.ActionWrite, metrics.StatusOk).Inc()
 		}
 	}()
+}
 
-	return token, nil
+// revalidate starts fetching fresh marks and waits for them up to freshTimeout.
+// If the diary is unavailable or too slow, the last known marks are served,
+// while the fetch keeps running up to refreshTimeout to refresh the cache.
+func revalidate[T any](ctx context.Context, freshTimeout, refreshTimeout time.Duration, fetch, last func(ctx context.Context) (T, error)) (marks T, stale bool, err error) {
+	type result struct {
+		marks T
+		err   error
+	}
+
+	results := make(chan result, 1)
+
+	go func() {
+		fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), refreshTimeout)
+		defer cancel()
+
+		marks, err := fetch(fetchCtx)
+		results <- result{marks: marks, err: err}
+	}()
+
+	timer := time.NewTimer(freshTimeout)
+	defer timer.Stop()
+
+	select {
+	case res := <-results:
+		if res.err == nil {
+			return res.marks, false, nil
+		}
+		// Wrong credentials or a changed page must not hide behind stale
+		// marks, those are served only while the diary is unreachable.
+		if !unavailable(res.err) {
+			return marks, false, res.err
+		}
+		err = res.err
+	case <-timer.C:
+	case <-ctx.Done():
+		return marks, false, ctx.Err()
+	}
+
+	if lastMarks, errLast := last(ctx); errLast == nil {
+		return lastMarks, true, nil
+	}
+
+	if err != nil {
+		return marks, false, err
+	}
+
+	select {
+	case res := <-results:
+		return res.marks, false, res.err
+	case <-ctx.Done():
+		return marks, false, ctx.Err()
+	}
+}
+
+func unavailable(err error) bool {
+	return errors.Is(err, service.ErrUnavailable) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// changedSubjects lists subjects whose marks were added, changed or removed.
+// withSkippedSubjects adds the subjects of the last marks a partial parse
+// skipped, so that they are neither lost from the last known marks nor
+// reported as removed.
+func withSkippedSubjects[V any](last, current map[string]V) map[string]V {
+	marks := make(map[string]V, len(last))
+	maps.Copy(marks, current)
+	for subject, lastMarks := range last {
+		if _, ok := marks[subject]; !ok {
+			marks[subject] = lastMarks
+		}
+	}
+	return marks
+}
+
+func changedSubjects[V any](last, current map[string]V, equal func(a, b V) bool) []string {
+	var subjects []string
+
+	for subject, marks := range current {
+		if lastMarks, ok := last[subject]; !ok || !equal(lastMarks, marks) {
+			subjects = append(subjects, subject)
+		}
+	}
+	for subject := range last {
+		if _, ok := current[subject]; !ok {
+			subjects = append(subjects, subject)
+		}
+	}
+
+	slices.Sort(subjects)
+
+	return subjects
 }
@@ -0,0 +1,181 @@
+package quota
+
+import (
+	"Elschool-API/internal/config"
+	"Elschool-API/internal/domain/models"
+	"Elschool-API/internal/infra/metrics"
+	"Elschool-API/internal/infra/storage"
+	"Elschool-API/internal/service"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+const (
+	marksWindow = time.Minute
+	// counterSlack keeps the counter a little past its window, so clocks of
+	// replicas being slightly apart don't reset it early.
+	counterSlack = 5 * time.Second
+)
+
+type QuotaStorage interface {
+	ResolveUserToken(ctx context.Context, userToken string) (userID string, err error)
+	CountUserStudents(ctx context.Context, userID string) (count int, err error)
+	ListUserStudentUsers(ctx context.Context, userID string) (students []models.StudentUsers, err error)
+}
+
+type CounterCache interface {
+	IncrCounter(ctx context.Context, key string, ttl time.Duration) (value int64, err error)
+	GetCounter(ctx context.Context, key string) (value int64, err error)
+}
+
+// QuotaService enforces per-user quotas. Student quotas are checked against
+// storage, marks requests are counted in the cache in fixed one minute
+// windows shared by all replicas.
+type QuotaService struct {
+	log     *slog.Logger
+	metrics *metrics.Metrics
+	storage QuotaStorage
+	cache   CounterCache
+	limits  models.QuotaLimits
+}
+
+func New(log *slog.Logger, quotaStorage QuotaStorage, counterCache CounterCache, metricsInfra *metrics.Metrics, cfg config.QuotasConfig) *QuotaService {
+	return &QuotaService{
+		log:     log,
+		metrics: metricsInfra,
+		storage: quotaStorage,
+		cache:   counterCache,
+		limits: models.QuotaLimits{
+			StudentsPerUser: cfg.StudentsPerUser,
+			UsersPerStudent: cfg.UsersPerStudent,
+			MarksPerMinute:  cfg.MarksPerMinute,
+		},
+	}
+}
+
+// AllowLink checks that the user may get one more student, which already has
+// studentUsers users. It expects the transaction adding the relation in the
+// context, so the count is consistent with it.
+func (q *QuotaService) AllowLink(ctx context.Context, userID, studID string, studentUsers int) (err error) {
+	const op = "service.quota.AllowLink"
+
+	if limit := q.limits.UsersPerStudent; limit > 0 && studentUsers >= limit {
+		return fmt.Errorf("%s: %w", op, q.exceeded(models.QuotaUsersPerStudent, userID, "student:"+studID, limit, 0))
+	}
+
+	limit := q.limits.StudentsPerUser
+	if limit <= 0 {
+		return nil
+	}
+
+	count, err := q.storage.CountUserStudents(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			q.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceQuota, metrics.ActionRead, metrics.StatusOk).Inc()
+			return fmt.Errorf("%s: %w", op, service.ErrUserNotFound)
+		}
+
+		q.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceQuota, metrics.ActionRead, metrics.StatusErr).Inc()
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	q.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceQuota, metrics.ActionRead, metrics.StatusOk).Inc()
+
+	if count >= limit {
+		return fmt.Errorf("%s: %w", op, q.exceeded(models.QuotaStudentsPerUser, userID, "user", limit, 0))
+	}
+
+	return nil
+}
+
+// AllowMarksRequest counts the marks request of the user. If the counter is
+// unavailable the request is let through, quotas shouldn't take marks down
+// together with the cache.
+func (q *QuotaService) AllowMarksRequest(ctx context.Context, userID string) (err error) {
+	const op = "service.quota.AllowMarksRequest"
+
+	limit := q.limits.MarksPerMinute
+	if limit <= 0 {
+		return nil
+	}
+
+	start, end := marksWindowOf(time.Now())
+
+	count, err := q.cache.IncrCounter(ctx, marksKey(userID, start), end.Sub(start)+counterSlack)
+	if err != nil {
+		q.log.Warn("failed to count marks request", slog.String("op", op), slog.String("user", userID), "error", err)
+		q.metrics.CacheModifyTotal.WithLabelValues(metrics.ServiceQuota, metrics.ActionWrite, metrics.StatusErr).Inc()
+		return nil
+	}
+	q.metrics.CacheModifyTotal.WithLabelValues(metrics.ServiceQuota, metrics.ActionWrite, metrics.StatusOk).Inc()
+
+	if count > int64(limit) {
+		return fmt.Errorf("%s: %w", op, q.exceeded(models.QuotaMarksPerMinute, userID, "user", limit, time.Until(end)))
+	}
+
+	return nil
+}
+
+// GetQuota returns the limits of the user and how much of them is used.
+func (q *QuotaService) GetQuota(ctx context.Context, userToken string) (usage models.QuotaUsage, err error) {
+	const op = "service.quota.GetQuota"
+
+	log := q.log.With(slog.String("op", op))
+
+	userID, err := q.storage.ResolveUserToken(ctx, userToken)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Error("no such user token", "error", err)
+			q.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceQuota, metrics.ActionRead, metrics.StatusOk).Inc()
+			return models.QuotaUsage{}, fmt.Errorf("%s: %w", op, service.ErrUserNotFound)
+		}
+
+		log.Error("failed to resolve user token", "error", err)
+		q.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceQuota, metrics.ActionRead, metrics.StatusErr).Inc()
+		return models.QuotaUsage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	students, err := q.storage.ListUserStudentUsers(ctx, userID)
+	if err != nil {
+		log.Error("failed to list students of user", slog.String("user", userID), "error", err)
+		q.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceQuota, metrics.ActionRead, metrics.StatusErr).Inc()
+		return models.QuotaUsage{}, fmt.Errorf("%s: %w", op, err)
+	}
+	q.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceQuota, metrics.ActionRead, metrics.StatusOk).Inc()
+
+	start, end := marksWindowOf(time.Now())
+
+	requests, err := q.cache.GetCounter(ctx, marksKey(userID, start))
+	if err != nil {
+		log.Error("failed to read marks requests counter", slog.String("user", userID), "error", err)
+		return models.QuotaUsage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return models.QuotaUsage{
+		Limits:         q.limits,
+		Students:       len(students),
+		MarksRequests:  int(requests),
+		MarksResetAt:   end,
+		LinkedStudents: students,
+	}, nil
+}
+
+// exceeded reports the violation. The subject is what the client knows it by,
+// the user id stays in the logs.
+func (q *QuotaService) exceeded(quota, userID, subject string, limit int, retryAfter time.Duration) *service.QuotaError {
+	q.log.Warn("quota exceeded", slog.String("quota", quota), slog.String("user", userID), slog.String("subject", subject), slog.Int("limit", limit))
+	q.metrics.QuotaExceeded.WithLabelValues(quota).Inc()
+
+	return &service.QuotaError{Quota: quota, Subject: subject, Limit: limit, RetryAfter: retryAfter}
+}
+
+func marksWindowOf(now time.Time) (start, end time.Time) {
+	start = now.UTC().Truncate(marksWindow)
+	return start, start.Add(marksWindow)
+}
+
+func marksKey(userID string, start time.Time) string {
+	return fmt.Sprintf("quota:marks:%s:%d", userID, start.Unix())
+}
@@ -1,8 +1,38 @@
 package service
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"time"
+)
 
 var (
 	ErrStudentNotFound = errors.New("student not found")
 	ErrUserNotFound    = errors.New("user not found")
+	ErrUnavailable     = errors.New("elschool is unavailable")
+	ErrAPIKeyNotFound  = errors.New("api key not found")
+	ErrInvalidScope    = errors.New("invalid api key scope")
+	ErrQuotaExceeded   = errors.New("quota exceeded")
+	ErrUnknownProvider = errors.New("unknown diary provider")
+	ErrUnknownInstance = errors.New("unknown diary instance")
+	ErrNotSupported    = errors.New("not supported by the diary provider")
+	ErrMarkupChanged   = errors.New("diary markup changed")
+	ErrTicketNotFound  = errors.New("stream ticket not found")
 )
+
+// QuotaError tells which quota the request would exceed and for whom. It
+// matches ErrQuotaExceeded. RetryAfter is set when waiting helps.
+type QuotaError struct {
+	Quota      string
+	Subject    string
+	Limit      int
+	RetryAfter time.Duration
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("%s: %s of %s is %d", ErrQuotaExceeded, e.Quota, e.Subject, e.Limit)
+}
+
+func (e *QuotaError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
@@ -1,6 +1,9 @@
 package student
 
 import (
+	"Elschool-API/internal/domain/models"
+	"Elschool-API/internal/infra/cache"
+	"Elschool-API/internal/infra/diary"
 	"Elschool-API/internal/infra/metrics"
 	"Elschool-API/internal/infra/storage"
 	"Elschool-API/internal/service"
@@ -9,6 +12,7 @@ import (
 	"fmt"
 	"github.com/google/uuid"
 	"log/slog"
+	"slices"
 	"time"
 )
 
@@ -22,9 +26,11 @@ type TransactionManager interface {
 }
 
 type StudentStorage interface {
-	CreateStudent(ctx context.Context, studentToken, login, password string) (err error)
+	CreateStudent(ctx context.Context, studentToken, provider, instance, login, password string) (err error)
 	DeleteStudent(ctx context.Context, studentToken string) (err error)
-	HasStudentWithCredentials(ctx context.Context, login, password string) (studentToken string, err error)
+	FindStudentByLogin(ctx context.Context, provider, instance, login string) (student models.Student, err error)
+	FindStudentProvider(ctx context.Context, studentToken string) (provider, instance string, err error)
+	UpdateStudentCredentials(ctx context.Context, studentToken, login, password string) (err error)
 }
 
 type UserStudentsStorage interface {
@@ -33,35 +39,77 @@ type UserStudentsStorage interface {
 	GetStudentRelations(ctx context.Context, studentToken string) (users []string, err error)
 }
 
-type StudentAuthChecker interface {
-	AuthStudent(ctx context.Context, login, password string) (jwt string, err error)
+type UserTokenStorage interface {
+	ResolveUserToken(ctx context.Context, userToken string) (userID string, err error)
+}
+
+type AuditStorage interface {
+	SaveAuditEntry(ctx context.Context, entry models.AuditEntry) (err error)
+}
+
+type Quotas interface {
+	AllowLink(ctx context.Context, userID, studentToken string, studentUsers int) (err error)
+}
+
+type StudentCache interface {
+	DeleteToken(ctx context.Context, studentToken string) (err error)
+	InvalidateStudent(ctx context.Context, studentToken string) (err error)
+}
+
+type DiaryProviders interface {
+	Provider(name string) (provider diary.Provider, err error)
+	Providers() []string
 }
 
 type StudentService struct {
-	log             *slog.Logger
-	metrics         *metrics.Metrics
-	studStorage     StudentStorage
-	usrStudStorage  UserStudentsStorage
-	studAuthChecker StudentAuthChecker
-	txManager       TransactionManager
+	log            *slog.Logger
+	metrics        *metrics.Metrics
+	studStorage    StudentStorage
+	usrStudStorage UserStudentsStorage
+	usrTokStorage  UserTokenStorage
+	studCache      StudentCache
+	providers      DiaryProviders
+	txManager      TransactionManager
+	auditStorage   AuditStorage
+	quotas         Quotas
 }
 
-func New(log *slog.Logger, studStorage StudentStorage, usrStudStorage UserStudentsStorage, studAuthChecker StudentAuthChecker, txManager TransactionManager, metricsInfra *metrics.Metrics) *StudentService {
-	return &StudentService{log: log, studStorage: studStorage, usrStudStorage: usrStudStorage, studAuthChecker: studAuthChecker, txManager: txManager, metrics: metricsInfra}
+func New(log *slog.Logger, studStorage StudentStorage, usrStudStorage UserStudentsStorage, usrTokStorage UserTokenStorage, studCache StudentCache, providers DiaryProviders, txManager TransactionManager, auditStorage AuditStorage, quotas Quotas, metricsInfra *metrics.Metrics) *StudentService {
+	return &StudentService{log: log, studStorage: studStorage, usrStudStorage: usrStudStorage, usrTokStorage: usrTokStorage, studCache: studCache, providers: providers, txManager: txManager, auditStorage: auditStorage, quotas: quotas, metrics: metricsInfra}
 }
 
-func (s *StudentService) AddStudent(ctx context.Context, userID, login, password string) (studID string, err error) {
-	student, err := s.addStudent(ctx, userID, login, password, nil)
+// AddStudent binds the student to the instance of the diary provider. Empty
+// names stand for the default provider and its default instance.
+func (s *StudentService) AddStudent(ctx context.Context, userToken, provider, instance, login, password string) (studID string, err error) {
+	if provider == "" {
+		provider = models.DefaultProvider
+	}
+
+	userID, err := s.resolveUser(ctx, userToken)
+	if err == nil {
+		studID, err = s.addStudent(ctx, userID, provider, instance, login, password)
+		if err != nil {
+			s.auditFailure(ctx, auditEntry(ctx, models.AuditAddStudent, userID, ""), err)
+		}
+	}
+
 	if err != nil {
 		s.metrics.StudentActions.WithLabelValues(metrics.ActionAdd, metrics.StatusErr).Inc()
 	} else {
 		s.metrics.StudentActions.WithLabelValues(metrics.ActionAdd, metrics.StatusOk).Inc()
 	}
-	return student, err
+	return studID, err
 }
 
-func (s *StudentService) DeleteStudent(ctx context.Context, userID, studID string) (err error) {
-	err = s.deleteStudent(ctx, userID, studID, nil)
+func (s *StudentService) DeleteStudent(ctx context.Context, userToken, studID string) (err error) {
+	userID, err := s.resolveUser(ctx, userToken)
+	if err == nil {
+		err = s.deleteStudent(ctx, userID, studID, nil)
+		if err != nil {
+			s.auditFailure(ctx, auditEntry(ctx, models.AuditDeleteStudent, userID, studID), err)
+		}
+	}
+
 	if err != nil {
 		s.metrics.StudentActions.WithLabelValues(metrics.ActionDelete, metrics.StatusErr).Inc()
 	} else {
@@ -70,12 +118,58 @@ func (s *StudentService) DeleteStudent(ctx context.Context, userID, studID strin
 	return err
 }
 
-func (s *StudentService) UpdateStudent(ctx context.Context, userID, studID, login, password string) (newStudID string, err error) {
+// UpdateStudent changes credentials of the student in place, so the student
+// token stays the same. If the login belongs to another known student of the
+// same instance, the user is moved to that student and its token is returned
+// instead.
+func (s *StudentService) UpdateStudent(ctx context.Context, userToken, studID, login, password string) (newStudID string, err error) {
 	const op = "services.student.UpdateStudent"
 
+	userID, err := s.resolveUser(ctx, userToken)
+	if err != nil {
+		s.metrics.StudentActions.WithLabelValues(metrics.ActionUpdate, metrics.StatusErr).Inc()
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
 	log := s.log.With(slog.String("op", op), slog.String("user", userID), slog.String("student", studID))
 	log.Info("updating student")
 
+	audit := auditEntry(ctx, models.AuditUpdateStudent, userID, studID)
+
+	// Deferred before the transaction is, so it runs after the rollback.
+	defer func(ctx context.Context) {
+		if err != nil {
+			s.auditFailure(ctx, audit, err)
+		}
+	}(ctx)
+
+	providerName, instance, err := s.studStorage.FindStudentProvider(ctx, studID)
+	if err != nil {
+		if errors.Is(err, storage.ErrStudentNotFound) {
+			log.Error("no such student", "error", err)
+			s.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceStudent, metrics.ActionRead, metrics.StatusOk).Inc()
+			s.metrics.StudentActions.WithLabelValues(metrics.ActionUpdate, metrics.StatusErr).Inc()
+			return "", fmt.Errorf("%s: %w", op, service.ErrStudentNotFound)
+		}
+
+		log.Error("failed to find student provider in storage", "error", err)
+		s.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceStudent, metrics.ActionRead, metrics.StatusErr).Inc()
+		s.metrics.StudentActions.WithLabelValues(metrics.ActionUpdate, metrics.StatusErr).Inc()
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	s.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceStudent, metrics.ActionRead, metrics.StatusOk).Inc()
+
+	provider, instance, err := s.provider(log, providerName, instance)
+	if err != nil {
+		s.metrics.StudentActions.WithLabelValues(metrics.ActionUpdate, metrics.StatusErr).Inc()
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = s.authStudent(ctx, log, provider, instance, login, password); err != nil {
+		s.metrics.StudentActions.WithLabelValues(metrics.ActionUpdate, metrics.StatusErr).Inc()
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
 	tx, err := s.txManager.StartTransaction(ctx)
 	if err != nil {
 		log.Error("failed to start transaction", "error", err)
@@ -86,7 +180,7 @@ func (s *StudentService) UpdateStudent(ctx context.Context, userID, studID, logi
 
 	defer func() {
 		if err == nil {
-			tx.Commit()
+			err = tx.Commit()
 		} else {
 			tx.Rollback()
 		}
@@ -96,17 +190,17 @@ func (s *StudentService) UpdateStudent(ctx context.Context, userID, studID, logi
 	if err != nil {
 		if errors.Is(err, storage.ErrStudentNotFound) {
 			log.Error("no such student", "error", err)
-			s.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceStudent, metrics.ActionRead, metrics.StatusOk)
+			s.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceStudent, metrics.ActionRead, metrics.StatusOk).Inc()
 			s.metrics.StudentActions.WithLabelValues(metrics.ActionUpdate, metrics.StatusErr).Inc()
 			return "", fmt.Errorf("%s: %w", op, service.ErrStudentNotFound)
 		}
 
 		log.Error("failed to find student relations in storage", "error", err)
-		s.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceStudent, metrics.ActionRead, metrics.StatusErr)
+		s.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceStudent, metrics.ActionRead, metrics.StatusErr).Inc()
 		s.metrics.StudentActions.WithLabelValues(metrics.ActionUpdate, metrics.StatusErr).Inc()
 		return "", fmt.Errorf("%s: %w", op, err)
 	}
-	s.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceStudent, metrics.ActionRead, metrics.StatusOk)
+	s.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceStudent, metrics.ActionRead, metrics.StatusOk).Inc()
 
 	if !s.contains(relations, userID) {
 		log.Error("no relations with that student", "error", err)
@@ -114,71 +208,178 @@ func (s *StudentService) UpdateStudent(ctx context.Context, userID, studID, logi
 		return "", fmt.Errorf("%s: %w", op, service.ErrUserNotFound)
 	}
 
-	newStudID, err = s.addStudent(ctx, userID, login, password, tx)
-	if err != nil {
-		log.Error("failed to add student in storage", "error", err)
-		s.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceStudent, metrics.ActionWrite, metrics.StatusErr)
+	existing, err := s.studStorage.FindStudentByLogin(ctx, providerName, instance, login)
+	if err != nil && !errors.Is(err, storage.ErrStudentNotFound) {
+		log.Error("failed to find student in storage", "error", err)
+		s.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceStudent, metrics.ActionRead, metrics.StatusErr).Inc()
 		s.metrics.StudentActions.WithLabelValues(metrics.ActionUpdate, metrics.StatusErr).Inc()
 		return "", fmt.Errorf("%s: %w", op, err)
 	}
-	log.Info("student added in storage during update")
-	s.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceStudent, metrics.ActionWrite, metrics.StatusOk)
+	s.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceStudent, metrics.ActionRead, metrics.StatusOk).Inc()
+
+	loginChanged := errors.Is(err, storage.ErrStudentNotFound)
+
+	// Rewriting the login of a shared student would move the other users to
+	// another Elschool account, so only the caller moves to a new student.
+	move := false
+	switch {
+	case err == nil && existing.Token != studID:
+		log.Info("login belongs to another student", slog.String("existing", existing.Token))
+		move = true
+	case loginChanged && len(relations) > 1:
+		log.Info("login of a shared student changed, moving the user to a new student", slog.Int("relations", len(relations)))
+		move = true
+	}
 
-	if newStudID != studID {
+	if move {
+		// The old relation goes first, so that the move doesn't count against
+		// the students per user quota.
 		err = s.deleteStudent(ctx, userID, studID, tx)
 		if err != nil {
 			log.Error("failed to delete old student in storage", "error", err)
-			s.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceStudent, metrics.ActionDelete, metrics.StatusOk)
+			s.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceStudent, metrics.ActionDelete, metrics.StatusErr).Inc()
 			s.metrics.StudentActions.WithLabelValues(metrics.ActionUpdate, metrics.StatusErr).Inc()
 			return "", fmt.Errorf("%s: %w", op, err)
 		}
 		log.Info("relation to old student deleted")
-		s.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceStudent, metrics.ActionDelete, metrics.StatusOk)
+		s.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceStudent, metrics.ActionDelete, metrics.StatusOk).Inc()
+
+		newStudID, err = s.attachStudent(ctx, log, userID, providerName, instance, login, password)
+		if err != nil {
+			s.metrics.StudentActions.WithLabelValues(metrics.ActionUpdate, metrics.StatusErr).Inc()
+			return "", fmt.Errorf("%s: %w", op, err)
+		}
+
+		audit.Target += ",student:" + newStudID
+		if err = s.audit(ctx, log, audit); err != nil {
+			s.metrics.StudentActions.WithLabelValues(metrics.ActionUpdate, metrics.StatusErr).Inc()
+			return "", fmt.Errorf("%s: %w", op, err)
+		}
+		s.metrics.StudentActions.WithLabelValues(metrics.ActionUpdate, metrics.StatusOk).Inc()
+
+		return newStudID, nil
+	}
+
+	err = s.studStorage.UpdateStudentCredentials(ctx, studID, login, password)
+	if err != nil {
+		log.Error("failed to update student credentials in storage", "error", err)
+		s.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceStudent, metrics.ActionWrite, metrics.StatusErr).Inc()
+		s.metrics.StudentActions.WithLabelValues(metrics.ActionUpdate, metrics.StatusErr).Inc()
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	s.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceStudent, metrics.ActionWrite, metrics.StatusOk).Inc()
+
+	if err = s.audit(ctx, log, audit); err != nil {
+		s.metrics.StudentActions.WithLabelValues(metrics.ActionUpdate, metrics.StatusErr).Inc()
+		return "", fmt.Errorf("%s: %w", op, err)
 	}
+
+	s.invalidateStudent(ctx, log, studID, loginChanged)
+
 	log.Info("student updated in storage")
 	s.metrics.StudentActions.WithLabelValues(metrics.ActionUpdate, metrics.StatusOk).Inc()
 
-	return newStudID, nil
+	return studID, nil
 }
 
-func (s *StudentService) addStudent(ctx context.Context, userID, login, password string, tx Transaction) (studID string, err error) {
+func (s *StudentService) addStudent(ctx context.Context, userID, providerName, instance, login, password string) (studID string, err error) {
 	const op = "services.student.addStudent"
 
-	log := s.log.With(slog.String("op", op), slog.String("user", userID))
+	log := s.log.With(slog.String("op", op), slog.String("user", userID), slog.String("provider", providerName))
 	log.Info("adding student")
 
+	provider, instance, err := s.provider(log, providerName, instance)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = s.authStudent(ctx, log, provider, instance, login, password); err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	tx, err := s.txManager.StartTransaction(ctx)
+	if err != nil {
+		log.Error("failed to start transaction", "error", err)
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	ctx = context.WithValue(ctx, "tx", tx)
+
+	defer func() {
+		if err == nil {
+			err = tx.Commit()
+		} else {
+			tx.Rollback()
+		}
+	}()
+
+	studID, err = s.attachStudent(ctx, log, userID, providerName, instance, login, password)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = s.audit(ctx, log, auditEntry(ctx, models.AuditAddStudent, userID, studID)); err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return studID, nil
+}
+
+// provider finds the diary provider by name and checks it has the instance.
+// An empty instance is the default instance of the provider.
+func (s *StudentService) provider(log *slog.Logger, name, instance string) (provider diary.Provider, providerInstance string, err error) {
+	const op = "services.student.provider"
+
+	provider, err = s.providers.Provider(name)
+	if err != nil {
+		log.Error("unknown provider", "error", err)
+		return nil, "", fmt.Errorf("%s: %w", op, service.ErrUnknownProvider)
+	}
+
+	instances := provider.Instances()
+	if instance == "" {
+		return provider, instances[0], nil
+	}
+	if !slices.Contains(instances, instance) {
+		log.Error("unknown instance", slog.String("instance", instance))
+		return nil, "", fmt.Errorf("%s: %w", op, service.ErrUnknownInstance)
+	}
+
+	return provider, instance, nil
+}
+
+// authStudent makes sure the credentials are accepted by the diary provider
+// before they get into storage.
+func (s *StudentService) authStudent(ctx context.Context, log *slog.Logger, provider diary.Provider, instance, login, password string) (err error) {
+	const op = "services.student.authStudent"
+
 	start := time.Now()
-	_, err = s.studAuthChecker.AuthStudent(ctx, login, password)
+	_, err = provider.Authenticate(ctx, instance, login, password)
 	s.metrics.ElschoolAuthDuration.WithLabelValues(metrics.MethodCheck).Observe(time.Since(start).Seconds())
 
 	if err != nil {
 		log.Error("failed to check student credential", "error", err)
 		s.metrics.ElschoolAuthTotal.WithLabelValues(metrics.MethodAuth, metrics.StatusErr).Inc()
-		return "", fmt.Errorf("%s: %w", op, err)
+		if errors.Is(err, diary.ErrUnavailable) {
+			return fmt.Errorf("%s: %w", op, service.ErrUnavailable)
+		}
+		return fmt.Errorf("%s: %w", op, err)
 	}
 	s.metrics.ElschoolAuthTotal.WithLabelValues(metrics.MethodAuth, metrics.StatusOk).Inc()
 
-	if tx == nil {
-		if tx, err = s.txManager.StartTransaction(ctx); err != nil {
-			log.Error("failed to start transaction", "error", err)
-			return "", fmt.Errorf("%s: %w", op, err)
-		}
-
-		ctx = context.WithValue(ctx, "tx", tx)
+	return nil
+}
 
-		defer func() {
-			if err == nil {
-				tx.Commit()
-			} else {
-				tx.Rollback()
-			}
-		}()
-	}
+// attachStudent relates the user to the student with that login on the
+// provider instance, creating the student if needed, within the quotas. It
+// expects a transaction in the context.
+func (s *StudentService) attachStudent(ctx context.Context, log *slog.Logger, userID, provider, instance, login, password string) (studID string, err error) {
+	const op = "services.student.attachStudent"
 
-	studID, err = s.studStorage.HasStudentWithCredentials(ctx, login, password)
+	student, err := s.studStorage.FindStudentByLogin(ctx, provider, instance, login)
 
 	if errors.Is(err, storage.ErrStudentNotFound) {
-		s.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceStudent, metrics.ActionRead, metrics.StatusOk)
+		s.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceStudent, metrics.ActionRead, metrics.StatusOk).Inc()
 		uuid4, genErr := uuid.NewRandom()
 
 		if genErr != nil {
@@ -188,7 +389,12 @@ func (s *StudentService) addStudent(ctx context.Context, userID, login, password
 		}
 		studID = uuid4.String()
 
-		err = s.studStorage.CreateStudent(ctx, studID, login, password)
+		if err = s.quotas.AllowLink(ctx, userID, studID, 0); err != nil {
+			log.Error("student not allowed", "error", err)
+			return "", fmt.Errorf("%s: %w", op, err)
+		}
+
+		err = s.studStorage.CreateStudent(ctx, studID, provider, instance, login, password)
 		if err != nil {
 			if errors.Is(err, storage.ErrStudentExists) {
 				s.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceStudent, metrics.ActionWrite, metrics.StatusOk).Inc()
@@ -225,7 +431,23 @@ func (s *StudentService) addStudent(ctx context.Context, userID, login, password
 	}
 	s.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceStudent, metrics.ActionRead, metrics.StatusOk).Inc()
 
-	log.Info("student already exists")
+	studID = student.Token
+	log.Info("student already exists", slog.String("student", studID))
+
+	if student.Password != password {
+		// The credentials have just been accepted by Elschool, so the stored
+		// password is outdated.
+		err = s.studStorage.UpdateStudentCredentials(ctx, studID, login, password)
+		if err != nil {
+			log.Error("failed to update student credentials in storage", "error", err)
+			s.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceStudent, metrics.ActionWrite, metrics.StatusErr).Inc()
+			return "", fmt.Errorf("%s: %w", op, err)
+		}
+		s.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceStudent, metrics.ActionWrite, metrics.StatusOk).Inc()
+
+		s.invalidateStudent(ctx, log, studID, false)
+		log.Info("student password updated")
+	}
 
 	relations, err := s.usrStudStorage.GetStudentRelations(ctx, studID)
 	if err != nil {
@@ -238,6 +460,11 @@ func (s *StudentService) addStudent(ctx context.Context, userID, login, password
 	if !s.contains(relations, userID) {
 		log.Info("no relations with that student")
 
+		if err = s.quotas.AllowLink(ctx, userID, studID, len(relations)); err != nil {
+			log.Error("student not allowed", "error", err)
+			return "", fmt.Errorf("%s: %w", op, err)
+		}
+
 		err = s.usrStudStorage.AddRelation(ctx, userID, studID)
 		if err != nil {
 			if errors.Is(err, storage.ErrUserNotFound) {
@@ -258,13 +485,67 @@ func (s *StudentService) addStudent(ctx context.Context, userID, login, password
 	return studID, nil
 }
 
+// ListInstances returns the instances of the diary provider, the default one
+// first. An empty name stands for the default provider.
+func (s *StudentService) ListInstances(name string) (instances []string, err error) {
+	const op = "services.student.ListInstances"
+
+	if name == "" {
+		name = models.DefaultProvider
+	}
+
+	provider, err := s.providers.Provider(name)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, service.ErrUnknownProvider)
+	}
+
+	return provider.Instances(), nil
+}
+
+// ListProviders describes the diary providers students can be bound to, the
+// default one first.
+func (s *StudentService) ListProviders() (providers []models.DiaryProvider) {
+	for _, name := range s.providers.Providers() {
+		provider, err := s.providers.Provider(name)
+		if err != nil {
+			continue
+		}
+
+		providers = append(providers, models.DiaryProvider{
+			Name:         name,
+			Instances:    provider.Instances(),
+			Capabilities: provider.Capabilities(),
+		})
+	}
+
+	return providers
+}
+
+// invalidateStudent drops the cached Elschool token after a credentials
+// change. Marks are dropped too if the student now is another account.
+func (s *StudentService) invalidateStudent(ctx context.Context, log *slog.Logger, studID string, loginChanged bool) {
+	var err error
+	if loginChanged {
+		err = s.studCache.InvalidateStudent(ctx, studID)
+	} else {
+		err = s.studCache.DeleteToken(ctx, studID)
+	}
+
+	if err != nil && !errors.Is(err, cache.ErrTokenNotFound) {
+		log.Warn("failed to invalidate student cache", "error", err)
+	}
+}
+
 func (s *StudentService) deleteStudent(ctx context.Context, userID, studID string, tx Transaction) (err error) {
 	const op = "services.student.deleteStudent"
 
 	log := s.log.With(slog.String("op", op), slog.String("user", userID), slog.String("student", studID))
 	log.Info("deleting student")
 
-	if tx == nil {
+	// A caller passing its transaction records the audit entry itself.
+	ownTx := tx == nil
+
+	if ownTx {
 		if tx, err = s.txManager.StartTransaction(ctx); err != nil {
 			log.Error("failed to start transaction", "error", err)
 			return fmt.Errorf("%s: %w", op, err)
@@ -274,7 +555,7 @@ func (s *StudentService) deleteStudent(ctx context.Context, userID, studID strin
 
 		defer func() {
 			if err == nil {
-				tx.Commit()
+				err = tx.Commit()
 			} else {
 				tx.Rollback()
 			}
@@ -325,11 +606,79 @@ func (s *StudentService) deleteStudent(ctx context.Context, userID, studID strin
 		s.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceStudent, metrics.ActionDelete, metrics.StatusOk).Inc()
 	}
 
+	if ownTx {
+		if err = s.audit(ctx, log, auditEntry(ctx, models.AuditDeleteStudent, userID, studID)); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
 	log.Info("student deleted")
 
 	return nil
 }
 
+// resolveUser maps the user token to the id relations are stored with.
+func (s *StudentService) resolveUser(ctx context.Context, userToken string) (userID string, err error) {
+	const op = "services.student.resolveUser"
+
+	userID, err = s.usrTokStorage.ResolveUserToken(ctx, userToken)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			s.log.Error("no such user token", slog.String("op", op), "error", err)
+			s.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceStudent, metrics.ActionRead, metrics.StatusOk).Inc()
+			return "", fmt.Errorf("%s: %w", op, service.ErrUserNotFound)
+		}
+
+		s.log.Error("failed to resolve user token", slog.String("op", op), "error", err)
+		s.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceStudent, metrics.ActionRead, metrics.StatusErr).Inc()
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	s.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceStudent, metrics.ActionRead, metrics.StatusOk).Inc()
+
+	return userID, nil
+}
+
+// audit records the change within the transaction in the context, so the
+// entry is stored only together with the change.
+func (s *StudentService) audit(ctx context.Context, log *slog.Logger, entry models.AuditEntry) error {
+	if err := s.auditStorage.SaveAuditEntry(ctx, entry); err != nil {
+		log.Error("failed to record audit entry", "error", err)
+		return err
+	}
+	return nil
+}
+
+// auditFailure records the failed action once its transaction is rolled
+// back. Requests with unknown user tokens aren't recorded, there is no user
+// to attribute them to.
+func (s *StudentService) auditFailure(ctx context.Context, entry models.AuditEntry, cause error) {
+	entry.Status = service.AuditStatus(cause)
+
+	if err := s.auditStorage.SaveAuditEntry(context.WithoutCancel(ctx), entry); err != nil {
+		s.log.Warn("failed to record audit entry", slog.String("action", entry.Action), slog.String("user", entry.UserID), "error", err)
+	}
+}
+
+func auditEntry(ctx context.Context, action, userID, studID string) models.AuditEntry {
+	client := service.ClientFromContext(ctx)
+
+	entry := models.AuditEntry{
+		Actor:     "user:" + userID,
+		Action:    action,
+		UserID:    userID,
+		StudentID: studID,
+		Service:   client.Service,
+		Status:    models.AuditStatusOk,
+		RequestID: client.RequestID,
+		CreatedAt: time.Now().UTC(),
+	}
+	if studID != "" {
+		entry.Target = "student:" + studID
+	}
+
+	return entry
+}
+
 func (s *StudentService) contains(slice []string, str string) bool {
 	for _, item := range slice {
 		if item == str {
@@ -1,52 +1,283 @@
 package user
 
 import (
+	"Elschool-API/internal/config"
+	"Elschool-API/internal/domain/models"
+	"Elschool-API/internal/infra/cache"
 	"Elschool-API/internal/infra/metrics"
+	"Elschool-API/internal/infra/storage"
+	"Elschool-API/internal/service"
 	"context"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
 	"log/slog"
+	"time"
 )
 
 type UserService struct {
 	log        *slog.Logger
 	metrics    *metrics.Metrics
 	usrStorage UserStorage
+	studCache  StudentCache
+
+	tokenTTL      time.Duration
+	rotationGrace time.Duration
 }
 
 type UserStorage interface {
-	CreateUser(ctx context.Context, token, service string) (err error)
+	CreateUser(ctx context.Context, userID, service, token string, expiresAt time.Time, audit models.AuditEntry) (err error)
+	RotateUserToken(ctx context.Context, oldToken, newToken string, expiresAt, graceUntil time.Time) (oldExpiresAt time.Time, err error)
+	RevokeUserToken(ctx context.Context, token string) (err error)
+	ResolveUserToken(ctx context.Context, token string) (userID string, err error)
+	DeleteUser(ctx context.Context, userID string) (deleted models.UserDeletion, err error)
+	ExportUser(ctx context.Context, userID string) (export models.UserExport, err error)
+	SaveAuditEntry(ctx context.Context, entry models.AuditEntry) (err error)
+}
+
+type StudentCache interface {
+	InvalidateStudent(ctx context.Context, studentToken string) (err error)
+	LastMarks(ctx context.Context, studentToken string) (marks models.LastMarks, err error)
 }
 
-func New(log *slog.Logger, usrStorage UserStorage, metricsInfra *metrics.Metrics) *UserService {
-	return &UserService{log: log, usrStorage: usrStorage, metrics: metricsInfra}
+func New(log *slog.Logger, usrStorage UserStorage, studCache StudentCache, metricsInfra *metrics.Metrics, cfg config.UsersConfig) *UserService {
+	return &UserService{log: log, usrStorage: usrStorage, studCache: studCache, metrics: metricsInfra, tokenTTL: cfg.TokenTTL, rotationGrace: cfg.RotationGrace}
 }
 
-func (u *UserService) CreateUser(ctx context.Context, service string) (token string, err error) {
+// CreateUser registers a user and issues its first token. A zero ttl means
+// the configured default, which may be no expiry at all.
+func (u *UserService) CreateUser(ctx context.Context, service string, ttl time.Duration) (token string, expiresAt time.Time, err error) {
 	const op = "service.user.CreateUser"
 
 	log := u.log.With(slog.String("op", op))
 	log.Info("creating user")
 
-	uuid4, err := uuid.NewRandom()
+	userID, err := uuid.NewRandom()
+	if err != nil {
+		log.Error("failed to gen uuid", "error", err)
+		return "", time.Time{}, fmt.Errorf("%s: %w", op, err)
+	}
 
+	tokenID, err := uuid.NewRandom()
 	if err != nil {
 		log.Error("failed to gen uuid", "error", err)
-		return "", fmt.Errorf("%s: %w", op, err)
+		return "", time.Time{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	token = uuid4.String()
-	err = u.usrStorage.CreateUser(ctx, token, service)
+	token = tokenID.String()
+	expiresAt = u.expiresAt(ttl)
+
+	audit := regUserAudit(ctx, userID.String(), service)
+
+	err = u.usrStorage.CreateUser(ctx, userID.String(), service, token, expiresAt, audit)
 
 	if err != nil {
 		log.Error("failed to save user", "error", err)
+
+		audit.Status = models.AuditStatusFailed
+		if err := u.usrStorage.SaveAuditEntry(context.WithoutCancel(ctx), audit); err != nil {
+			log.Warn("failed to record audit entry", "error", err)
+		}
+
 		u.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceUser, metrics.ActionWrite, metrics.StatusErr).Inc()
 		u.metrics.UserRegistrations.WithLabelValues(service, metrics.StatusErr).Inc()
-		return "", fmt.Errorf("%s: %w", op, err)
+		return "", time.Time{}, fmt.Errorf("%s: %w", op, err)
 	}
 	u.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceUser, metrics.ActionWrite, metrics.StatusOk).Inc()
 	u.metrics.UserRegistrations.WithLabelValues(service, metrics.StatusOk).Inc()
-	log.Info("user created")
+	log.Info("user created", slog.String("user", userID.String()))
+
+	return token, expiresAt, nil
+}
+
+// RotateToken issues a new token for the user. The old one keeps working for
+// the rotation grace period, so clients can switch without downtime.
+func (u *UserService) RotateToken(ctx context.Context, token string, ttl time.Duration) (newToken string, expiresAt, oldExpiresAt time.Time, err error) {
+	const op = "service.user.RotateToken"
+
+	log := u.log.With(slog.String("op", op))
+	log.Info("rotating user token")
+
+	tokenID, err := uuid.NewRandom()
+	if err != nil {
+		log.Error("failed to gen uuid", "error", err)
+		return "", time.Time{}, time.Time{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	newToken = tokenID.String()
+	expiresAt = u.expiresAt(ttl)
+
+	oldExpiresAt, err = u.usrStorage.RotateUserToken(ctx, token, newToken, expiresAt, time.Now().UTC().Add(u.rotationGrace))
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Error("no such user token", "error", err)
+			u.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceUser, metrics.ActionUpdate, metrics.StatusOk).Inc()
+			u.metrics.UserTokenActions.WithLabelValues(metrics.ActionRotate, metrics.StatusErr).Inc()
+			return "", time.Time{}, time.Time{}, fmt.Errorf("%s: %w", op, service.ErrUserNotFound)
+		}
+
+		log.Error("failed to rotate user token", "error", err)
+		u.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceUser, metrics.ActionUpdate, metrics.StatusErr).Inc()
+		u.metrics.UserTokenActions.WithLabelValues(metrics.ActionRotate, metrics.StatusErr).Inc()
+		return "", time.Time{}, time.Time{}, fmt.Errorf("%s: %w", op, err)
+	}
+	u.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceUser, metrics.ActionUpdate, metrics.StatusOk).Inc()
+	u.metrics.UserTokenActions.WithLabelValues(metrics.ActionRotate, metrics.StatusOk).Inc()
+	log.Info("user token rotated")
+
+	return newToken, expiresAt, oldExpiresAt, nil
+}
+
+func (u *UserService) RevokeToken(ctx context.Context, token string) (err error) {
+	const op = "service.user.RevokeToken"
+
+	log := u.log.With(slog.String("op", op))
+	log.Info("revoking user token")
+
+	err = u.usrStorage.RevokeUserToken(ctx, token)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Error("no such user token", "error", err)
+			u.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceUser, metrics.ActionUpdate, metrics.StatusOk).Inc()
+			u.metrics.UserTokenActions.WithLabelValues(metrics.ActionRevoke, metrics.StatusErr).Inc()
+			return fmt.Errorf("%s: %w", op, service.ErrUserNotFound)
+		}
+
+		log.Error("failed to revoke user token", "error", err)
+		u.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceUser, metrics.ActionUpdate, metrics.StatusErr).Inc()
+		u.metrics.UserTokenActions.WithLabelValues(metrics.ActionRevoke, metrics.StatusErr).Inc()
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	u.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceUser, metrics.ActionUpdate, metrics.StatusOk).Inc()
+	u.metrics.UserTokenActions.WithLabelValues(metrics.ActionRevoke, metrics.StatusOk).Inc()
+	log.Info("user token revoked")
+
+	return nil
+}
 
-	return token, nil
+// DeleteUser deletes the user with everything only it had, so credentials of
+// its students aren't kept anymore. Failing to drop cached data doesn't fail
+// the deletion, it just isn't counted in the result.
+func (u *UserService) DeleteUser(ctx context.Context, token string) (deleted models.UserDeletion, err error) {
+	const op = "service.user.DeleteUser"
+
+	log := u.log.With(slog.String("op", op))
+	log.Info("deleting user")
+
+	userID, err := u.usrStorage.ResolveUserToken(ctx, token)
+	if err == nil {
+		deleted, err = u.usrStorage.DeleteUser(ctx, userID)
+	}
+
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Error("no such user token", "error", err)
+			u.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceUser, metrics.ActionDelete, metrics.StatusOk).Inc()
+			u.metrics.UserDeletions.WithLabelValues(metrics.StatusErr).Inc()
+			return models.UserDeletion{}, fmt.Errorf("%s: %w", op, service.ErrUserNotFound)
+		}
+
+		log.Error("failed to delete user", "error", err)
+		u.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceUser, metrics.ActionDelete, metrics.StatusErr).Inc()
+		u.metrics.UserDeletions.WithLabelValues(metrics.StatusErr).Inc()
+		return models.UserDeletion{}, fmt.Errorf("%s: %w", op, err)
+	}
+	u.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceUser, metrics.ActionDelete, metrics.StatusOk).Inc()
+
+	for _, studID := range deleted.Students {
+		err := u.studCache.InvalidateStudent(ctx, studID)
+		if err != nil && !errors.Is(err, cache.ErrTokenNotFound) {
+			log.Warn("failed to invalidate student cache", slog.String("student", studID), "error", err)
+			continue
+		}
+		deleted.CachesInvalidated++
+	}
+
+	u.metrics.UserDeletions.WithLabelValues(metrics.StatusOk).Inc()
+	log.Info("user deleted",
+		slog.String("user", userID),
+		slog.Int("tokens", deleted.Tokens),
+		slog.Int("relations", deleted.Relations),
+		slog.Int("students", len(deleted.Students)),
+	)
+
+	return deleted, nil
+}
+
+// ExportUserData collects everything stored about the user of the token,
+// including the last known marks of the linked students.
+func (u *UserService) ExportUserData(ctx context.Context, token string) (export models.UserExport, err error) {
+	const op = "service.user.ExportUserData"
+
+	log := u.log.With(slog.String("op", op))
+	log.Info("exporting user data")
+
+	userID, err := u.usrStorage.ResolveUserToken(ctx, token)
+	if err == nil {
+		export, err = u.usrStorage.ExportUser(ctx, userID)
+	}
+
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Error("no such user token", "error", err)
+			u.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceUser, metrics.ActionRead, metrics.StatusOk).Inc()
+			u.metrics.UserExports.WithLabelValues(metrics.StatusErr).Inc()
+			return models.UserExport{}, fmt.Errorf("%s: %w", op, service.ErrUserNotFound)
+		}
+
+		log.Error("failed to export user data", "error", err)
+		u.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceUser, metrics.ActionRead, metrics.StatusErr).Inc()
+		u.metrics.UserExports.WithLabelValues(metrics.StatusErr).Inc()
+		return models.UserExport{}, fmt.Errorf("%s: %w", op, err)
+	}
+	u.metrics.StorageRequestsTotal.WithLabelValues(metrics.ServiceUser, metrics.ActionRead, metrics.StatusOk).Inc()
+
+	for i, student := range export.Students {
+		export.Students[i].LastMarks, err = u.studCache.LastMarks(ctx, student.ID)
+		if err != nil {
+			log.Error("failed to read last known marks", slog.String("student", student.ID), "error", err)
+			u.metrics.UserExports.WithLabelValues(metrics.StatusErr).Inc()
+			return models.UserExport{}, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	u.metrics.UserExports.WithLabelValues(metrics.StatusOk).Inc()
+	log.Info("user data exported",
+		slog.String("user", userID),
+		slog.Int("tokens", len(export.Tokens)),
+		slog.Int("students", len(export.Students)),
+		slog.Int("audit_entries", len(export.Audit)),
+	)
+
+	return export, nil
+}
+
+// regUserAudit describes the registration. Unless the request came with an api
+// key, the client service is the one the user is registered for.
+func regUserAudit(ctx context.Context, userID, userService string) models.AuditEntry {
+	client := service.ClientFromContext(ctx)
+	if client.Service == "" {
+		client.Service = userService
+	}
+
+	return models.AuditEntry{
+		Actor:     "user:" + userID,
+		Action:    models.AuditRegUser,
+		Target:    "user:" + userID,
+		UserID:    userID,
+		Service:   client.Service,
+		Status:    models.AuditStatusOk,
+		RequestID: client.RequestID,
+		CreatedAt: time.Now().UTC(),
+	}
+}
+
+func (u *UserService) expiresAt(ttl time.Duration) time.Time {
+	if ttl == 0 {
+		ttl = u.tokenTTL
+	}
+	if ttl == 0 {
+		return time.Time{}
+	}
+	return time.Now().UTC().Add(ttl)
 }
@@ -0,0 +1,34 @@
+-- +goose Up
+-- Existing rows are encrypted by the server on start, which clears their
+-- plaintext columns, the keyring is needed for that.
+-- +goose StatementBegin
+ALTER TABLE students
+    ALTER COLUMN login DROP NOT NULL,
+    ALTER COLUMN password DROP NOT NULL,
+    DROP CONSTRAINT unique_login_password,
+    ADD COLUMN login_hash BYTEA,
+    ADD COLUMN login_enc BYTEA,
+    ADD COLUMN password_enc BYTEA,
+    ADD COLUMN data_key BYTEA,
+    ADD COLUMN key_id TEXT;
+
+CREATE INDEX students_login_hash_idx ON students (login_hash);
+CREATE INDEX students_key_id_idx ON students (key_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- Rows have to be decrypted first, otherwise restoring NOT NULL fails.
+-- +goose StatementBegin
+DROP INDEX IF EXISTS students_key_id_idx;
+DROP INDEX IF EXISTS students_login_hash_idx;
+
+ALTER TABLE students
+    DROP COLUMN key_id,
+    DROP COLUMN data_key,
+    DROP COLUMN password_enc,
+    DROP COLUMN login_enc,
+    DROP COLUMN login_hash,
+    ALTER COLUMN login SET NOT NULL,
+    ALTER COLUMN password SET NOT NULL,
+    ADD CONSTRAINT unique_login_password UNIQUE (login, password);
+-- +goose StatementEnd
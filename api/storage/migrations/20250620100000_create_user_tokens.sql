@@ -0,0 +1,22 @@
+-- +goose Up
+-- Tokens issued before are equal to user ids, so they are carried over as is.
+-- +goose StatementBegin
+CREATE TABLE user_tokens (
+    token_hash BYTEA PRIMARY KEY,
+    user_id UUID NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP,
+    revoked_at TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX user_tokens_user_id_idx ON user_tokens (user_id);
+
+INSERT INTO user_tokens (token_hash, user_id, created_at)
+SELECT sha256(convert_to(id::text, 'UTF8')), id, created_at FROM users;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS user_tokens;
+-- +goose StatementEnd
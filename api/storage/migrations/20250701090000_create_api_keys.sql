@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE api_keys (
+    id UUID PRIMARY KEY,
+    service TEXT NOT NULL,
+    key_hash BYTEA NOT NULL UNIQUE,
+    scopes TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    revoked_at TIMESTAMP,
+    CONSTRAINT api_keys_service_len CHECK (char_length(service) <= 100)
+);
+
+CREATE INDEX api_keys_service_idx ON api_keys (service);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS api_keys;
+-- +goose StatementEnd
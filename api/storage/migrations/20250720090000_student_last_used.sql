@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE students ADD COLUMN last_used_at TIMESTAMP;
+UPDATE students SET last_used_at = NOW();
+
+CREATE INDEX students_last_used_at_idx ON students (last_used_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS students_last_used_at_idx;
+ALTER TABLE students DROP COLUMN last_used_at;
+-- +goose StatementEnd
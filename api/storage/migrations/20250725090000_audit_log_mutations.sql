@@ -0,0 +1,33 @@
+-- +goose Up
+-- Entries recorded before have the affected user and student in the target only.
+-- +goose StatementBegin
+ALTER TABLE audit_log ADD COLUMN user_id UUID;
+ALTER TABLE audit_log ADD COLUMN student_id UUID;
+ALTER TABLE audit_log ADD COLUMN service TEXT NOT NULL DEFAULT '';
+
+UPDATE audit_log SET user_id = substring(target from 'user:([0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12})')::uuid;
+UPDATE audit_log SET student_id = substring(target from 'student:([0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12})')::uuid;
+
+CREATE INDEX audit_log_user_id_idx ON audit_log (user_id, id);
+CREATE INDEX audit_log_student_id_idx ON audit_log (student_id, id);
+
+CREATE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_append_only BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS audit_log_append_only ON audit_log;
+DROP FUNCTION IF EXISTS audit_log_append_only();
+DROP INDEX IF EXISTS audit_log_student_id_idx;
+DROP INDEX IF EXISTS audit_log_user_id_idx;
+ALTER TABLE audit_log DROP COLUMN service;
+ALTER TABLE audit_log DROP COLUMN student_id;
+ALTER TABLE audit_log DROP COLUMN user_id;
+-- +goose StatementEnd
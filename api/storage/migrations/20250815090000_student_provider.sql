@@ -0,0 +1,20 @@
+-- +goose Up
+-- Students are bound to a diary provider now, every one before is an Elschool
+-- student. Logins are unique per instance of a provider only.
+-- +goose StatementBegin
+ALTER TABLE students ADD COLUMN provider TEXT NOT NULL DEFAULT 'elschool';
+
+DROP INDEX IF EXISTS students_instance_login_hash_key;
+DROP INDEX IF EXISTS students_instance_login_key;
+CREATE UNIQUE INDEX students_provider_login_hash_key ON students (provider, instance, login_hash);
+CREATE UNIQUE INDEX students_provider_login_key ON students (provider, instance, login);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS students_provider_login_key;
+DROP INDEX IF EXISTS students_provider_login_hash_key;
+CREATE UNIQUE INDEX students_instance_login_hash_key ON students (instance, login_hash);
+CREATE UNIQUE INDEX students_instance_login_key ON students (instance, login);
+ALTER TABLE students DROP COLUMN provider;
+-- +goose StatementEnd
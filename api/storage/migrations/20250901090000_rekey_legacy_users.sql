@@ -0,0 +1,39 @@
+-- +goose Up
+-- Users registered before tokens were introduced have their token as the id,
+-- so the secret is stored in plain wherever the user is referenced. They get
+-- fresh ids, the token keeps working by its hash only.
+-- +goose StatementBegin
+CREATE TEMPORARY TABLE user_rekeys AS
+SELECT u.id AS old_id, gen_random_uuid() AS new_id
+FROM users u
+JOIN user_tokens t ON t.user_id = u.id AND t.token_hash = sha256(convert_to(u.id::text, 'UTF8'));
+
+INSERT INTO users (id, service, created_at, disabled_at, disabled_reason)
+SELECT r.new_id, u.service, u.created_at, u.disabled_at, u.disabled_reason
+FROM users u
+JOIN user_rekeys r ON r.old_id = u.id;
+
+UPDATE user_students SET user_id = r.new_id FROM user_rekeys r WHERE user_students.user_id = r.old_id;
+UPDATE user_tokens SET user_id = r.new_id FROM user_rekeys r WHERE user_tokens.user_id = r.old_id;
+
+-- Entries name the user in the actor and the target too, also those without
+-- a user_id, each column names a single user.
+ALTER TABLE audit_log DISABLE TRIGGER audit_log_append_only;
+UPDATE audit_log SET user_id = r.new_id
+FROM user_rekeys r
+WHERE audit_log.user_id = r.old_id;
+UPDATE audit_log SET actor = replace(actor, r.old_id::text, r.new_id::text)
+FROM user_rekeys r
+WHERE strpos(audit_log.actor, r.old_id::text) > 0;
+UPDATE audit_log SET target = replace(target, r.old_id::text, r.new_id::text)
+FROM user_rekeys r
+WHERE strpos(audit_log.target, r.old_id::text) > 0;
+ALTER TABLE audit_log ENABLE TRIGGER audit_log_append_only;
+
+DELETE FROM users WHERE id IN (SELECT old_id FROM user_rekeys);
+
+DROP TABLE user_rekeys;
+-- +goose StatementEnd
+
+-- +goose Down
+-- Old ids are tokens, they aren't kept.
@@ -0,0 +1,57 @@
+package tests
+
+import (
+	"Elschool-API/tests/suite"
+	apiv1 "github.com/Ilya-Repin/elschooler/protos/gen/api/api.v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestAdminAuditLog(t *testing.T) {
+	ctx, st := suite.New(t)
+	adminCtx, ok := st.AdminContext(ctx)
+	if !ok {
+		t.Skip("admin service is disabled")
+	}
+
+	userResp, err := st.UserClient.RegUser(ctx, &apiv1.RegUserRequest{Service: "testsuite_audit"})
+	require.NoError(t, err)
+	token := userResp.GetUserToken()
+
+	_, err = st.StudentClient.AddStudent(ctx, &apiv1.AddStudentRequest{UserToken: token, Login: existedStudentLogin, Password: existedStudentPassword})
+	require.NoError(t, err)
+
+	_, err = st.StudentClient.DeleteStudent(ctx, &apiv1.DeleteStudentRequest{UserToken: token, StudentToken: existedStudentId})
+	require.NoError(t, err)
+
+	getResp, err := st.AdminClient.GetUser(adminCtx, &apiv1.GetUserRequest{UserToken: token})
+	require.NoError(t, err)
+	userID := getResp.GetUser().GetId()
+
+	var actions []string
+	var pageToken string
+	for {
+		resp, err := st.AdminClient.ListAuditLog(adminCtx, &apiv1.ListAuditLogRequest{UserId: userID, PageSize: 1, PageToken: pageToken})
+		require.NoError(t, err)
+
+		for _, entry := range resp.GetEntries() {
+			if entry.GetActor() != "user:"+userID {
+				continue
+			}
+			assert.Equal(t, "ok", entry.GetStatus())
+			assert.NotEmpty(t, entry.GetRequestId())
+			actions = append(actions, entry.GetAction())
+		}
+
+		if pageToken = resp.GetNextPageToken(); pageToken == "" {
+			break
+		}
+	}
+	assert.Equal(t, []string{"delete_student", "add_student", "reg_user"}, actions)
+
+	studentResp, err := st.AdminClient.ListAuditLog(adminCtx, &apiv1.ListAuditLogRequest{UserId: userID, StudentId: existedStudentId})
+	require.NoError(t, err)
+	require.Len(t, studentResp.GetEntries(), 2)
+	assert.Equal(t, existedStudentId, studentResp.GetEntries()[0].GetStudentId())
+}
@@ -0,0 +1,90 @@
+package tests
+
+import (
+	"Elschool-API/tests/suite"
+	apiv1 "github.com/Ilya-Repin/elschooler/protos/gen/api/api.v1"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+func TestAdminAuth(t *testing.T) {
+	ctx, st := suite.New(t)
+	if _, ok := st.AdminContext(ctx); !ok {
+		t.Skip("admin service is disabled")
+	}
+
+	_, err := st.AdminClient.GetStats(ctx, &apiv1.GetStatsRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestAdminDisableUser(t *testing.T) {
+	ctx, st := suite.New(t)
+	adminCtx, ok := st.AdminContext(ctx)
+	if !ok {
+		t.Skip("admin service is disabled")
+	}
+
+	userResp, err := st.UserClient.RegUser(ctx, &apiv1.RegUserRequest{Service: "testsuite_admin"})
+	require.NoError(t, err)
+
+	getResp, err := st.AdminClient.GetUser(adminCtx, &apiv1.GetUserRequest{UserToken: userResp.GetUserToken()})
+	require.NoError(t, err)
+	assert.Equal(t, "testsuite_admin", getResp.GetUser().GetService())
+	assert.Equal(t, int32(1), getResp.GetUser().GetActiveTokens())
+	userID := getResp.GetUser().GetId()
+
+	disableResp, err := st.AdminClient.DisableUser(adminCtx, &apiv1.DisableUserRequest{UserId: userID, Reason: "abuse"})
+	require.NoError(t, err)
+	assert.NotNil(t, disableResp.GetUser().GetDisabledAt())
+	assert.Equal(t, "abuse", disableResp.GetUser().GetDisabledReason())
+
+	_, err = st.StudentClient.DeleteStudent(ctx, &apiv1.DeleteStudentRequest{UserToken: userResp.GetUserToken(), StudentToken: uuid.NewString()})
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "no such user")
+
+	_, err = st.UserClient.RotateUserToken(ctx, &apiv1.RotateUserTokenRequest{UserToken: userResp.GetUserToken()})
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "no such user")
+
+	enableResp, err := st.AdminClient.EnableUser(adminCtx, &apiv1.EnableUserRequest{UserId: userID})
+	require.NoError(t, err)
+	assert.Nil(t, enableResp.GetUser().GetDisabledAt())
+
+	_, err = st.StudentClient.DeleteStudent(ctx, &apiv1.DeleteStudentRequest{UserToken: userResp.GetUserToken(), StudentToken: uuid.NewString()})
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "no such student")
+}
+
+func TestAdminLookups(t *testing.T) {
+	ctx, st := suite.New(t)
+	adminCtx, ok := st.AdminContext(ctx)
+	if !ok {
+		t.Skip("admin service is disabled")
+	}
+
+	studentID := "70d0ed1a-25e1-40f7-877d-9fb9ce28969f"
+
+	studentResp, err := st.AdminClient.GetStudent(adminCtx, &apiv1.GetStudentRequest{StudentId: studentID})
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, studentResp.GetStudent().GetUsers(), int32(1))
+
+	relationsResp, err := st.AdminClient.ListRelations(adminCtx, &apiv1.ListRelationsRequest{StudentId: studentID, PageSize: 1})
+	require.NoError(t, err)
+	require.Len(t, relationsResp.GetRelations(), 1)
+	assert.Equal(t, studentID, relationsResp.GetRelations()[0].GetStudentId())
+
+	_, err = st.AdminClient.GetStudent(adminCtx, &apiv1.GetStudentRequest{StudentId: uuid.NewString()})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = st.AdminClient.InvalidateStudentCache(adminCtx, &apiv1.InvalidateStudentCacheRequest{StudentId: studentID})
+	require.NoError(t, err)
+
+	statsResp, err := st.AdminClient.GetStats(adminCtx, &apiv1.GetStatsRequest{Days: 7})
+	require.NoError(t, err)
+	assert.Len(t, statsResp.GetGrowth(), 7)
+	assert.GreaterOrEqual(t, statsResp.GetRelations(), int64(1))
+}
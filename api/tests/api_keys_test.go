@@ -0,0 +1,54 @@
+package tests
+
+import (
+	"Elschool-API/internal/domain/models"
+	"Elschool-API/internal/grpc/interceptors"
+	"Elschool-API/tests/suite"
+	apiv1 "github.com/Ilya-Repin/elschooler/protos/gen/api/api.v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+func TestApiKeyScopes(t *testing.T) {
+	ctx, st := suite.New(t)
+	if !st.Cfg.APIKeysConfig.Enabled {
+		t.Skip("api keys are disabled")
+	}
+
+	service := "testsuite_keys"
+
+	createResp, err := st.ApiKeysClient.CreateApiKey(ctx, &apiv1.CreateApiKeyRequest{Service: service, Scopes: []string{models.ScopeUsersCreate}})
+	require.NoError(t, err)
+	assert.NotEmpty(t, createResp.GetApiKey())
+
+	keyCtx := metadata.AppendToOutgoingContext(ctx, interceptors.APIKeyHeader, createResp.GetApiKey())
+
+	_, err = st.UserClient.RegUser(keyCtx, &apiv1.RegUserRequest{Service: service})
+	require.NoError(t, err)
+
+	_, err = st.UserClient.RegUser(keyCtx, &apiv1.RegUserRequest{Service: "another_service"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = st.MarksClient.GetFinalMarks(keyCtx, &apiv1.FinalMarksRequest{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = st.StudentClient.ListProviders(keyCtx, &apiv1.ListProvidersRequest{})
+	require.NoError(t, err)
+
+	_, err = st.StudentClient.ListInstances(keyCtx, &apiv1.ListInstancesRequest{})
+	require.NoError(t, err)
+
+	listResp, err := st.ApiKeysClient.ListApiKeys(ctx, &apiv1.ListApiKeysRequest{Service: service})
+	require.NoError(t, err)
+	assert.NotEmpty(t, listResp.GetKeys())
+
+	_, err = st.ApiKeysClient.RevokeApiKey(ctx, &apiv1.RevokeApiKeyRequest{Id: createResp.GetKey().GetId()})
+	require.NoError(t, err)
+
+	_, err = st.UserClient.RegUser(keyCtx, &apiv1.RegUserRequest{Service: service})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
@@ -0,0 +1,125 @@
+package tests
+
+import (
+	gatewayapp "Elschool-API/internal/app/gateway"
+	"Elschool-API/internal/grpc/interceptors"
+	"Elschool-API/tests/suite"
+	"encoding/json"
+	"fmt"
+	apiv1 "github.com/Ilya-Repin/elschooler/protos/gen/api/api.v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestGatewayRegUser(t *testing.T) {
+	_, st := suite.New(t)
+	if !st.Cfg.GatewayConfig.Enabled {
+		t.Skip("gateway is disabled")
+	}
+
+	resp := gatewayRequest(t, st, http.MethodPost, "/v1/users", `{"service":"testsuite_gateway"}`)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.NotEmpty(t, resp.Header.Get(interceptors.RequestIDHeader))
+
+	var body struct {
+		UserToken string `json:"userToken"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.NotEmpty(t, body.UserToken)
+}
+
+func TestGatewayStatusMapping(t *testing.T) {
+	_, st := suite.New(t)
+	if !st.Cfg.GatewayConfig.Enabled {
+		t.Skip("gateway is disabled")
+	}
+
+	resp := gatewayUserRequest(t, st, http.MethodGet, "/v1/students/not-a-token/marks/final", "not-a-token")
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestGatewayUserTokenHeader(t *testing.T) {
+	ctx, st := suite.New(t)
+	if !st.Cfg.GatewayConfig.Enabled {
+		t.Skip("gateway is disabled")
+	}
+
+	userResp, err := st.UserClient.RegUser(ctx, &apiv1.RegUserRequest{Service: "testsuite_gateway"})
+	require.NoError(t, err)
+
+	studentResp, err := st.StudentClient.AddStudent(ctx, &apiv1.AddStudentRequest{UserToken: userResp.GetUserToken(), Login: existedStudentLogin, Password: existedStudentPassword})
+	require.NoError(t, err)
+
+	path := fmt.Sprintf("/v1/students/%s/marks/final", studentResp.GetStudentToken())
+
+	resp := gatewayUserRequest(t, st, http.MethodGet, path, userResp.GetUserToken())
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = gatewayRequest(t, st, http.MethodGet, path+"?userToken="+userResp.GetUserToken(), "")
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp = gatewayUserRequest(t, st, http.MethodDelete, fmt.Sprintf("/v1/students/%s", studentResp.GetStudentToken()), userResp.GetUserToken())
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestGatewayOpenAPI(t *testing.T) {
+	_, st := suite.New(t)
+	if !st.Cfg.GatewayConfig.Enabled {
+		t.Skip("gateway is disabled")
+	}
+
+	resp := gatewayRequest(t, st, http.MethodGet, gatewayapp.OpenAPIPath, "")
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var spec struct {
+		Paths map[string]any `json:"paths"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&spec))
+	assert.Contains(t, spec.Paths, "/v1/users")
+}
+
+func gatewayRequest(t *testing.T, st *suite.Suite, method, path, body string) *http.Response {
+	t.Helper()
+
+	return doGatewayRequest(t, st, method, path, body, "")
+}
+
+// gatewayUserRequest sends the user token in the Authorization header, as
+// methods without a body take it.
+func gatewayUserRequest(t *testing.T, st *suite.Suite, method, path, userToken string) *http.Response {
+	t.Helper()
+
+	return doGatewayRequest(t, st, method, path, "", userToken)
+}
+
+func doGatewayRequest(t *testing.T, st *suite.Suite, method, path, body, userToken string) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(method, fmt.Sprintf("http://localhost:%d%s", st.Cfg.GatewayConfig.Port, path), strings.NewReader(body))
+	require.NoError(t, err)
+
+	if userToken != "" {
+		req.Header.Set("Authorization", "Bearer "+userToken)
+	}
+
+	if st.Cfg.APIKeysConfig.Enabled {
+		req.Header.Set(interceptors.APIKeyHeader, st.Cfg.APIKeysConfig.BootstrapKey)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+
+	return resp
+}
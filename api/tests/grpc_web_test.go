@@ -0,0 +1,125 @@
+package tests
+
+import (
+	"Elschool-API/internal/grpc/interceptors"
+	"Elschool-API/tests/suite"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	apiv1 "github.com/Ilya-Repin/elschooler/protos/gen/api/api.v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const (
+	allowedOrigin = "http://localhost:3000"
+	grpcWebType   = "application/grpc-web+proto"
+)
+
+func TestGRPCWebPreflight(t *testing.T) {
+	_, st := suite.New(t)
+	if !st.Cfg.GRPCConfig.Web.Enabled {
+		t.Skip("grpc-web is disabled")
+	}
+
+	tests := []struct {
+		name    string
+		origin  string
+		allowed bool
+	}{
+		{name: "Allowed origin", origin: allowedOrigin, allowed: true},
+		{name: "Unknown origin", origin: "http://evil.example", allowed: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := grpcWebRequest(t, st, http.MethodOptions, apiv1.User_RegUser_FullMethodName, nil)
+			req.Header.Set("Origin", tt.origin)
+			req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			req.Header.Set("Access-Control-Request-Headers", "content-type,x-grpc-web")
+
+			resp, err := (&http.Client{Transport: &http.Transport{}}).Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			if tt.allowed {
+				assert.Equal(t, tt.origin, resp.Header.Get("Access-Control-Allow-Origin"))
+			} else {
+				assert.Empty(t, resp.Header.Get("Access-Control-Allow-Origin"))
+			}
+		})
+	}
+}
+
+func TestGRPCWebRegUser(t *testing.T) {
+	_, st := suite.New(t)
+	if !st.Cfg.GRPCConfig.Web.Enabled {
+		t.Skip("grpc-web is disabled")
+	}
+
+	msg, err := proto.Marshal(&apiv1.RegUserRequest{Service: "testsuite_grpc_web"})
+	require.NoError(t, err)
+
+	req := grpcWebRequest(t, st, http.MethodPost, apiv1.User_RegUser_FullMethodName, frame(0, msg))
+	req.Header.Set("Origin", allowedOrigin)
+
+	resp, err := (&http.Client{Transport: &http.Transport{}}).Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, allowedOrigin, resp.Header.Get("Access-Control-Allow-Origin"))
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	var (
+		reply    apiv1.RegUserResponse
+		trailers string
+	)
+	for len(body) >= 5 {
+		flag, size := body[0], binary.BigEndian.Uint32(body[1:5])
+		require.GreaterOrEqual(t, uint32(len(body)-5), size)
+
+		payload := body[5 : 5+size]
+		if flag&0x80 != 0 {
+			trailers = string(payload)
+		} else {
+			require.NoError(t, proto.Unmarshal(payload, &reply))
+		}
+		body = body[5+size:]
+	}
+
+	assert.NotEmpty(t, reply.GetUserToken())
+	assert.Contains(t, strings.ToLower(trailers), "grpc-status: 0")
+}
+
+func grpcWebRequest(t *testing.T, st *suite.Suite, method, rpc string, body []byte) *http.Request {
+	t.Helper()
+
+	req, err := http.NewRequest(method, fmt.Sprintf("http://localhost:%d%s", st.Cfg.GRPCConfig.Web.Port, rpc), bytes.NewReader(body))
+	require.NoError(t, err)
+
+	req.Header.Set("Content-Type", grpcWebType)
+	req.Header.Set("X-Grpc-Web", "1")
+
+	if st.Cfg.APIKeysConfig.Enabled {
+		req.Header.Set(interceptors.APIKeyHeader, st.Cfg.APIKeysConfig.BootstrapKey)
+	}
+
+	return req
+}
+
+// frame wraps a message into a gRPC-Web length-prefixed frame.
+func frame(flag byte, msg []byte) []byte {
+	buf := make([]byte, 5, 5+len(msg))
+	buf[0] = flag
+	binary.BigEndian.PutUint32(buf[1:], uint32(len(msg)))
+
+	return append(buf, msg...)
+}
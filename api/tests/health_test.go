@@ -0,0 +1,27 @@
+package tests
+
+import (
+	"Elschool-API/tests/suite"
+	apiv1 "github.com/Ilya-Repin/elschooler/protos/gen/api/api.v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	healthv1 "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+func TestHealthCheck(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	resp, err := st.HealthClient.Check(ctx, &healthv1.HealthCheckRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, healthv1.HealthCheckResponse_SERVING, resp.GetStatus())
+
+	resp, err = st.HealthClient.Check(ctx, &healthv1.HealthCheckRequest{Service: apiv1.User_ServiceDesc.ServiceName})
+	require.NoError(t, err)
+	assert.Equal(t, healthv1.HealthCheckResponse_SERVING, resp.GetStatus())
+
+	_, err = st.HealthClient.Check(ctx, &healthv1.HealthCheckRequest{Service: "unknown.Service"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
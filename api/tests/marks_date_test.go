@@ -0,0 +1,75 @@
+package tests
+
+import (
+	"Elschool-API/tests/suite"
+	apiv1 "github.com/Ilya-Repin/elschooler/protos/gen/api/api.v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gdate "google.golang.org/genproto/googleapis/type/date"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+const (
+	isoDate = "2023-04-26"
+)
+
+func TestGetDayMarksISODate(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	marksResp, err := st.MarksClient.GetDayMarks(ctx, &apiv1.DayMarksRequest{UserToken: marksUserId, StudentToken: marksStudentId, Date: isoDate})
+
+	require.NoError(t, err)
+	assert.Equal(t, int32(dayWorstMark), marksResp.GetWorstMark())
+	assert.Equal(t, []int32{4, 4, 4, 4, 5, 5, 5}, marksResp.GetMarks()[language].GetMarks())
+}
+
+func TestGetDayMarksProtoDate(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	marksResp, err := st.MarksClient.GetDayMarks(ctx, &apiv1.DayMarksRequest{UserToken: marksUserId, StudentToken: marksStudentId, Day: &gdate.Date{Year: 2023, Month: 4, Day: 26}})
+
+	require.NoError(t, err)
+	assert.Equal(t, int32(dayWorstMark), marksResp.GetWorstMark())
+	assert.Equal(t, []int32{4, 4, 4, 4, 5, 5, 5}, marksResp.GetMarks()[language].GetMarks())
+}
+
+func TestGetDayMarksRelativeDate(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	for _, keyword := range []string{"today", "yesterday", "Today"} {
+		_, err := st.MarksClient.GetDayMarks(ctx, &apiv1.DayMarksRequest{UserToken: marksUserId, StudentToken: marksStudentId, Date: keyword})
+		require.NoError(t, err, keyword)
+	}
+}
+
+func TestGetDayMarksInvalidDate(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	tests := []struct {
+		name    string
+		date    string
+		day     *gdate.Date
+		message string
+	}{
+		{name: "Empty", message: "date required"},
+		{name: "Garbage", date: "tomorrowish", message: `wrong date "tomorrowish", use YYYY-MM-DD, DD.MM.YYYY, "today" or "yesterday"`},
+		{name: "No such day", date: "2023-02-30", message: `wrong date "2023-02-30", use YYYY-MM-DD, DD.MM.YYYY, "today" or "yesterday"`},
+		{name: "Partial proto date", day: &gdate.Date{Year: 2023, Month: 4}, message: "day must have year, month and day set"},
+		{name: "Invalid proto date", day: &gdate.Date{Year: 2023, Month: 2, Day: 30}, message: "wrong day 2023-02-30, no such date"},
+		{name: "Both", date: isoDate, day: &gdate.Date{Year: 2023, Month: 4, Day: 26}, message: "either date or day is allowed, not both"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := st.MarksClient.GetDayMarks(ctx, &apiv1.DayMarksRequest{UserToken: marksUserId, StudentToken: marksStudentId, Date: tt.date, Day: tt.day})
+			require.Error(t, err)
+
+			errStatus, ok := status.FromError(err)
+			require.True(t, ok)
+			assert.Equal(t, codes.InvalidArgument, errStatus.Code())
+			assert.Equal(t, tt.message, errStatus.Message())
+		})
+	}
+}
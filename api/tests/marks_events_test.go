@@ -0,0 +1,228 @@
+package tests
+
+import (
+	"Elschool-API/internal/fakeelschool"
+	"Elschool-API/tests/suite"
+	"bufio"
+	"context"
+	"fmt"
+	apiv1 "github.com/Ilya-Repin/elschooler/protos/gen/api/api.v1"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	eventsLogin = "eventsStudent"
+)
+
+func TestMarksEventsStream(t *testing.T) {
+	ctx, st := suite.New(t)
+	if !st.Cfg.GatewayConfig.Enabled {
+		t.Skip("gateway is disabled")
+	}
+
+	newTicket := func() string {
+		resp, err := st.MarksClient.CreateEventsTicket(ctx, &apiv1.EventsTicketRequest{UserToken: marksUserId, StudentToken: marksStudentId})
+		require.NoError(t, err)
+		assert.True(t, resp.GetExpiresAt().AsTime().After(time.Now()))
+		return resp.GetTicket()
+	}
+
+	usedTicket := newTicket()
+	openEventsStream(t, st, marksStudentId, usedTicket, "").Body.Close()
+
+	tests := []struct {
+		name        string
+		ticket      string
+		lastEventID string
+		status      int
+		firstEvent  string
+	}{
+		{name: "Stream opened", ticket: newTicket(), status: http.StatusOK},
+		{name: "Unknown last event", ticket: newTicket(), lastEventID: "unknown-1", status: http.StatusOK, firstEvent: "event: reset"},
+		{name: "Used ticket", ticket: usedTicket, status: http.StatusUnauthorized},
+		{name: "Unknown ticket", ticket: uuid.NewString(), status: http.StatusUnauthorized},
+		{name: "Invalid ticket", ticket: "invalid", status: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := openEventsStream(t, st, marksStudentId, tt.ticket, tt.lastEventID)
+			defer resp.Body.Close()
+
+			require.Equal(t, tt.status, resp.StatusCode)
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+			reader := bufio.NewReader(resp.Body)
+
+			line, err := reader.ReadString('\n')
+			require.NoError(t, err)
+			assert.True(t, strings.HasPrefix(line, "retry: "))
+
+			if tt.firstEvent != "" {
+				_, _ = reader.ReadString('\n')
+				line, err = reader.ReadString('\n')
+				require.NoError(t, err)
+				assert.Equal(t, tt.firstEvent, strings.TrimSpace(line))
+			}
+		})
+	}
+}
+
+func TestMarksEventsResume(t *testing.T) {
+	ctx, st := suite.New(t)
+	if !st.Cfg.GatewayConfig.Enabled {
+		t.Skip("gateway is disabled")
+	}
+
+	userResp, err := st.UserClient.RegUser(ctx, &apiv1.RegUserRequest{Service: "testsuite_events"})
+	require.NoError(t, err)
+
+	studResp, err := st.StudentClient.AddStudent(ctx, &apiv1.AddStudentRequest{UserToken: userResp.GetUserToken(), Login: eventsLogin, Password: "eventsPassword"})
+	require.NoError(t, err)
+
+	userToken, studentToken := userResp.GetUserToken(), studResp.GetStudentToken()
+
+	// Events are the differences from the last known marks, so there have to
+	// be some first.
+	_, err = st.MarksClient.GetFinalMarks(ctx, &apiv1.FinalMarksRequest{UserToken: userToken, StudentToken: studentToken})
+	require.NoError(t, err)
+
+	page, err := os.ReadFile("./html/test_page_result.html")
+	require.NoError(t, err)
+
+	newTicket := func() string {
+		resp, err := st.MarksClient.CreateEventsTicket(ctx, &apiv1.EventsTicketRequest{UserToken: userToken, StudentToken: studentToken})
+		require.NoError(t, err)
+		return resp.GetTicket()
+	}
+
+	resp := openEventsStream(t, st, studentToken, newTicket(), "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	stream := bufio.NewReader(resp.Body)
+
+	fakeElschool.SetPages(eventsLogin, fakeelschool.Pages{Results: withFirstChemistryMark(t, string(page), 5)})
+	waitFinalMark(ctx, t, st, userToken, studentToken, 5)
+
+	first := readEvent(t, stream)
+	assert.Equal(t, "marks", first.name)
+	assert.Contains(t, first.data, `"type":"final"`)
+	assert.Contains(t, first.data, chemistry)
+	resp.Body.Close()
+
+	// Changes made while the client is away are replayed when it is back.
+	fakeElschool.SetPages(eventsLogin, fakeelschool.Pages{Results: withFirstChemistryMark(t, string(page), 2)})
+	waitFinalMark(ctx, t, st, userToken, studentToken, 2)
+
+	resp = openEventsStream(t, st, studentToken, newTicket(), first.id)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	replayed := readEvent(t, bufio.NewReader(resp.Body))
+	assert.Equal(t, "marks", replayed.name)
+	assert.NotEqual(t, first.id, replayed.id)
+	assert.Contains(t, replayed.data, `"`+chemistry+`":[2,`)
+}
+
+func TestMarksEventsTicket(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	_, err := st.MarksClient.CreateEventsTicket(ctx, &apiv1.EventsTicketRequest{UserToken: uuid.NewString(), StudentToken: marksStudentId})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = st.MarksClient.CreateEventsTicket(ctx, &apiv1.EventsTicketRequest{UserToken: marksUserId, StudentToken: uuid.NewString()})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+// openEventsStream opens the stream the way EventSource does, with the
+// ticket in the URL and no other credentials. It is kept open for as long as
+// marks take to expire from the cache twice.
+func openEventsStream(t *testing.T, st *suite.Suite, studentToken, ticket, lastEventID string) *http.Response {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*st.Cfg.CacheConfig.MarksTTL+10*time.Second)
+	t.Cleanup(cancel)
+
+	url := fmt.Sprintf("http://localhost:%d/v1/students/%s/events?ticket=%s", st.Cfg.GatewayConfig.Port, studentToken, ticket)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	require.NoError(t, err)
+
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	resp, err := (&http.Client{Transport: &http.Transport{}}).Do(req)
+	require.NoError(t, err)
+
+	return resp
+}
+
+type sseEvent struct {
+	id   string
+	name string
+	data string
+}
+
+// readEvent reads the stream up to the next event, skipping the retry
+// delay and the heartbeats.
+func readEvent(t *testing.T, stream *bufio.Reader) sseEvent {
+	t.Helper()
+
+	var event sseEvent
+	for {
+		line, err := stream.ReadString('\n')
+		require.NoError(t, err)
+
+		field, value, _ := strings.Cut(strings.TrimSpace(line), ": ")
+		switch field {
+		case "id":
+			event.id = value
+		case "event":
+			event.name = value
+		case "data":
+			event.data = value
+		case "":
+			if event.name != "" {
+				return event
+			}
+		}
+	}
+}
+
+// withFirstChemistryMark changes the first final chemistry mark on the
+// results page.
+func withFirstChemistryMark(t *testing.T, page string, mark int) string {
+	t.Helper()
+
+	const first = "<td>" + chemistry + "</td>\n                <td class=\"results-mark mark3\">\n                    <i>3</i>"
+
+	changed := strings.Replace(page, first, fmt.Sprintf("<td>%s</td>\n                <td class=\"results-mark mark%d\">\n                    <i>%d</i>", chemistry, mark, mark), 1)
+	require.NotEqual(t, page, changed)
+
+	return changed
+}
+
+// waitFinalMark waits for the cached final marks to expire and the changed
+// ones to be fetched.
+func waitFinalMark(ctx context.Context, t *testing.T, st *suite.Suite, userToken, studentToken string, mark int32) {
+	t.Helper()
+
+	require.EventuallyWithT(t, func(c *assert.CollectT) {
+		resp, err := st.MarksClient.GetFinalMarks(ctx, &apiv1.FinalMarksRequest{UserToken: userToken, StudentToken: studentToken})
+		require.NoError(c, err)
+		require.NotEmpty(c, resp.GetMarks()[chemistry].GetMarks())
+		assert.Equal(c, mark, resp.GetMarks()[chemistry].GetMarks()[0])
+	}, st.Cfg.CacheConfig.MarksTTL+5*time.Second, 250*time.Millisecond)
+}
@@ -0,0 +1,39 @@
+package tests
+
+import (
+	"Elschool-API/tests/suite"
+	apiv1 "github.com/Ilya-Repin/elschooler/protos/gen/api/api.v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+const (
+	redesignedLogin = "redesignedStudent"
+)
+
+func TestGetMarksMarkupChanged(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	userResp, err := st.UserClient.RegUser(ctx, &apiv1.RegUserRequest{Service: "testsuite_markup"})
+	require.NoError(t, err)
+
+	studResp, err := st.StudentClient.AddStudent(ctx, &apiv1.AddStudentRequest{UserToken: userResp.GetUserToken(), Login: redesignedLogin, Password: "redesignedPassword"})
+	require.NoError(t, err)
+
+	_, err = st.MarksClient.GetDayMarks(ctx, &apiv1.DayMarksRequest{UserToken: userResp.GetUserToken(), StudentToken: studResp.GetStudentToken(), Date: date})
+	require.Error(t, err)
+
+	errStatus, ok := status.FromError(err)
+	require.True(t, ok)
+	assert.Equal(t, codes.Unavailable, errStatus.Code())
+	assert.Equal(t, "diary pages changed and can't be read, try again later", errStatus.Message())
+
+	_, err = st.MarksClient.GetAverageMarks(ctx, &apiv1.AverageMarksRequest{UserToken: userResp.GetUserToken(), StudentToken: studResp.GetStudentToken(), Period: period})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+
+	_, err = st.MarksClient.GetFinalMarks(ctx, &apiv1.FinalMarksRequest{UserToken: userResp.GetUserToken(), StudentToken: studResp.GetStudentToken()})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
@@ -0,0 +1,59 @@
+package tests
+
+import (
+	"Elschool-API/internal/fakeelschool"
+	"Elschool-API/tests/suite"
+	apiv1 "github.com/Ilya-Repin/elschooler/protos/gen/api/api.v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+	"time"
+)
+
+const (
+	staleLogin = "staleStudent"
+)
+
+func TestGetFinalMarksStale(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	userResp, err := st.UserClient.RegUser(ctx, &apiv1.RegUserRequest{Service: "testsuite_stale"})
+	require.NoError(t, err)
+
+	studResp, err := st.StudentClient.AddStudent(ctx, &apiv1.AddStudentRequest{UserToken: userResp.GetUserToken(), Login: staleLogin, Password: "stalePassword"})
+	require.NoError(t, err)
+
+	req := &apiv1.FinalMarksRequest{UserToken: userResp.GetUserToken(), StudentToken: studResp.GetStudentToken()}
+
+	freshResp, err := st.MarksClient.GetFinalMarks(ctx, req)
+	require.NoError(t, err)
+	require.False(t, freshResp.GetStale())
+	require.NotNil(t, freshResp.GetFetchedAt())
+
+	fakeElschool.StartOutage(staleLogin, fakeelschool.Outage{Status: http.StatusServiceUnavailable})
+	t.Cleanup(func() { fakeElschool.EndOutage(staleLogin) })
+
+	// The fresh marks are served from the cache until they expire, only then
+	// the diary is asked and found down.
+	var staleResp *apiv1.FinalMarksResponse
+	require.EventuallyWithT(t, func(c *assert.CollectT) {
+		resp, err := st.MarksClient.GetFinalMarks(ctx, req)
+		require.NoError(c, err)
+		assert.True(c, resp.GetStale())
+		staleResp = resp
+	}, st.Cfg.CacheConfig.MarksTTL+5*time.Second, 250*time.Millisecond)
+
+	assert.Equal(t, freshResp.GetFetchedAt().AsTime(), staleResp.GetFetchedAt().AsTime())
+	assert.Equal(t, freshResp.GetWorstMark(), staleResp.GetWorstMark())
+	assert.Len(t, staleResp.GetMarks(), len(freshResp.GetMarks()))
+
+	fakeElschool.EndOutage(staleLogin)
+
+	require.EventuallyWithT(t, func(c *assert.CollectT) {
+		resp, err := st.MarksClient.GetFinalMarks(ctx, req)
+		require.NoError(c, err)
+		assert.False(c, resp.GetStale())
+		assert.True(c, resp.GetFetchedAt().AsTime().After(freshResp.GetFetchedAt().AsTime()))
+	}, st.Cfg.CacheConfig.MarksTTL+5*time.Second, 250*time.Millisecond)
+}
@@ -30,6 +30,9 @@ func TestGetDayMarks(t *testing.T) {
 	assert.NotEmpty(t, marksResp.GetMarks())
 	assert.NotEmpty(t, marksResp.GetWorstMark())
 	assert.Equal(t, int32(dayWorstMark), marksResp.GetWorstMark())
+	assert.NotNil(t, marksResp.GetFetchedAt())
+	assert.False(t, marksResp.GetStale())
+	assert.Empty(t, marksResp.GetWarnings())
 	marks := marksResp.GetMarks()
 	assert.Equal(t, 1, len(marks))
 	subjectMarks := marks[language]
@@ -46,6 +49,9 @@ func TestGetAverageMarks(t *testing.T) {
 	assert.NotEmpty(t, marksResp.GetMarks())
 	assert.NotEmpty(t, marksResp.GetWorstMark())
 	assert.Equal(t, averageWorstMark, marksResp.GetWorstMark())
+	assert.NotNil(t, marksResp.GetFetchedAt())
+	assert.False(t, marksResp.GetStale())
+	assert.Empty(t, marksResp.GetWarnings())
 
 	marks := marksResp.GetMarks()
 	assert.Equal(t, 3, len(marks))
@@ -64,6 +70,9 @@ func TestGetFinalMarks(t *testing.T) {
 	assert.NotEmpty(t, marksResp.GetMarks())
 	assert.NotEmpty(t, marksResp.GetWorstMark())
 	assert.Equal(t, int32(finalWorstMark), marksResp.GetWorstMark())
+	assert.NotNil(t, marksResp.GetFetchedAt())
+	assert.False(t, marksResp.GetStale())
+	assert.Empty(t, marksResp.GetWarnings())
 
 	finalMarks := marksResp.GetMarks()
 	assert.Equal(t, 3, len(finalMarks))
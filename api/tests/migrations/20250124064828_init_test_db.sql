@@ -1,30 +1,9 @@
 -- +goose Up
+-- Seed data of the tests. It goes between the first migrations of the storage,
+-- so the users are legacy ones with tokens equal to their ids and the
+-- students have plaintext credentials, and every later migration has to carry
+-- them over.
 -- +goose StatementBegin
-CREATE TABLE users (
-    id UUID PRIMARY KEY,
-    service TEXT NOT NULL,
-    created_at TIMESTAMP NOT NULL DEFAULT NOW()
-    CONSTRAINT users_service_len CHECK (char_length(service) <= 100)
-);
-
-CREATE TABLE students (
-    id UUID PRIMARY KEY,
-    login TEXT NOT NULL,
-    password TEXT NOT NULL,
-    CONSTRAINT students_login_len     CHECK (char_length(login)    <= 100),
-    CONSTRAINT students_password_len  CHECK (char_length(password) <= 100),
-    CONSTRAINT unique_login_password UNIQUE (login, password)
-);
-
-CREATE TABLE user_students (
-    user_id UUID,
-    student_id UUID,
-    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
-    PRIMARY KEY (user_id, student_id),
-    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
-    FOREIGN KEY (student_id) REFERENCES students(id) ON DELETE CASCADE
-);
-
 INSERT INTO users (id, service) VALUES ('e5e79b0d-1e2c-49f0-97db-4930c9ea8c43', 'testsuite_student');
 INSERT INTO users (id, service) VALUES ('d1b07c6e-9091-4f90-b13a-e3247245f1b5', 'testsuite_student');
 INSERT INTO students (id, login, password) VALUES ('70d0ed1a-25e1-40f7-877d-9fb9ce28969f', 'existedStudent', 'existedPassword');
@@ -54,13 +33,7 @@ INSERT INTO users (id, service) VALUES ('599ce889-11be-4abf-89fb-2940a5b3bfec',
 
 INSERT INTO students (id, login, password) VALUES ('a7b7de0e-d637-41ef-a26a-3a02294ade44', 'testStudent', 'testPassword');
 INSERT INTO user_students (user_id, student_id) VALUES ('599ce889-11be-4abf-89fb-2940a5b3bfec', 'a7b7de0e-d637-41ef-a26a-3a02294ade44');
-
-
 -- +goose StatementEnd
 
 -- +goose Down
--- +goose StatementBegin
-DROP TABLE IF EXISTS user_students;
-DROP TABLE IF EXISTS users;
-DROP TABLE IF EXISTS students;
--- +goose StatementEnd
+-- The seed data goes away with the tables.
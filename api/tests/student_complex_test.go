@@ -43,7 +43,7 @@ func TestComplexStudent(t *testing.T) {
 	parsed, err = uuid.Parse(updatedStudentToken)
 	require.NoError(t, err)
 	assert.Equal(t, uuid.Version(4), parsed.Version())
-	assert.NotEqual(t, studentToken, updatedStudentToken)
+	assert.Equal(t, studentToken, updatedStudentToken)
 
 	deleteResp, err := st.StudentClient.DeleteStudent(ctx, &apiv1.DeleteStudentRequest{UserToken: userToken, StudentToken: updatedStudentToken})
 	require.NoError(t, err)
@@ -0,0 +1,50 @@
+package tests
+
+import (
+	"Elschool-API/tests/suite"
+	apiv1 "github.com/Ilya-Repin/elschooler/protos/gen/api/api.v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+func TestListInstances(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	resp, err := st.StudentClient.ListInstances(ctx, &apiv1.ListInstancesRequest{})
+	require.NoError(t, err)
+	require.NotEmpty(t, resp.GetInstances())
+	assert.Equal(t, "default", resp.GetDefaultInstance())
+	assert.Equal(t, resp.GetDefaultInstance(), resp.GetInstances()[0])
+}
+
+func TestAddStudentDefaultInstance(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	userResp, err := st.UserClient.RegUser(ctx, &apiv1.RegUserRequest{Service: "testsuite_instance"})
+	require.NoError(t, err)
+
+	implicit, err := st.StudentClient.AddStudent(ctx, &apiv1.AddStudentRequest{UserToken: userResp.GetUserToken(), Login: "instanceStudent", Password: "instancePassword"})
+	require.NoError(t, err)
+
+	explicit, err := st.StudentClient.AddStudent(ctx, &apiv1.AddStudentRequest{UserToken: userResp.GetUserToken(), Login: "instanceStudent", Password: "instancePassword", Instance: "default"})
+	require.NoError(t, err)
+	assert.Equal(t, implicit.GetStudentToken(), explicit.GetStudentToken())
+}
+
+func TestAddStudentUnknownInstance(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	userResp, err := st.UserClient.RegUser(ctx, &apiv1.RegUserRequest{Service: "testsuite_instance"})
+	require.NoError(t, err)
+
+	_, err = st.StudentClient.AddStudent(ctx, &apiv1.AddStudentRequest{UserToken: userResp.GetUserToken(), Login: "instanceStudent", Password: "instancePassword", Instance: "nowhere"})
+	require.Error(t, err)
+
+	errStatus, ok := status.FromError(err)
+	require.True(t, ok)
+	assert.Equal(t, codes.InvalidArgument, errStatus.Code())
+	assert.Equal(t, "unknown instance", errStatus.Message())
+}
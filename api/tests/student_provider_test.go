@@ -0,0 +1,60 @@
+package tests
+
+import (
+	"Elschool-API/tests/suite"
+	apiv1 "github.com/Ilya-Repin/elschooler/protos/gen/api/api.v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+func TestListProviders(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	resp, err := st.StudentClient.ListProviders(ctx, &apiv1.ListProvidersRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, "elschool", resp.GetDefaultProvider())
+	require.NotEmpty(t, resp.GetProviders())
+
+	elschool := resp.GetProviders()[0]
+	assert.Equal(t, "elschool", elschool.GetName())
+	assert.Equal(t, "default", elschool.GetDefaultInstance())
+	assert.True(t, elschool.GetCapabilities().GetDayMarks())
+	assert.True(t, elschool.GetCapabilities().GetAverageMarks())
+	assert.True(t, elschool.GetCapabilities().GetFinalMarks())
+}
+
+func TestAddStudentDefaultProvider(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	userResp, err := st.UserClient.RegUser(ctx, &apiv1.RegUserRequest{Service: "testsuite_provider"})
+	require.NoError(t, err)
+
+	implicit, err := st.StudentClient.AddStudent(ctx, &apiv1.AddStudentRequest{UserToken: userResp.GetUserToken(), Login: "providerStudent", Password: "providerPassword"})
+	require.NoError(t, err)
+
+	explicit, err := st.StudentClient.AddStudent(ctx, &apiv1.AddStudentRequest{UserToken: userResp.GetUserToken(), Login: "providerStudent", Password: "providerPassword", Provider: "elschool"})
+	require.NoError(t, err)
+	assert.Equal(t, implicit.GetStudentToken(), explicit.GetStudentToken())
+}
+
+func TestAddStudentUnknownProvider(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	userResp, err := st.UserClient.RegUser(ctx, &apiv1.RegUserRequest{Service: "testsuite_provider"})
+	require.NoError(t, err)
+
+	_, err = st.StudentClient.AddStudent(ctx, &apiv1.AddStudentRequest{UserToken: userResp.GetUserToken(), Login: "providerStudent", Password: "providerPassword", Provider: "nowhere"})
+	require.Error(t, err)
+
+	errStatus, ok := status.FromError(err)
+	require.True(t, ok)
+	assert.Equal(t, codes.InvalidArgument, errStatus.Code())
+	assert.Equal(t, "unknown provider", errStatus.Message())
+
+	_, err = st.StudentClient.ListInstances(ctx, &apiv1.ListInstancesRequest{Provider: "nowhere"})
+	require.Error(t, err)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
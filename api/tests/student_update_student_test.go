@@ -46,4 +46,39 @@ func TestUpdateStudentToNew(t *testing.T) {
 	parsed, err := uuid.Parse(studentResp.GetStudentToken())
 	require.NoError(t, err)
 	assert.Equal(t, uuid.Version(4), parsed.Version())
+	assert.Equal(t, updateStudentId, parsed.String())
+}
+
+func TestUpdateSharedStudentLogin(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	const (
+		sharedLogin    = "testStudentUpdateShared"
+		sharedPassword = "testPasswordUpdateShared"
+		movedLogin     = "testStudentUpdateSharedNew"
+	)
+
+	first, err := st.UserClient.RegUser(ctx, &apiv1.RegUserRequest{Service: "testsuite_update"})
+	require.NoError(t, err)
+	second, err := st.UserClient.RegUser(ctx, &apiv1.RegUserRequest{Service: "testsuite_update"})
+	require.NoError(t, err)
+
+	firstStudent, err := st.StudentClient.AddStudent(ctx, &apiv1.AddStudentRequest{UserToken: first.GetUserToken(), Login: sharedLogin, Password: sharedPassword})
+	require.NoError(t, err)
+	secondStudent, err := st.StudentClient.AddStudent(ctx, &apiv1.AddStudentRequest{UserToken: second.GetUserToken(), Login: sharedLogin, Password: sharedPassword})
+	require.NoError(t, err)
+	require.Equal(t, firstStudent.GetStudentToken(), secondStudent.GetStudentToken())
+
+	studentResp, err := st.StudentClient.UpdateStudent(ctx, &apiv1.UpdateStudentRequest{UserToken: first.GetUserToken(), StudentToken: firstStudent.GetStudentToken(), Login: movedLogin, Password: sharedPassword})
+	require.NoError(t, err)
+	assert.NotEqual(t, firstStudent.GetStudentToken(), studentResp.GetStudentToken())
+
+	// The other user keeps the student and its Elschool account.
+	again, err := st.StudentClient.AddStudent(ctx, &apiv1.AddStudentRequest{UserToken: second.GetUserToken(), Login: sharedLogin, Password: sharedPassword})
+	require.NoError(t, err)
+	assert.Equal(t, secondStudent.GetStudentToken(), again.GetStudentToken())
+
+	moved, err := st.StudentClient.AddStudent(ctx, &apiv1.AddStudentRequest{UserToken: second.GetUserToken(), Login: movedLogin, Password: sharedPassword})
+	require.NoError(t, err)
+	assert.Equal(t, studentResp.GetStudentToken(), moved.GetStudentToken())
 }
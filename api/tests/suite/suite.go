@@ -2,17 +2,24 @@ package suite
 
 import (
 	"Elschool-API/internal/config"
+	"Elschool-API/internal/grpc/interceptors"
 	"context"
 	apiv1 "github.com/Ilya-Repin/elschooler/protos/gen/api/api.v1"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	healthv1 "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
 	"net"
+	"os"
 	"strconv"
 	"testing"
 )
 
 const (
 	grpcHost = "localhost"
+
+	localConfigPath  = "../config/local_tests.yaml"
+	sqliteConfigPath = "../config/sqlite_tests.yaml"
 )
 
 type Suite struct {
@@ -21,13 +28,16 @@ type Suite struct {
 	UserClient    apiv1.UserClient
 	StudentClient apiv1.StudentClient
 	MarksClient   apiv1.MarksClient
+	ApiKeysClient apiv1.ApiKeysClient
+	HealthClient  healthv1.HealthClient
+	AdminClient   apiv1.AdminClient
 }
 
 func New(t *testing.T) (context.Context, *Suite) {
 	t.Helper()
 	t.Parallel()
 
-	cfg := config.MustLoadByPath("../config/local_tests.yaml")
+	cfg := config.MustLoadByPath(ConfigPath())
 
 	ctx, cancelCtx := context.WithTimeout(context.Background(), cfg.GRPCConfig.Timeout)
 
@@ -36,22 +46,74 @@ func New(t *testing.T) (context.Context, *Suite) {
 		cancelCtx()
 	})
 
-	cc, err := grpc.DialContext(ctx,
-		grpcAddress(cfg),
-		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+
+	if cfg.APIKeysConfig.Enabled {
+		opts = append(opts, grpc.WithUnaryInterceptor(withAPIKey(cfg.APIKeysConfig.BootstrapKey)))
+	}
+
+	cc, err := grpc.DialContext(ctx, grpcAddress(cfg), opts...)
 	if err != nil {
 		t.Fatalf("grpc server connection failed: %v", err)
 	}
 
+	adminCC, err := grpc.DialContext(ctx, adminAddress(cfg), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("admin server connection failed: %v", err)
+	}
+
 	return ctx, &Suite{
 		T:             t,
 		Cfg:           cfg,
 		UserClient:    apiv1.NewUserClient(cc),
 		StudentClient: apiv1.NewStudentClient(cc),
 		MarksClient:   apiv1.NewMarksClient(cc),
+		ApiKeysClient: apiv1.NewApiKeysClient(cc),
+		HealthClient:  healthv1.NewHealthClient(cc),
+		AdminClient:   apiv1.NewAdminClient(adminCC),
+	}
+}
+
+// ConfigPath is the config of the tests, CONFIG_PATH overrides the local one.
+// Without a local one tests run on sqlite and the memory cache.
+func ConfigPath() string {
+	if path := os.Getenv("CONFIG_PATH"); path != "" {
+		return path
+	}
+	if _, err := os.Stat(localConfigPath); err == nil {
+		return localConfigPath
 	}
+	return sqliteConfigPath
 }
 
 func grpcAddress(cfg *config.Config) string {
 	return net.JoinHostPort(grpcHost, strconv.Itoa(cfg.GRPCConfig.Port))
 }
+
+func adminAddress(cfg *config.Config) string {
+	return net.JoinHostPort(grpcHost, strconv.Itoa(cfg.AdminConfig.Port))
+}
+
+// AdminContext authorizes admin calls with one of the configured operator
+// tokens, it is false if the admin service isn't running.
+func (s *Suite) AdminContext(ctx context.Context) (context.Context, bool) {
+	if !s.Cfg.AdminConfig.Enabled {
+		return ctx, false
+	}
+
+	for _, token := range s.Cfg.AdminConfig.Tokens {
+		return metadata.AppendToOutgoingContext(ctx, interceptors.AdminTokenHeader, "Bearer "+token), true
+	}
+
+	return ctx, false
+}
+
+// withAPIKey sends the bootstrap key unless the test passes a key itself.
+func withAPIKey(key string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if md, ok := metadata.FromOutgoingContext(ctx); !ok || len(md.Get(interceptors.APIKeyHeader)) == 0 {
+			ctx = metadata.AppendToOutgoingContext(ctx, interceptors.APIKeyHeader, key)
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
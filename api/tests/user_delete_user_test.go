@@ -0,0 +1,41 @@
+package tests
+
+import (
+	"Elschool-API/tests/suite"
+	apiv1 "github.com/Ilya-Repin/elschooler/protos/gen/api/api.v1"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestDeleteUser(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	userResp, err := st.UserClient.RegUser(ctx, &apiv1.RegUserRequest{Service: "testsuite_delete_user"})
+	require.NoError(t, err)
+	token := userResp.GetUserToken()
+
+	ownResp, err := st.StudentClient.AddStudent(ctx, &apiv1.AddStudentRequest{UserToken: token, Login: "deletedUserStudent", Password: "deletedUserPassword"})
+	require.NoError(t, err)
+
+	sharedResp, err := st.StudentClient.AddStudent(ctx, &apiv1.AddStudentRequest{UserToken: token, Login: existedStudentLogin, Password: existedStudentPassword})
+	require.NoError(t, err)
+	require.Equal(t, existedStudentId, sharedResp.GetStudentToken())
+
+	deleteResp, err := st.UserClient.DeleteUser(ctx, &apiv1.DeleteUserRequest{UserToken: token})
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), deleteResp.GetTokensDeleted())
+	assert.Equal(t, int32(2), deleteResp.GetRelationsDeleted())
+	assert.Equal(t, int32(1), deleteResp.GetStudentsDeleted())
+	assert.Equal(t, []string{ownResp.GetStudentToken()}, deleteResp.GetDeletedStudentTokens())
+	assert.Equal(t, int32(1), deleteResp.GetStudentsCacheInvalidated())
+
+	_, err = st.StudentClient.DeleteStudent(ctx, &apiv1.DeleteStudentRequest{UserToken: token, StudentToken: uuid.NewString()})
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "no such user")
+
+	_, err = st.UserClient.DeleteUser(ctx, &apiv1.DeleteUserRequest{UserToken: token})
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "no such user")
+}
@@ -0,0 +1,86 @@
+package tests
+
+import (
+	"Elschool-API/tests/suite"
+	"archive/zip"
+	"bytes"
+	"errors"
+	apiv1 "github.com/Ilya-Repin/elschooler/protos/gen/api/api.v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protojson"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestExportUserData(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	userResp, err := st.UserClient.RegUser(ctx, &apiv1.RegUserRequest{Service: "testsuite_export_user"})
+	require.NoError(t, err)
+	token := userResp.GetUserToken()
+
+	_, err = st.StudentClient.AddStudent(ctx, &apiv1.AddStudentRequest{UserToken: token, Login: existedStudentLogin, Password: existedStudentPassword})
+	require.NoError(t, err)
+
+	finalResp, err := st.MarksClient.GetFinalMarks(ctx, &apiv1.FinalMarksRequest{UserToken: token, StudentToken: existedStudentId})
+	require.NoError(t, err)
+
+	// The last known marks are saved in the background of the request.
+	var (
+		contentType string
+		data        []byte
+		doc         apiv1.UserDataExport
+	)
+	require.EventuallyWithT(t, func(c *assert.CollectT) {
+		stream, err := st.UserClient.ExportUserData(ctx, &apiv1.ExportUserDataRequest{UserToken: token})
+		require.NoError(c, err)
+		contentType, data = readExport(c, stream)
+		require.NoError(c, protojson.Unmarshal(data, &doc))
+		require.Len(c, doc.GetStudents(), 1)
+		assert.Len(c, doc.GetStudents()[0].GetLastMarks().GetFinal().GetMarks(), len(finalResp.GetMarks()))
+	}, 5*time.Second, 100*time.Millisecond)
+	assert.Equal(t, "application/json", contentType)
+	assert.Equal(t, "testsuite_export_user", doc.GetUser().GetService())
+	assert.Len(t, doc.GetTokens(), 1)
+	require.Len(t, doc.GetStudents(), 1)
+	assert.Equal(t, existedStudentId, doc.GetStudents()[0].GetStudentToken())
+	assert.NotContains(t, string(data), existedStudentLogin)
+	assert.NotContains(t, string(data), existedStudentPassword)
+
+	stream, err := st.UserClient.ExportUserData(ctx, &apiv1.ExportUserDataRequest{UserToken: token, Format: apiv1.ExportFormat_EXPORT_FORMAT_ZIP})
+	require.NoError(t, err)
+	contentType, data = readExport(t, stream)
+	assert.Equal(t, "application/zip", contentType)
+
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	require.Len(t, archive.File, 1)
+	assert.Equal(t, "user_data.json", archive.File[0].Name)
+
+	_, err = st.UserClient.DeleteUser(ctx, &apiv1.DeleteUserRequest{UserToken: token})
+	require.NoError(t, err)
+
+	stream, err = st.UserClient.ExportUserData(ctx, &apiv1.ExportUserDataRequest{UserToken: token})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "no such user")
+}
+
+func readExport(t require.TestingT, stream grpc.ServerStreamingClient[apiv1.ExportUserDataResponse]) (contentType string, data []byte) {
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return contentType, data
+		}
+		require.NoError(t, err)
+
+		if resp.GetContentType() != "" {
+			contentType = resp.GetContentType()
+		}
+		data = append(data, resp.GetChunk()...)
+	}
+}
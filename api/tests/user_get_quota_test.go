@@ -0,0 +1,72 @@
+package tests
+
+import (
+	"Elschool-API/tests/suite"
+	"fmt"
+	apiv1 "github.com/Ilya-Repin/elschooler/protos/gen/api/api.v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+// studentsPerUser is the quota set in config/test.yaml.
+const studentsPerUser = 5
+
+func TestStudentsPerUserQuota(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	userResp, err := st.UserClient.RegUser(ctx, &apiv1.RegUserRequest{Service: "testsuite_quota"})
+	require.NoError(t, err)
+	token := userResp.GetUserToken()
+
+	for i := 0; i < studentsPerUser; i++ {
+		login := fmt.Sprintf("quotaStudent%d", i)
+		_, err := st.StudentClient.AddStudent(ctx, &apiv1.AddStudentRequest{UserToken: token, Login: login, Password: login})
+		require.NoError(t, err)
+	}
+
+	_, err = st.StudentClient.AddStudent(ctx, &apiv1.AddStudentRequest{UserToken: token, Login: "quotaStudentExtra", Password: "quotaPasswordExtra"})
+	require.Error(t, err)
+
+	errStatus, ok := status.FromError(err)
+	require.True(t, ok)
+	assert.Equal(t, codes.ResourceExhausted, errStatus.Code())
+	require.Len(t, errStatus.Details(), 1)
+	failure, ok := errStatus.Details()[0].(*errdetails.QuotaFailure)
+	require.True(t, ok)
+	require.Len(t, failure.GetViolations(), 1)
+	assert.Equal(t, "students_per_user", failure.GetViolations()[0].GetDescription())
+
+	quotaResp, err := st.UserClient.GetQuota(ctx, &apiv1.GetQuotaRequest{UserToken: token})
+	require.NoError(t, err)
+	assert.Equal(t, int32(studentsPerUser), quotaResp.GetStudents().GetLimit())
+	assert.Equal(t, int32(studentsPerUser), quotaResp.GetStudents().GetUsed())
+	assert.Equal(t, int32(0), quotaResp.GetStudents().GetRemaining())
+	assert.Len(t, quotaResp.GetLinkedStudents(), studentsPerUser)
+	assert.Equal(t, int32(0), quotaResp.GetMarksRequests().GetUsed())
+}
+
+func TestGetQuotaCountsMarksRequests(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	_, err := st.MarksClient.GetFinalMarks(ctx, &apiv1.FinalMarksRequest{UserToken: marksUserId, StudentToken: marksStudentId})
+	require.NoError(t, err)
+
+	quotaResp, err := st.UserClient.GetQuota(ctx, &apiv1.GetQuotaRequest{UserToken: marksUserId})
+	require.NoError(t, err)
+	assert.Positive(t, quotaResp.GetMarksRequests().GetLimit())
+	assert.NotNil(t, quotaResp.GetMarksRequestsResetAt())
+	require.Len(t, quotaResp.GetLinkedStudents(), 1)
+	assert.Equal(t, marksStudentId, quotaResp.GetLinkedStudents()[0].GetStudentToken())
+}
+
+func TestGetQuotaUnknownUser(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	_, err := st.UserClient.GetQuota(ctx, &apiv1.GetQuotaRequest{UserToken: "9d1b7a43-5b7e-4f0a-9f3c-6b8a1f0c2d4e"})
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "no such user")
+}
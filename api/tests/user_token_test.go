@@ -0,0 +1,47 @@
+package tests
+
+import (
+	"Elschool-API/tests/suite"
+	apiv1 "github.com/Ilya-Repin/elschooler/protos/gen/api/api.v1"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestRotateUserToken(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	userResp, err := st.UserClient.RegUser(ctx, &apiv1.RegUserRequest{Service: "testsuite_token"})
+	require.NoError(t, err)
+
+	rotateResp, err := st.UserClient.RotateUserToken(ctx, &apiv1.RotateUserTokenRequest{UserToken: userResp.GetUserToken()})
+	require.NoError(t, err)
+	assert.NotEqual(t, userResp.GetUserToken(), rotateResp.GetUserToken())
+	assert.NotNil(t, rotateResp.GetPreviousExpiresAt())
+
+	for _, token := range []string{userResp.GetUserToken(), rotateResp.GetUserToken()} {
+		_, err = st.StudentClient.DeleteStudent(ctx, &apiv1.DeleteStudentRequest{UserToken: token, StudentToken: uuid.NewString()})
+		require.Error(t, err)
+		assert.ErrorContains(t, err, "no such student")
+	}
+}
+
+func TestRevokeUserToken(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	userResp, err := st.UserClient.RegUser(ctx, &apiv1.RegUserRequest{Service: "testsuite_token"})
+	require.NoError(t, err)
+
+	revokeResp, err := st.UserClient.RevokeUserToken(ctx, &apiv1.RevokeUserTokenRequest{UserToken: userResp.GetUserToken()})
+	require.NoError(t, err)
+	assert.True(t, revokeResp.GetSuccess())
+
+	_, err = st.StudentClient.DeleteStudent(ctx, &apiv1.DeleteStudentRequest{UserToken: userResp.GetUserToken(), StudentToken: uuid.NewString()})
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "no such user")
+
+	_, err = st.UserClient.RotateUserToken(ctx, &apiv1.RotateUserTokenRequest{UserToken: userResp.GetUserToken()})
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "no such user")
+}
@@ -19,7 +19,12 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	User_RegUser_FullMethodName = "/api.User/RegUser"
+	User_RegUser_FullMethodName         = "/api.User/RegUser"
+	User_RotateUserToken_FullMethodName = "/api.User/RotateUserToken"
+	User_RevokeUserToken_FullMethodName = "/api.User/RevokeUserToken"
+	User_DeleteUser_FullMethodName      = "/api.User/DeleteUser"
+	User_ExportUserData_FullMethodName  = "/api.User/ExportUserData"
+	User_GetQuota_FullMethodName        = "/api.User/GetQuota"
 )
 
 // UserClient is the client API for User service.
@@ -27,6 +32,11 @@ const (
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type UserClient interface {
 	RegUser(ctx context.Context, in *RegUserRequest, opts ...grpc.CallOption) (*RegUserResponse, error)
+	RotateUserToken(ctx context.Context, in *RotateUserTokenRequest, opts ...grpc.CallOption) (*RotateUserTokenResponse, error)
+	RevokeUserToken(ctx context.Context, in *RevokeUserTokenRequest, opts ...grpc.CallOption) (*RevokeUserTokenResponse, error)
+	DeleteUser(ctx context.Context, in *DeleteUserRequest, opts ...grpc.CallOption) (*DeleteUserResponse, error)
+	ExportUserData(ctx context.Context, in *ExportUserDataRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ExportUserDataResponse], error)
+	GetQuota(ctx context.Context, in *GetQuotaRequest, opts ...grpc.CallOption) (*GetQuotaResponse, error)
 }
 
 type userClient struct {
@@ -47,11 +57,75 @@ func (c *userClient) RegUser(ctx context.Context, in *RegUserRequest, opts ...gr
 	return out, nil
 }
 
+func (c *userClient) RotateUserToken(ctx context.Context, in *RotateUserTokenRequest, opts ...grpc.CallOption) (*RotateUserTokenResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RotateUserTokenResponse)
+	err := c.cc.Invoke(ctx, User_RotateUserToken_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userClient) RevokeUserToken(ctx context.Context, in *RevokeUserTokenRequest, opts ...grpc.CallOption) (*RevokeUserTokenResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RevokeUserTokenResponse)
+	err := c.cc.Invoke(ctx, User_RevokeUserToken_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userClient) DeleteUser(ctx context.Context, in *DeleteUserRequest, opts ...grpc.CallOption) (*DeleteUserResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteUserResponse)
+	err := c.cc.Invoke(ctx, User_DeleteUser_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userClient) ExportUserData(ctx context.Context, in *ExportUserDataRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ExportUserDataResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &User_ServiceDesc.Streams[0], User_ExportUserData_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ExportUserDataRequest, ExportUserDataResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type User_ExportUserDataClient = grpc.ServerStreamingClient[ExportUserDataResponse]
+
+func (c *userClient) GetQuota(ctx context.Context, in *GetQuotaRequest, opts ...grpc.CallOption) (*GetQuotaResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetQuotaResponse)
+	err := c.cc.Invoke(ctx, User_GetQuota_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // UserServer is the server API for User service.
 // All implementations must embed UnimplementedUserServer
 // for forward compatibility.
 type UserServer interface {
 	RegUser(context.Context, *RegUserRequest) (*RegUserResponse, error)
+	RotateUserToken(context.Context, *RotateUserTokenRequest) (*RotateUserTokenResponse, error)
+	RevokeUserToken(context.Context, *RevokeUserTokenRequest) (*RevokeUserTokenResponse, error)
+	DeleteUser(context.Context, *DeleteUserRequest) (*DeleteUserResponse, error)
+	ExportUserData(*ExportUserDataRequest, grpc.ServerStreamingServer[ExportUserDataResponse]) error
+	GetQuota(context.Context, *GetQuotaRequest) (*GetQuotaResponse, error)
 	mustEmbedUnimplementedUserServer()
 }
 
@@ -65,6 +139,21 @@ type UnimplementedUserServer struct{}
 func (UnimplementedUserServer) RegUser(context.Context, *RegUserRequest) (*RegUserResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method RegUser not implemented")
 }
+func (UnimplementedUserServer) RotateUserToken(context.Context, *RotateUserTokenRequest) (*RotateUserTokenResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RotateUserToken not implemented")
+}
+func (UnimplementedUserServer) RevokeUserToken(context.Context, *RevokeUserTokenRequest) (*RevokeUserTokenResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevokeUserToken not implemented")
+}
+func (UnimplementedUserServer) DeleteUser(context.Context, *DeleteUserRequest) (*DeleteUserResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteUser not implemented")
+}
+func (UnimplementedUserServer) ExportUserData(*ExportUserDataRequest, grpc.ServerStreamingServer[ExportUserDataResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method ExportUserData not implemented")
+}
+func (UnimplementedUserServer) GetQuota(context.Context, *GetQuotaRequest) (*GetQuotaResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetQuota not implemented")
+}
 func (UnimplementedUserServer) mustEmbedUnimplementedUserServer() {}
 func (UnimplementedUserServer) testEmbeddedByValue()              {}
 
@@ -104,6 +193,89 @@ func _User_RegUser_Handler(srv interface{}, ctx context.Context, dec func(interf
 	return interceptor(ctx, in, info, handler)
 }
 
+func _User_RotateUserToken_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RotateUserTokenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServer).RotateUserToken(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: User_RotateUserToken_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServer).RotateUserToken(ctx, req.(*RotateUserTokenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _User_RevokeUserToken_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokeUserTokenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServer).RevokeUserToken(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: User_RevokeUserToken_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServer).RevokeUserToken(ctx, req.(*RevokeUserTokenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _User_DeleteUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServer).DeleteUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: User_DeleteUser_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServer).DeleteUser(ctx, req.(*DeleteUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _User_ExportUserData_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExportUserDataRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(UserServer).ExportUserData(m, &grpc.GenericServerStream[ExportUserDataRequest, ExportUserDataResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type User_ExportUserDataServer = grpc.ServerStreamingServer[ExportUserDataResponse]
+
+func _User_GetQuota_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetQuotaRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServer).GetQuota(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: User_GetQuota_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServer).GetQuota(ctx, req.(*GetQuotaRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // User_ServiceDesc is the grpc.ServiceDesc for User service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -115,8 +287,30 @@ var User_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "RegUser",
 			Handler:    _User_RegUser_Handler,
 		},
+		{
+			MethodName: "RotateUserToken",
+			Handler:    _User_RotateUserToken_Handler,
+		},
+		{
+			MethodName: "RevokeUserToken",
+			Handler:    _User_RevokeUserToken_Handler,
+		},
+		{
+			MethodName: "DeleteUser",
+			Handler:    _User_DeleteUser_Handler,
+		},
+		{
+			MethodName: "GetQuota",
+			Handler:    _User_GetQuota_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ExportUserData",
+			Handler:       _User_ExportUserData_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/api/api.proto",
 }
 
@@ -124,6 +318,8 @@ const (
 	Student_AddStudent_FullMethodName    = "/api.Student/AddStudent"
 	Student_DeleteStudent_FullMethodName = "/api.Student/DeleteStudent"
 	Student_UpdateStudent_FullMethodName = "/api.Student/UpdateStudent"
+	Student_ListInstances_FullMethodName = "/api.Student/ListInstances"
+	Student_ListProviders_FullMethodName = "/api.Student/ListProviders"
 )
 
 // StudentClient is the client API for Student service.
@@ -133,6 +329,8 @@ type StudentClient interface {
 	AddStudent(ctx context.Context, in *AddStudentRequest, opts ...grpc.CallOption) (*AddStudentResponse, error)
 	DeleteStudent(ctx context.Context, in *DeleteStudentRequest, opts ...grpc.CallOption) (*DeleteStudentResponse, error)
 	UpdateStudent(ctx context.Context, in *UpdateStudentRequest, opts ...grpc.CallOption) (*UpdateStudentResponse, error)
+	ListInstances(ctx context.Context, in *ListInstancesRequest, opts ...grpc.CallOption) (*ListInstancesResponse, error)
+	ListProviders(ctx context.Context, in *ListProvidersRequest, opts ...grpc.CallOption) (*ListProvidersResponse, error)
 }
 
 type studentClient struct {
@@ -173,6 +371,26 @@ func (c *studentClient) UpdateStudent(ctx context.Context, in *UpdateStudentRequ
 	return out, nil
 }
 
+func (c *studentClient) ListInstances(ctx context.Context, in *ListInstancesRequest, opts ...grpc.CallOption) (*ListInstancesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListInstancesResponse)
+	err := c.cc.Invoke(ctx, Student_ListInstances_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *studentClient) ListProviders(ctx context.Context, in *ListProvidersRequest, opts ...grpc.CallOption) (*ListProvidersResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListProvidersResponse)
+	err := c.cc.Invoke(ctx, Student_ListProviders_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // StudentServer is the server API for Student service.
 // All implementations must embed UnimplementedStudentServer
 // for forward compatibility.
@@ -180,6 +398,8 @@ type StudentServer interface {
 	AddStudent(context.Context, *AddStudentRequest) (*AddStudentResponse, error)
 	DeleteStudent(context.Context, *DeleteStudentRequest) (*DeleteStudentResponse, error)
 	UpdateStudent(context.Context, *UpdateStudentRequest) (*UpdateStudentResponse, error)
+	ListInstances(context.Context, *ListInstancesRequest) (*ListInstancesResponse, error)
+	ListProviders(context.Context, *ListProvidersRequest) (*ListProvidersResponse, error)
 	mustEmbedUnimplementedStudentServer()
 }
 
@@ -199,6 +419,12 @@ func (UnimplementedStudentServer) DeleteStudent(context.Context, *DeleteStudentR
 func (UnimplementedStudentServer) UpdateStudent(context.Context, *UpdateStudentRequest) (*UpdateStudentResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method UpdateStudent not implemented")
 }
+func (UnimplementedStudentServer) ListInstances(context.Context, *ListInstancesRequest) (*ListInstancesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListInstances not implemented")
+}
+func (UnimplementedStudentServer) ListProviders(context.Context, *ListProvidersRequest) (*ListProvidersResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListProviders not implemented")
+}
 func (UnimplementedStudentServer) mustEmbedUnimplementedStudentServer() {}
 func (UnimplementedStudentServer) testEmbeddedByValue()                 {}
 
@@ -274,6 +500,42 @@ func _Student_UpdateStudent_Handler(srv interface{}, ctx context.Context, dec fu
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Student_ListInstances_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListInstancesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StudentServer).ListInstances(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Student_ListInstances_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StudentServer).ListInstances(ctx, req.(*ListInstancesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Student_ListProviders_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListProvidersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StudentServer).ListProviders(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Student_ListProviders_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StudentServer).ListProviders(ctx, req.(*ListProvidersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Student_ServiceDesc is the grpc.ServiceDesc for Student service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -293,15 +555,25 @@ var Student_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "UpdateStudent",
 			Handler:    _Student_UpdateStudent_Handler,
 		},
+		{
+			MethodName: "ListInstances",
+			Handler:    _Student_ListInstances_Handler,
+		},
+		{
+			MethodName: "ListProviders",
+			Handler:    _Student_ListProviders_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/api/api.proto",
 }
 
 const (
-	Marks_GetDayMarks_FullMethodName     = "/api.Marks/GetDayMarks"
-	Marks_GetAverageMarks_FullMethodName = "/api.Marks/GetAverageMarks"
-	Marks_GetFinalMarks_FullMethodName   = "/api.Marks/GetFinalMarks"
+	Marks_GetDayMarks_FullMethodName        = "/api.Marks/GetDayMarks"
+	Marks_GetAverageMarks_FullMethodName    = "/api.Marks/GetAverageMarks"
+	Marks_GetFinalMarks_FullMethodName      = "/api.Marks/GetFinalMarks"
+	Marks_CreateEventsTicket_FullMethodName = "/api.Marks/CreateEventsTicket"
+	Marks_WatchMarks_FullMethodName         = "/api.Marks/WatchMarks"
 )
 
 // MarksClient is the client API for Marks service.
@@ -311,6 +583,11 @@ type MarksClient interface {
 	GetDayMarks(ctx context.Context, in *DayMarksRequest, opts ...grpc.CallOption) (*DayMarksResponse, error)
 	GetAverageMarks(ctx context.Context, in *AverageMarksRequest, opts ...grpc.CallOption) (*AverageMarksResponse, error)
 	GetFinalMarks(ctx context.Context, in *FinalMarksRequest, opts ...grpc.CallOption) (*FinalMarksResponse, error)
+	CreateEventsTicket(ctx context.Context, in *EventsTicketRequest, opts ...grpc.CallOption) (*EventsTicketResponse, error)
+	// WatchMarks streams changes of the student's marks. Over HTTP it is served
+	// as Server-Sent Events at GET /v1/students/{student_token}/events with the
+	// ticket and last_event_id query parameters.
+	WatchMarks(ctx context.Context, in *WatchMarksRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[WatchMarksResponse], error)
 }
 
 type marksClient struct {
@@ -351,6 +628,35 @@ func (c *marksClient) GetFinalMarks(ctx context.Context, in *FinalMarksRequest,
 	return out, nil
 }
 
+func (c *marksClient) CreateEventsTicket(ctx context.Context, in *EventsTicketRequest, opts ...grpc.CallOption) (*EventsTicketResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(EventsTicketResponse)
+	err := c.cc.Invoke(ctx, Marks_CreateEventsTicket_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *marksClient) WatchMarks(ctx context.Context, in *WatchMarksRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[WatchMarksResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Marks_ServiceDesc.Streams[0], Marks_WatchMarks_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchMarksRequest, WatchMarksResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Marks_WatchMarksClient = grpc.ServerStreamingClient[WatchMarksResponse]
+
 // MarksServer is the server API for Marks service.
 // All implementations must embed UnimplementedMarksServer
 // for forward compatibility.
@@ -358,6 +664,11 @@ type MarksServer interface {
 	GetDayMarks(context.Context, *DayMarksRequest) (*DayMarksResponse, error)
 	GetAverageMarks(context.Context, *AverageMarksRequest) (*AverageMarksResponse, error)
 	GetFinalMarks(context.Context, *FinalMarksRequest) (*FinalMarksResponse, error)
+	CreateEventsTicket(context.Context, *EventsTicketRequest) (*EventsTicketResponse, error)
+	// WatchMarks streams changes of the student's marks. Over HTTP it is served
+	// as Server-Sent Events at GET /v1/students/{student_token}/events with the
+	// ticket and last_event_id query parameters.
+	WatchMarks(*WatchMarksRequest, grpc.ServerStreamingServer[WatchMarksResponse]) error
 	mustEmbedUnimplementedMarksServer()
 }
 
@@ -377,6 +688,12 @@ func (UnimplementedMarksServer) GetAverageMarks(context.Context, *AverageMarksRe
 func (UnimplementedMarksServer) GetFinalMarks(context.Context, *FinalMarksRequest) (*FinalMarksResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetFinalMarks not implemented")
 }
+func (UnimplementedMarksServer) CreateEventsTicket(context.Context, *EventsTicketRequest) (*EventsTicketResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateEventsTicket not implemented")
+}
+func (UnimplementedMarksServer) WatchMarks(*WatchMarksRequest, grpc.ServerStreamingServer[WatchMarksResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchMarks not implemented")
+}
 func (UnimplementedMarksServer) mustEmbedUnimplementedMarksServer() {}
 func (UnimplementedMarksServer) testEmbeddedByValue()               {}
 
@@ -452,6 +769,35 @@ func _Marks_GetFinalMarks_Handler(srv interface{}, ctx context.Context, dec func
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Marks_CreateEventsTicket_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EventsTicketRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MarksServer).CreateEventsTicket(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Marks_CreateEventsTicket_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MarksServer).CreateEventsTicket(ctx, req.(*EventsTicketRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Marks_WatchMarks_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchMarksRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(MarksServer).WatchMarks(m, &grpc.GenericServerStream[WatchMarksRequest, WatchMarksResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Marks_WatchMarksServer = grpc.ServerStreamingServer[WatchMarksResponse]
+
 // Marks_ServiceDesc is the grpc.ServiceDesc for Marks service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -471,6 +817,194 @@ var Marks_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetFinalMarks",
 			Handler:    _Marks_GetFinalMarks_Handler,
 		},
+		{
+			MethodName: "CreateEventsTicket",
+			Handler:    _Marks_CreateEventsTicket_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchMarks",
+			Handler:       _Marks_WatchMarks_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "proto/api/api.proto",
+}
+
+const (
+	ApiKeys_CreateApiKey_FullMethodName = "/api.ApiKeys/CreateApiKey"
+	ApiKeys_ListApiKeys_FullMethodName  = "/api.ApiKeys/ListApiKeys"
+	ApiKeys_RevokeApiKey_FullMethodName = "/api.ApiKeys/RevokeApiKey"
+)
+
+// ApiKeysClient is the client API for ApiKeys service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ApiKeysClient interface {
+	CreateApiKey(ctx context.Context, in *CreateApiKeyRequest, opts ...grpc.CallOption) (*CreateApiKeyResponse, error)
+	ListApiKeys(ctx context.Context, in *ListApiKeysRequest, opts ...grpc.CallOption) (*ListApiKeysResponse, error)
+	RevokeApiKey(ctx context.Context, in *RevokeApiKeyRequest, opts ...grpc.CallOption) (*RevokeApiKeyResponse, error)
+}
+
+type apiKeysClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewApiKeysClient(cc grpc.ClientConnInterface) ApiKeysClient {
+	return &apiKeysClient{cc}
+}
+
+func (c *apiKeysClient) CreateApiKey(ctx context.Context, in *CreateApiKeyRequest, opts ...grpc.CallOption) (*CreateApiKeyResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CreateApiKeyResponse)
+	err := c.cc.Invoke(ctx, ApiKeys_CreateApiKey_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *apiKeysClient) ListApiKeys(ctx context.Context, in *ListApiKeysRequest, opts ...grpc.CallOption) (*ListApiKeysResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListApiKeysResponse)
+	err := c.cc.Invoke(ctx, ApiKeys_ListApiKeys_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *apiKeysClient) RevokeApiKey(ctx context.Context, in *RevokeApiKeyRequest, opts ...grpc.CallOption) (*RevokeApiKeyResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RevokeApiKeyResponse)
+	err := c.cc.Invoke(ctx, ApiKeys_RevokeApiKey_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ApiKeysServer is the server API for ApiKeys service.
+// All implementations must embed UnimplementedApiKeysServer
+// for forward compatibility.
+type ApiKeysServer interface {
+	CreateApiKey(context.Context, *CreateApiKeyRequest) (*CreateApiKeyResponse, error)
+	ListApiKeys(context.Context, *ListApiKeysRequest) (*ListApiKeysResponse, error)
+	RevokeApiKey(context.Context, *RevokeApiKeyRequest) (*RevokeApiKeyResponse, error)
+	mustEmbedUnimplementedApiKeysServer()
+}
+
+// UnimplementedApiKeysServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedApiKeysServer struct{}
+
+func (UnimplementedApiKeysServer) CreateApiKey(context.Context, *CreateApiKeyRequest) (*CreateApiKeyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateApiKey not implemented")
+}
+func (UnimplementedApiKeysServer) ListApiKeys(context.Context, *ListApiKeysRequest) (*ListApiKeysResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListApiKeys not implemented")
+}
+func (UnimplementedApiKeysServer) RevokeApiKey(context.Context, *RevokeApiKeyRequest) (*RevokeApiKeyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevokeApiKey not implemented")
+}
+func (UnimplementedApiKeysServer) mustEmbedUnimplementedApiKeysServer() {}
+func (UnimplementedApiKeysServer) testEmbeddedByValue()                 {}
+
+// UnsafeApiKeysServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ApiKeysServer will
+// result in compilation errors.
+type UnsafeApiKeysServer interface {
+	mustEmbedUnimplementedApiKeysServer()
+}
+
+func RegisterApiKeysServer(s grpc.ServiceRegistrar, srv ApiKeysServer) {
+	// If the following call pancis, it indicates UnimplementedApiKeysServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&ApiKeys_ServiceDesc, srv)
+}
+
+func _ApiKeys_CreateApiKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateApiKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ApiKeysServer).CreateApiKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ApiKeys_CreateApiKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ApiKeysServer).CreateApiKey(ctx, req.(*CreateApiKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ApiKeys_ListApiKeys_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListApiKeysRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ApiKeysServer).ListApiKeys(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ApiKeys_ListApiKeys_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ApiKeysServer).ListApiKeys(ctx, req.(*ListApiKeysRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ApiKeys_RevokeApiKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokeApiKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ApiKeysServer).RevokeApiKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ApiKeys_RevokeApiKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ApiKeysServer).RevokeApiKey(ctx, req.(*RevokeApiKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// ApiKeys_ServiceDesc is the grpc.ServiceDesc for ApiKeys service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ApiKeys_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "api.ApiKeys",
+	HandlerType: (*ApiKeysServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateApiKey",
+			Handler:    _ApiKeys_CreateApiKey_Handler,
+		},
+		{
+			MethodName: "ListApiKeys",
+			Handler:    _ApiKeys_ListApiKeys_Handler,
+		},
+		{
+			MethodName: "RevokeApiKey",
+			Handler:    _ApiKeys_RevokeApiKey_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/api/api.proto",
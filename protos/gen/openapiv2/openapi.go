@@ -0,0 +1,7 @@
+// Package openapiv2 embeds the OpenAPI document generated from api.proto.
+package openapiv2
+
+import _ "embed"
+
+//go:embed api.swagger.json
+var Spec []byte